@@ -0,0 +1,261 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/config"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+)
+
+// ManifestFileName is the name of the manifest written to a backup directory
+const ManifestFileName = "MANIFEST"
+
+const manifestVersion = 1
+
+// Manifest describes the live segments of a backup, and how they differ from the ones of the previous backup in the same directory
+type Manifest struct {
+	Version  int               `json:"version"`
+	Segments []ManifestSegment `json:"segments"`
+	Added    []uint64          `json:"added,omitempty"`   // fileIds of the segments copied by this backup
+	Removed  []uint64          `json:"removed,omitempty"` // fileIds of the segments of the previous backup which merge has removed since
+}
+
+// ManifestSegment describes a segment file of a backup, along with the CRC-32 (IEEE) checksum of its content
+type ManifestSegment struct {
+	FileId   uint64 `json:"fileId"`
+	Size     int64  `json:"size"`
+	Checksum uint32 `json:"checksum"`
+}
+
+// Backup takes a consistent backup of the database in dstDir without stopping writes, copying only the segments missing from a previous backup in dstDir
+func (db *DB[Key]) Backup(ctx context.Context, dstDir string) (*Manifest, error) {
+	segmentFiles, err := db.kvStore.PinSegments()
+	if err != nil {
+		return nil, err
+	}
+	defer db.kvStore.UnpinSegments(fileIdsOf(segmentFiles))
+
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return nil, err
+	}
+	previousSegments, err := readPreviousSegments(dstDir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Version: manifestVersion}
+	for _, segmentFile := range segmentFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if segment, ok := previousSegments[segmentFile.FileId]; ok && segment.Size == segmentFile.Size {
+			manifest.Segments = append(manifest.Segments, segment)
+			delete(previousSegments, segmentFile.FileId)
+			continue
+		}
+		segment, err := backupSegment(segmentFile, dstDir)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Segments = append(manifest.Segments, segment)
+		manifest.Added = append(manifest.Added, segment.FileId)
+	}
+	manifest.Removed = slices.Sorted(maps.Keys(previousSegments))
+
+	if err := writeManifest(manifest, dstDir); err != nil {
+		return nil, err
+	}
+	for _, fileId := range manifest.Removed {
+		if err := os.Remove(path.Join(dstDir, kvlog.SegmentFileName(fileId))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// Restore copies the segments listed in the manifest of the backup in backupDir to directory, which must hold no segment, and opens the database in it with opts
+func Restore[Key config.BitcaskKey](backupDir string, directory string, opts ...Option) (*DB[Key], error) {
+	manifest, err := ReadManifest(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range manifest.Segments {
+		if err := verifyManifestSegment(backupDir, segment); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	existing, err := kvlog.ListSegmentFiles(directory)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("can not restore into %v, it already contains %v segments", directory, len(existing))
+	}
+	for _, segment := range manifest.Segments {
+		name := kvlog.SegmentFileName(segment.FileId)
+		if _, err := copyFile(path.Join(backupDir, name), path.Join(directory, name)); err != nil {
+			return nil, err
+		}
+	}
+	return Open[Key](directory, opts...)
+}
+
+// ReadManifest reads the manifest of the backup in backupDir
+func ReadManifest(backupDir string) (*Manifest, error) {
+	content, err := os.ReadFile(path.Join(backupDir, ManifestFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v has no manifest", ErrInvalidBackup, backupDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("%w: manifest can not be decoded: %v", ErrInvalidBackup, err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("%w: unsupported manifest version %v", ErrInvalidBackup, manifest.Version)
+	}
+	return manifest, nil
+}
+
+// readPreviousSegments returns the segments of the previous backup in dstDir by fileId, or an empty map if dstDir holds no backup
+func readPreviousSegments(dstDir string) (map[uint64]ManifestSegment, error) {
+	segments := make(map[uint64]ManifestSegment)
+	if _, err := os.Stat(path.Join(dstDir, ManifestFileName)); errors.Is(err, os.ErrNotExist) {
+		return segments, nil
+	}
+	manifest, err := ReadManifest(dstDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range manifest.Segments {
+		segments[segment.FileId] = segment
+	}
+	return segments, nil
+}
+
+// backupSegment hard-links the segment file into dstDir, falling back to a copy, and returns its description for the manifest
+func backupSegment(segmentFile *kvlog.SegmentFile, dstDir string) (ManifestSegment, error) {
+	dstPath := path.Join(dstDir, kvlog.SegmentFileName(segmentFile.FileId))
+	if err := os.Remove(dstPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return ManifestSegment{}, err
+	}
+
+	var checksum uint32
+	var err error
+	if os.Link(segmentFile.Path, dstPath) == nil {
+		checksum, err = checksumOf(dstPath)
+	} else {
+		checksum, err = copyFile(segmentFile.Path, dstPath)
+	}
+	if err != nil {
+		return ManifestSegment{}, err
+	}
+	return ManifestSegment{FileId: segmentFile.FileId, Size: segmentFile.Size, Checksum: checksum}, nil
+}
+
+func verifyManifestSegment(backupDir string, segment ManifestSegment) error {
+	filePath := path.Join(backupDir, kvlog.SegmentFileName(segment.FileId))
+	info, err := os.Stat(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: segment %v is missing", ErrInvalidBackup, segment.FileId)
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() != segment.Size {
+		return fmt.Errorf("%w: segment %v has %v bytes, manifest records %v", ErrInvalidBackup, segment.FileId, info.Size(), segment.Size)
+	}
+	checksum, err := checksumOf(filePath)
+	if err != nil {
+		return err
+	}
+	if checksum != segment.Checksum {
+		return fmt.Errorf("%w: checksum of segment %v does not match the manifest", ErrInvalidBackup, segment.FileId)
+	}
+	return nil
+}
+
+// writeManifest writes the manifest to a temporary file and renames it, so a backup directory never has a partially written manifest
+func writeManifest(manifest *Manifest, dstDir string) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempPath := path.Join(dstDir, ManifestFileName+".tmp")
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path.Join(dstDir, ManifestFileName))
+}
+
+// copyFile copies the file at srcPath to dstPath, syncs it and returns the checksum of its content
+func copyFile(srcPath string, dstPath string) (uint32, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return 0, err
+	}
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(io.MultiWriter(dst, hash), src); err != nil {
+		dst.Close()
+		return 0, err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return 0, err
+	}
+	return hash.Sum32(), dst.Close()
+}
+
+func checksumOf(filePath string) (uint32, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, file); err != nil {
+		return 0, err
+	}
+	return hash.Sum32(), nil
+}
+
+func fileIdsOf(segmentFiles []*kvlog.SegmentFile) []uint64 {
+	fileIds := make([]uint64, len(segmentFiles))
+	for index, segmentFile := range segmentFiles {
+		fileIds[index] = segmentFile.FileId
+	}
+	return fileIds
+}
@@ -0,0 +1,175 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/config"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func openWithSegmentSize(t *testing.T, directory string, maxSegmentSize uint64) *DB[serializableKey] {
+	db, err := Open[serializableKey](
+		directory,
+		WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}),
+		WithMaxSegmentSize(maxSegmentSize),
+	)
+	require.NoError(t, err)
+	return db
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testBackup")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	db.Put("Topic", []byte("Microservices"))
+	db.Put("Disk", []byte("SSD"))
+	db.Delete("Disk")
+
+	manifest, err := db.Backup(context.Background(), path.Join(tempDir, "backup"))
+	require.NoError(t, err)
+	require.NotEmpty(t, manifest.Segments)
+
+	db.Put("Engine", []byte("Bitcask"))
+	db.Shutdown()
+
+	restored, err := Restore[serializableKey](
+		path.Join(tempDir, "backup"),
+		path.Join(tempDir, "restored"),
+		WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}),
+	)
+	require.NoError(t, err)
+	defer restored.Shutdown()
+
+	value, err := restored.Get("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Microservices", string(value))
+
+	_, err = restored.Get("Disk")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = restored.Get("Engine")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestBackupKeepsPinnedSegmentsThroughAMerge(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testBackupPinned")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 16)
+	defer db.Shutdown()
+	for _, value := range []string{"Databases", "Microservices", "Storage engines", "Networks", "Compilers", "Distributed systems"} {
+		db.Put("Topic", []byte(value))
+	}
+
+	segmentFiles, err := db.kvStore.PinSegments()
+	require.NoError(t, err)
+	require.NoError(t, db.Merge())
+	for _, segmentFile := range segmentFiles {
+		require.FileExists(t, segmentFile.Path)
+	}
+
+	db.kvStore.UnpinSegments(fileIdsOf(segmentFiles))
+	for _, segmentFile := range segmentFiles {
+		require.NoFileExists(t, segmentFile.Path)
+	}
+	value, err := db.Get("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Distributed systems", string(value))
+}
+
+func TestRestoreATamperedBackup(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testRestoreTampered")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	db.Put("Topic", []byte("Microservices"))
+	manifest, err := db.Backup(context.Background(), path.Join(tempDir, "backup"))
+	require.NoError(t, err)
+	db.Shutdown()
+
+	segmentPath := path.Join(tempDir, "backup", kvlog.SegmentFileName(manifest.Segments[0].FileId))
+	content, _ := os.ReadFile(segmentPath)
+	content[len(content)-2] ^= 0xFF
+	os.Remove(segmentPath)
+	os.WriteFile(segmentPath, content, 0644)
+
+	_, err = Restore[serializableKey](
+		path.Join(tempDir, "backup"),
+		path.Join(tempDir, "restored"),
+		WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}),
+	)
+	require.ErrorIs(t, err, ErrInvalidBackup)
+}
+
+func TestBackupWithACancelledContext(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testBackupCancelled")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	defer db.Shutdown()
+	db.Put("Topic", []byte("Microservices"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := db.Backup(ctx, path.Join(tempDir, "backup"))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIncrementalBackupCopiesNewSegmentsAndRecordsRemovedOnes(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testIncrementalBackup")
+	defer os.RemoveAll(tempDir)
+	backupDir := path.Join(tempDir, "backup")
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 16)
+	db.Put("Topic", []byte("Databases"))
+	db.Put("Disk", []byte("SSD"))
+	first, err := db.Backup(context.Background(), backupDir)
+	require.NoError(t, err)
+	require.Equal(t, len(first.Segments), len(first.Added))
+	require.Empty(t, first.Removed)
+
+	db.Put("Engine", []byte("Bitcask"))
+	second, err := db.Backup(context.Background(), backupDir)
+	require.NoError(t, err)
+	require.Equal(t, len(first.Segments)+1, len(second.Segments))
+	require.Equal(t, 1, len(second.Added))
+	require.Empty(t, second.Removed)
+
+	db.Put("Topic", []byte("Microservices"))
+	require.NoError(t, db.Merge())
+	db.Put("Editor", []byte("Vim"))
+	third, err := db.Backup(context.Background(), backupDir)
+	require.NoError(t, err)
+	require.Equal(t, fileIdsOf(segmentFilesOf(second)), third.Removed)
+	for _, fileId := range third.Removed {
+		require.NoFileExists(t, path.Join(backupDir, kvlog.SegmentFileName(fileId)))
+	}
+	db.Shutdown()
+
+	restored, err := Restore[serializableKey](
+		backupDir,
+		path.Join(tempDir, "restored"),
+		WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}),
+	)
+	require.NoError(t, err)
+	defer restored.Shutdown()
+
+	restoredSegments, _ := kvlog.ListSegmentFiles(path.Join(tempDir, "restored"))
+	require.Equal(t, len(third.Segments)+1, len(restoredSegments))
+	require.Equal(t, 4, restored.Len())
+	value, err := restored.Get("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Microservices", string(value))
+}
+
+func segmentFilesOf(manifest *Manifest) []*kvlog.SegmentFile {
+	var segmentFiles []*kvlog.SegmentFile
+	for _, segment := range manifest.Segments {
+		segmentFiles = append(segmentFiles, &kvlog.SegmentFile{FileId: segment.FileId})
+	}
+	return segmentFiles
+}
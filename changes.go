@@ -0,0 +1,23 @@
+package bitcask
+
+import "ashishkujoy/bitcask/kv"
+
+// Op identifies the kind of write in a change event
+type Op = kv.Op
+
+// Position identifies a record in the log by the fileId of its segment and its offset in the segment
+type Position = kv.Position
+
+// OverflowPolicy decides what happens to a change event when the buffer of a subscription is full
+type OverflowPolicy = kv.OverflowPolicy
+
+const (
+	OpPut          = kv.OpPut
+	OpDelete       = kv.OpDelete
+	OpDropKeyspace = kv.OpDropKeyspace
+
+	// DropEvents drops the events a slow subscriber has no room for
+	DropEvents = kv.DropEvents
+	// BlockWriters makes the writers wait until a slow subscriber has room for the event
+	BlockWriters = kv.BlockWriters
+)
@@ -0,0 +1,301 @@
+// Package client accesses a DB served by the remote package, with the method set of the DB taking a context.
+//
+// Connections are pooled, and reads are retried on a new connection when theirs fails, whereas writes, which may have been applied, are not.
+package client
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/internal/rpc"
+	"ashishkujoy/bitcask/internal/wire"
+	"ashishkujoy/bitcask/kv"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrClientClosed is returned by every call made after Close
+var ErrClientClosed = errors.New("client is closed")
+
+// Client sends the calls to a remote server over a pool of connections. It is safe for concurrent use.
+type Client[Key config.BitcaskKey] struct {
+	address  string
+	keyCodec config.KeyCodec[Key]
+	options  options
+	dialer   *net.Dialer
+
+	lock   sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// conn is a pooled connection. broken marks a connection whose state is unknown, which is closed instead of returning to the pool.
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	broken bool
+}
+
+// New creates a Client of the server at address, using keyCodec to convert keys to and from bytes. Connections are dialled when needed.
+func New[Key config.BitcaskKey](address string, keyCodec config.KeyCodec[Key], opts ...Option) *Client[Key] {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Client[Key]{
+		address:  address,
+		keyCodec: keyCodec,
+		options:  options,
+		dialer:   &net.Dialer{Timeout: options.dialTimeout},
+	}
+}
+
+// Get gets the value of the key. A missing key is reported as bitcask.ErrKeyNotFound.
+func (client *Client[Key]) Get(ctx context.Context, key Key) ([]byte, error) {
+	var value []byte
+	err := client.call(ctx, alwaysRetry, func(conn *conn) error {
+		response, err := conn.roundTrip(rpc.OpGet, client.keyCodec.Encode(key))
+		value = response
+		return err
+	})
+	return value, err
+}
+
+// Put puts the key value pair
+func (client *Client[Key]) Put(ctx context.Context, key Key, value []byte) error {
+	payload := append(wire.AppendBytes(nil, client.keyCodec.Encode(key)), value...)
+	if err := checkRequestSize(payload); err != nil {
+		return err
+	}
+	return client.call(ctx, neverRetry, func(conn *conn) error {
+		_, err := conn.roundTrip(rpc.OpPut, payload)
+		return err
+	})
+}
+
+// Delete deletes the key
+func (client *Client[Key]) Delete(ctx context.Context, key Key) error {
+	return client.call(ctx, neverRetry, func(conn *conn) error {
+		_, err := conn.roundTrip(rpc.OpDelete, client.keyCodec.Encode(key))
+		return err
+	})
+}
+
+// PutAll puts the key value pairs, in order, in as few requests as rpc.MaxRequestPayload allows. Like DB.PutAll, it is not atomic.
+func (client *Client[Key]) PutAll(ctx context.Context, pairs []kv.KeyValue[Key]) error {
+	for len(pairs) > 0 {
+		payload, count := client.encodePutAll(pairs)
+		if err := checkRequestSize(payload); err != nil {
+			return err
+		}
+		err := client.call(ctx, neverRetry, func(conn *conn) error {
+			_, err := conn.roundTrip(rpc.OpPutAll, payload)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		pairs = pairs[count:]
+	}
+	return nil
+}
+
+// encodePutAll encodes the payload of a PutAll request of the leading pairs which fit in a request, at least one, and returns their count
+func (client *Client[Key]) encodePutAll(pairs []kv.KeyValue[Key]) ([]byte, int) {
+	var fields []byte
+	count := 0
+	for _, pair := range pairs {
+		field := wire.AppendBytes(wire.AppendBytes(nil, client.keyCodec.Encode(pair.Key)), pair.Value)
+		if count > 0 && 4+len(fields)+len(field) > rpc.MaxRequestPayload {
+			break
+		}
+		fields = append(fields, field...)
+		count++
+	}
+	return append(wire.LittleEndian.AppendUint32(nil, uint32(count)), fields...), count
+}
+
+// checkRequestSize rejects a request which the server would not read, before it is sent
+func checkRequestSize(payload []byte) error {
+	if len(payload) > rpc.MaxRequestPayload {
+		return fmt.Errorf("%w: request of %v bytes exceeds the maximum of %v bytes", bitcask.ErrValueTooLarge, len(payload), rpc.MaxRequestPayload)
+	}
+	return nil
+}
+
+// Scan is DB.Scan, streaming the entries from the server
+func (client *Client[Key]) Scan(ctx context.Context, prefix []byte, fn func(key Key, value []byte) bool) error {
+	delivered := false
+	canRetry := func() bool { return !delivered }
+	return client.call(ctx, canRetry, func(conn *conn) error {
+		if err := conn.send(rpc.OpScan, prefix); err != nil {
+			return err
+		}
+		for {
+			response, err := wire.ReadFrame(conn.reader)
+			if err != nil {
+				return err
+			}
+			switch response.Kind {
+			case rpc.StatusEntry:
+				fields := wire.NewPayloadReader(response.Payload)
+				encodedKey, value := fields.Bytes(), fields.Rest()
+				if fields.Err() != nil {
+					return fields.Err()
+				}
+				key, err := client.keyCodec.Decode(encodedKey)
+				if err != nil {
+					return err
+				}
+				delivered = true
+				if !fn(key, value) {
+					// the rest of the scan is still on its way, so the connection can not be reused
+					conn.broken = true
+					return nil
+				}
+			case rpc.StatusEnd:
+				return nil
+			case rpc.StatusError:
+				return rpc.DecodeError(response.Payload)
+			default:
+				return fmt.Errorf("unexpected response %v to a scan", response.Kind)
+			}
+		}
+	})
+}
+
+// Ping checks that the server answers
+func (client *Client[Key]) Ping(ctx context.Context) error {
+	return client.call(ctx, alwaysRetry, func(conn *conn) error {
+		_, err := conn.roundTrip(rpc.OpPing, nil)
+		return err
+	})
+}
+
+// Close closes the pooled connections. The calls in progress complete, after which their connections are closed.
+func (client *Client[Key]) Close() error {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	client.closed = true
+	var err error
+	for _, conn := range client.idle {
+		err = errors.Join(err, conn.Close())
+	}
+	client.idle = nil
+	return err
+}
+
+func alwaysRetry() bool { return true }
+
+func neverRetry() bool { return false }
+
+// call runs fn on a pooled connection, and runs it again on another connection, after a backoff, while the connection fails and canRetry allows it
+func (client *Client[Key]) call(ctx context.Context, canRetry func() bool, fn func(conn *conn) error) error {
+	for attempt := 1; ; attempt++ {
+		err := client.callOnce(ctx, fn)
+		if err == nil || ctx.Err() != nil || attempt > client.options.retries || !canRetry() || isServerError(err) || errors.Is(err, ErrClientClosed) {
+			return err
+		}
+		timer := time.NewTimer(time.Duration(attempt) * client.options.retryBackoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// callOnce runs fn on a pooled connection with the deadline of ctx, closing the connection if fn fails other than with an error of the server
+func (client *Client[Key]) callOnce(ctx context.Context, fn func(conn *conn) error) error {
+	conn, err := client.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	err = fn(conn)
+	interrupted := !stop()
+
+	if interrupted || (err != nil && !isServerError(err)) {
+		conn.broken = true
+	}
+	client.release(conn)
+	if interrupted || (err != nil && ctx.Err() != nil) {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (client *Client[Key]) acquire(ctx context.Context) (*conn, error) {
+	client.lock.Lock()
+	if client.closed {
+		client.lock.Unlock()
+		return nil, ErrClientClosed
+	}
+	if count := len(client.idle); count > 0 {
+		conn := client.idle[count-1]
+		client.idle = client.idle[:count-1]
+		client.lock.Unlock()
+		return conn, nil
+	}
+	client.lock.Unlock()
+
+	netConn, err := client.dialer.DialContext(ctx, "tcp", client.address)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: netConn, reader: bufio.NewReader(netConn), writer: bufio.NewWriter(netConn)}, nil
+}
+
+// release returns the connection to the pool, unless it is broken, the pool is full or the client is closed
+func (client *Client[Key]) release(conn *conn) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	if conn.broken || client.closed || len(client.idle) >= client.options.poolSize {
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	client.idle = append(client.idle, conn)
+}
+
+func (conn *conn) send(op byte, payload []byte) error {
+	if err := wire.WriteFrame(conn.writer, op, payload); err != nil {
+		return err
+	}
+	return conn.writer.Flush()
+}
+
+// roundTrip sends the request and reads its single response
+func (conn *conn) roundTrip(op byte, payload []byte) ([]byte, error) {
+	if err := conn.send(op, payload); err != nil {
+		return nil, err
+	}
+	response, err := wire.ReadFrame(conn.reader)
+	if err != nil {
+		return nil, err
+	}
+	switch response.Kind {
+	case rpc.StatusOK:
+		return response.Payload, nil
+	case rpc.StatusError:
+		return nil, rpc.DecodeError(response.Payload)
+	default:
+		return nil, fmt.Errorf("unexpected response %v", response.Kind)
+	}
+}
+
+func isServerError(err error) bool {
+	var serverErr *rpc.Error
+	return errors.As(err, &serverErr)
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/internal/rpc"
+	"ashishkujoy/bitcask/internal/wire"
+	"ashishkujoy/bitcask/kv"
+	"ashishkujoy/bitcask/remote"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingListener counts the accepted connections, and closes the first dropped ones right away
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int32
+	dropped  int32
+}
+
+func (listener *countingListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := listener.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if listener.accepted.Add(1) > listener.dropped {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
+
+func startServer(t *testing.T, dropped int32) (*Client[string], *countingListener) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testClient")
+	db, err := bitcask.Open[string](tempDir, bitcask.WithKeyCodec[string](config.StringKeyCodec[string]{}))
+	require.NoError(t, err)
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener := &countingListener{Listener: tcpListener, dropped: dropped}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- remote.NewServer(db).Serve(ctx, listener) }()
+
+	client := New[string](tcpListener.Addr().String(), config.StringKeyCodec[string]{}, WithRetries(2, time.Millisecond))
+	t.Cleanup(func() {
+		client.Close()
+		cancel()
+		require.ErrorIs(t, <-served, context.Canceled)
+		db.Shutdown()
+		os.RemoveAll(tempDir)
+	})
+	return client, listener
+}
+
+func TestPutGetDeleteAndPutAll(t *testing.T) {
+	client, listener := startServer(t, 0)
+	ctx := context.Background()
+
+	require.NoError(t, client.Put(ctx, "Topic", []byte("Microservices")))
+	value, err := client.Get(ctx, "Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Microservices", string(value))
+
+	require.NoError(t, client.Delete(ctx, "Topic"))
+	_, err = client.Get(ctx, "Topic")
+	require.ErrorIs(t, err, bitcask.ErrKeyNotFound)
+
+	require.NoError(t, client.PutAll(ctx, []kv.KeyValue[string]{
+		{Key: "Disk", Value: []byte("SSD")},
+		{Key: "Engine", Value: []byte("Bitcask")},
+	}))
+	value, err = client.Get(ctx, "Engine")
+	require.NoError(t, err)
+	require.Equal(t, "Bitcask", string(value))
+
+	require.ErrorIs(t, client.Put(ctx, "", []byte("empty")), bitcask.ErrEmptyKey)
+	require.Equal(t, int32(1), listener.accepted.Load())
+}
+
+func TestRequestsAreBoundedByTheMaximumRequestPayload(t *testing.T) {
+	client, listener := startServer(t, 0)
+	ctx := context.Background()
+
+	large := make([]byte, rpc.MaxRequestPayload)
+	require.ErrorIs(t, client.Put(ctx, "Topic", large), bitcask.ErrValueTooLarge)
+	require.Equal(t, int32(0), listener.accepted.Load(), "a request the server would not read is not sent")
+
+	half := make([]byte, rpc.MaxRequestPayload/2)
+	require.NoError(t, client.PutAll(ctx, []kv.KeyValue[string]{{Key: "Disk", Value: half}, {Key: "Engine", Value: half}}))
+	value, err := client.Get(ctx, "Engine")
+	require.NoError(t, err)
+	require.Equal(t, len(half), len(value))
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	header := wire.LittleEndian.AppendUint32([]byte{rpc.OpPut}, rpc.MaxRequestPayload+1)
+	_, err = conn.Write(header)
+	require.NoError(t, err)
+	_, err = conn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF, "the server closes a connection announcing a larger request")
+}
+
+func TestScanStreamsTheEntriesAndStopsEarly(t *testing.T) {
+	client, _ := startServer(t, 0)
+	ctx := context.Background()
+	for index := range 100 {
+		require.NoError(t, client.Put(ctx, fmt.Sprintf("user:%03d", index), []byte("value")))
+	}
+	require.NoError(t, client.Put(ctx, "order:1", []byte("value")))
+
+	var keys []string
+	require.NoError(t, client.Scan(ctx, []byte("user:"), func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	require.Len(t, keys, 100)
+	require.Equal(t, "user:099", keys[99])
+
+	keys = nil
+	require.NoError(t, client.Scan(ctx, nil, func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	}))
+	require.Equal(t, []string{"order:1", "user:000"}, keys)
+
+	require.NoError(t, client.Ping(ctx))
+}
+
+func TestReadsAreRetriedButWritesAreNot(t *testing.T) {
+	client, listener := startServer(t, 1)
+	ctx := context.Background()
+
+	require.Error(t, client.Put(ctx, "Topic", []byte("Microservices")))
+
+	listener.accepted.Store(0)
+	_, err := client.Get(ctx, "Topic")
+	require.ErrorIs(t, err, bitcask.ErrKeyNotFound)
+	require.Equal(t, int32(2), listener.accepted.Load())
+}
+
+func TestACallTimesOutWithItsContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		// accepts the connections and never answers
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := New[string](listener.Addr().String(), config.StringKeyCodec[string]{})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Get(ctx, "Topic")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCallsAfterClose(t *testing.T) {
+	client, _ := startServer(t, 0)
+	require.NoError(t, client.Close())
+	require.ErrorIs(t, client.Ping(context.Background()), ErrClientClosed)
+}
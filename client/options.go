@@ -0,0 +1,44 @@
+package client
+
+import "time"
+
+// Option changes a single setting of a Client
+type Option func(*options)
+
+type options struct {
+	poolSize     int
+	dialTimeout  time.Duration
+	retries      int
+	retryBackoff time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		poolSize:     8,
+		dialTimeout:  5 * time.Second,
+		retries:      2,
+		retryBackoff: 10 * time.Millisecond,
+	}
+}
+
+// WithPoolSize sets the number of idle connections kept for the calls to come
+func WithPoolSize(poolSize int) Option {
+	return func(options *options) {
+		options.poolSize = poolSize
+	}
+}
+
+// WithDialTimeout sets the time after which dialling a connection fails, which also applies when the context of the call has no deadline
+func WithDialTimeout(dialTimeout time.Duration) Option {
+	return func(options *options) {
+		options.dialTimeout = dialTimeout
+	}
+}
+
+// WithRetries sets the number of times a failed read is retried, on a new connection, waiting a backoff growing by retryBackoff before each retry
+func WithRetries(retries int, retryBackoff time.Duration) Option {
+	return func(options *options) {
+		options.retries = retries
+		options.retryBackoff = retryBackoff
+	}
+}
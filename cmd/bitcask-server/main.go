@@ -0,0 +1,67 @@
+// Command bitcask-server serves a bitcask directory over a subset of RESP, so that redis clients can use it.
+//
+// Usage:
+//
+//	bitcask-server -dir <directory> [-addr <address>] [-segment-size <bytes>]
+//
+// The server stops on SIGINT or SIGTERM.
+package main
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/server"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "bitcask-server:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("bitcask-server", flag.ContinueOnError)
+	directory := flags.String("dir", "", "bitcask directory")
+	address := flags.String("addr", "127.0.0.1:6380", "address to listen on")
+	maxSegmentSize := flags.Uint64("segment-size", 64*1024*1024, "maximum segment size in bytes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *directory == "" {
+		return errors.New("-dir is required")
+	}
+
+	db, err := bitcask.Open[config.BytesKey](
+		*directory,
+		bitcask.WithKeyCodec[config.BytesKey](config.BytesKeyCodec{}),
+		bitcask.WithMaxSegmentSize(*maxSegmentSize),
+	)
+	if err != nil {
+		return err
+	}
+	defer db.Shutdown()
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "bitcask-server: listening on", listener.Addr())
+
+	err = server.New(db).Serve(ctx, listener)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,362 @@
+// Command bitcask inspects and operates a bitcask directory without writing Go code.
+//
+// Usage:
+//
+//	bitcask -dir <directory> [-hex] [-key-file <file>] <command> [arguments]
+//
+// Commands:
+//
+//	get <key>                prints the value of the key
+//	put <key> <value>        puts the key and the value, a value of - is read from stdin
+//	delete <key>             deletes the key
+//	scan [--prefix <prefix>] prints the keys starting with the prefix, along with their values
+//	stats                    prints the number of segments, their bytes, the bytes of the live entries and the number of live keys
+//	dump-segment <file>      prints the entries decoded from a segment file
+//	merge                    merges the inactive segments
+//	verify                   checks that every entry of every segment decodes cleanly
+//	repair                   truncates torn segment tails and quarantines unreadable segments
+//
+// -hex treats keys as hex encoded raw bytes, and -key-file holds one "<key id> <hex encoded key>" per line, the last one being the current key.
+package main
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "bitcask:", err)
+		os.Exit(1)
+	}
+}
+
+// cli holds the global flags shared by all the commands
+type cli struct {
+	directory      string
+	hexKeys        bool
+	maxSegmentSize uint64
+	keyFile        string
+	stdin          io.Reader
+	stdout         io.Writer
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("bitcask", flag.ContinueOnError)
+	cli := &cli{stdin: stdin, stdout: stdout}
+	flags.StringVar(&cli.directory, "dir", "", "bitcask directory")
+	flags.BoolVar(&cli.hexKeys, "hex", false, "treat keys as hex encoded raw bytes")
+	flags.StringVar(&cli.keyFile, "key-file", "", "file of the encryption keys, one \"<key id> <hex encoded key>\" per line")
+	flags.Uint64Var(&cli.maxSegmentSize, "segment-size", 64*1024*1024, "maximum segment size in bytes, used by put, delete and merge")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("a command is required: get, put, delete, scan, stats, dump-segment, merge, verify or repair")
+	}
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	if command == "dump-segment" {
+		return cli.dumpSegment(commandArgs)
+	}
+	if cli.directory == "" {
+		return errors.New("-dir is required")
+	}
+
+	switch command {
+	case "get":
+		return cli.get(commandArgs)
+	case "put":
+		return cli.put(commandArgs)
+	case "delete":
+		return cli.delete(commandArgs)
+	case "scan":
+		return cli.scan(commandArgs)
+	case "stats":
+		return cli.stats()
+	case "merge":
+		return cli.merge()
+	case "verify":
+		return cli.verify()
+	case "repair":
+		return cli.repair()
+	default:
+		return fmt.Errorf("unknown command %v", command)
+	}
+}
+
+func (cli *cli) get(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: get <key>")
+	}
+	return cli.withReadOnlyDB(func(db *bitcask.DB[config.BytesKey]) error {
+		key, err := cli.parseKey(args[0])
+		if err != nil {
+			return err
+		}
+		value, err := db.Get(key)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cli.stdout, cli.formatValue(value))
+		return err
+	})
+}
+
+func (cli *cli) put(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: put <key> <value>")
+	}
+	value := []byte(args[1])
+	if args[1] == "-" {
+		var err error
+		if value, err = io.ReadAll(cli.stdin); err != nil {
+			return err
+		}
+	}
+	return cli.withDB(func(db *bitcask.DB[config.BytesKey]) error {
+		key, err := cli.parseKey(args[0])
+		if err != nil {
+			return err
+		}
+		if err := db.Put(key, value); err != nil {
+			return err
+		}
+		return db.Sync()
+	})
+}
+
+func (cli *cli) delete(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: delete <key>")
+	}
+	return cli.withDB(func(db *bitcask.DB[config.BytesKey]) error {
+		key, err := cli.parseKey(args[0])
+		if err != nil {
+			return err
+		}
+		if err := db.Delete(key); err != nil {
+			return err
+		}
+		return db.Sync()
+	})
+}
+
+func (cli *cli) scan(args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	prefix := flags.String("prefix", "", "prefix of the keys to scan")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	return cli.withReadOnlyDB(func(db *bitcask.DB[config.BytesKey]) error {
+		encodedPrefix, err := cli.parseKey(*prefix)
+		if err != nil {
+			return err
+		}
+		var writeErr error
+		err = db.Scan(encodedPrefix.Bytes(), func(key config.BytesKey, value []byte) bool {
+			_, writeErr = fmt.Fprintf(cli.stdout, "%v\t%v\n", cli.formatKey(key), cli.formatValue(value))
+			return writeErr == nil
+		})
+		if err != nil {
+			return err
+		}
+		return writeErr
+	})
+}
+
+func (cli *cli) stats() error {
+	return cli.withReadOnlyDB(func(db *bitcask.DB[config.BytesKey]) error {
+		stats, err := db.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cli.stdout, "segments\t%v\n", stats.Segments)
+		fmt.Fprintf(cli.stdout, "total_bytes\t%v\n", stats.TotalBytes)
+		fmt.Fprintf(cli.stdout, "live_bytes\t%v\n", stats.LiveBytes)
+		_, err = fmt.Fprintf(cli.stdout, "live_keys\t%v\n", stats.Keys)
+		return err
+	})
+}
+
+func (cli *cli) dumpSegment(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: dump-segment <file>")
+	}
+	keyProvider, err := cli.keyProvider()
+	if err != nil {
+		return err
+	}
+	records, err := kvlog.ReadRecords(args[0], keyProvider)
+	for _, record := range records {
+		fmt.Fprintf(
+			cli.stdout,
+			"offset=%v length=%v timestamp=%v deleted=%v key=%v value=%v\n",
+			record.Offset, record.Length, record.Timestamp, record.Deleted,
+			cli.formatKey(config.NewBytesKey(record.Key)), cli.formatValue(record.Value),
+		)
+	}
+	return err
+}
+
+func (cli *cli) merge() error {
+	return cli.withDB(func(db *bitcask.DB[config.BytesKey]) error {
+		return db.Merge()
+	})
+}
+
+func (cli *cli) verify() error {
+	keyProvider, err := cli.keyProvider()
+	if err != nil {
+		return err
+	}
+	report, err := kvlog.Verify(cli.directory, keyProvider)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, segment := range report.Segments {
+		if !segment.Healthy() {
+			failed++
+			fmt.Fprintf(cli.stdout, "%v\tfailed after %v entries: %v\n", segment.Path, segment.Entries, segment.Err)
+			continue
+		}
+		fmt.Fprintf(cli.stdout, "%v\tok, %v entries\n", segment.Path, segment.Entries)
+	}
+	for _, fileId := range report.DuplicateFileIds {
+		fmt.Fprintf(cli.stdout, "fileId %v is claimed by more than one segment file\n", fileId)
+	}
+	if !report.Healthy() {
+		return fmt.Errorf("%v of %v segments failed verification, %v duplicate fileIds", failed, len(report.Segments), len(report.DuplicateFileIds))
+	}
+	return nil
+}
+
+func (cli *cli) repair() error {
+	keyProvider, err := cli.keyProvider()
+	if err != nil {
+		return err
+	}
+	report, err := kvlog.Repair(cli.directory, keyProvider)
+	if report == nil {
+		return err
+	}
+	for _, segment := range report.Truncated {
+		fmt.Fprintf(cli.stdout, "%v\ttruncated to %v bytes, %v entries\n", segment.Path, segment.ValidSize, segment.Entries)
+	}
+	for _, segment := range report.Quarantined {
+		fmt.Fprintf(cli.stdout, "%v\tquarantined: %v\n", segment.Path, segment.Err)
+	}
+	return err
+}
+
+// withDB opens the database in the directory, runs fn and shuts the database down
+func (cli *cli) withDB(fn func(db *bitcask.DB[config.BytesKey]) error) error {
+	opts, err := cli.options()
+	if err != nil {
+		return err
+	}
+	db, err := bitcask.Open[config.BytesKey](cli.directory, append(opts, bitcask.WithMaxSegmentSize(cli.maxSegmentSize))...)
+	if err != nil {
+		return err
+	}
+	defer db.Shutdown()
+	return fn(db)
+}
+
+// withReadOnlyDB opens the database in the directory read-only, runs fn and shuts the database down
+func (cli *cli) withReadOnlyDB(fn func(db *bitcask.DB[config.BytesKey]) error) error {
+	opts, err := cli.options()
+	if err != nil {
+		return err
+	}
+	db, err := bitcask.OpenReadOnly[config.BytesKey](cli.directory, opts...)
+	if err != nil {
+		return err
+	}
+	defer db.Shutdown()
+	return fn(db)
+}
+
+// options returns the options shared by every command which opens the database
+func (cli *cli) options() ([]bitcask.Option, error) {
+	opts := []bitcask.Option{bitcask.WithKeyCodec[config.BytesKey](config.BytesKeyCodec{})}
+	keyProvider, err := cli.keyProvider()
+	if err != nil {
+		return nil, err
+	}
+	if keyProvider != nil {
+		opts = append(opts, bitcask.WithKeyProvider(keyProvider))
+	}
+	return opts, nil
+}
+
+// keyProvider reads the encryption keys from -key-file. It returns nil if no key file is given.
+func (cli *cli) keyProvider() (config.KeyProvider, error) {
+	if cli.keyFile == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(cli.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[uint32][]byte)
+	var currentKeyId uint32
+	for lineNumber, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%v:%v: expected <key id> <hex encoded key>", cli.keyFile, lineNumber+1)
+		}
+		keyId, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: key id %v: %w", cli.keyFile, lineNumber+1, fields[0], err)
+		}
+		key, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: key is not hex encoded: %w", cli.keyFile, lineNumber+1, err)
+		}
+		keys[uint32(keyId)] = key
+		currentKeyId = uint32(keyId)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%v has no keys", cli.keyFile)
+	}
+	return config.NewStaticKeyProvider(keys, currentKeyId), nil
+}
+
+func (cli *cli) parseKey(key string) (config.BytesKey, error) {
+	if !cli.hexKeys {
+		return config.BytesKey(key), nil
+	}
+	decoded, err := hex.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("key %v is not hex encoded: %w", key, err)
+	}
+	return config.NewBytesKey(decoded), nil
+}
+
+func (cli *cli) formatKey(key config.BytesKey) string {
+	if cli.hexKeys {
+		return hex.EncodeToString(key.Bytes())
+	}
+	return string(key)
+}
+
+func (cli *cli) formatValue(value []byte) string {
+	if cli.hexKeys {
+		return hex.EncodeToString(value)
+	}
+	return string(value)
+}
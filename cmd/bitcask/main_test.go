@@ -0,0 +1,122 @@
+package main
+
+import (
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"bytes"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runCommand(t *testing.T, args ...string) string {
+	var stdout bytes.Buffer
+	require.NoError(t, run(args, strings.NewReader("from stdin"), &stdout))
+	return stdout.String()
+}
+
+func TestPutGetAndDelete(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testCliPutGetDelete")
+	defer os.RemoveAll(tempDir)
+
+	runCommand(t, "-dir", tempDir, "put", "topic", "microservices")
+	require.Equal(t, "microservices\n", runCommand(t, "-dir", tempDir, "get", "topic"))
+
+	runCommand(t, "-dir", tempDir, "put", "disk", "-")
+	require.Equal(t, "from stdin\n", runCommand(t, "-dir", tempDir, "get", "disk"))
+
+	runCommand(t, "-dir", tempDir, "delete", "topic")
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"-dir", tempDir, "get", "topic"}, nil, &stdout))
+}
+
+func TestScanWithPrefixAndHexKeys(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testCliScan")
+	defer os.RemoveAll(tempDir)
+
+	runCommand(t, "-dir", tempDir, "put", "user:1", "alice")
+	runCommand(t, "-dir", tempDir, "put", "user:2", "bob")
+	runCommand(t, "-dir", tempDir, "put", "topic", "bitcask")
+
+	require.Equal(t, "user:1\talice\nuser:2\tbob\n", runCommand(t, "-dir", tempDir, "scan", "--prefix", "user:"))
+	require.Equal(t, "757365723a31\t616c696365\n757365723a32\t626f62\n", runCommand(t, "-dir", tempDir, "-hex", "scan", "--prefix", "7573"))
+}
+
+func TestStatsDumpSegmentAndVerify(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testCliStats")
+	defer os.RemoveAll(tempDir)
+
+	runCommand(t, "-dir", tempDir, "put", "topic", "microservices")
+	runCommand(t, "-dir", tempDir, "put", "disk", "ssd")
+
+	segmentFiles, _ := kvlog.ListSegmentFiles(tempDir)
+	stats := runCommand(t, "-dir", tempDir, "stats")
+	require.Contains(t, stats, "segments\t"+strconv.Itoa(len(segmentFiles))+"\n")
+	require.Contains(t, stats, "live_keys\t2\n")
+
+	dump := runCommand(t, "dump-segment", segmentFiles[0].Path)
+	require.Contains(t, dump, "key=topic value=microservices")
+
+	require.Contains(t, runCommand(t, "-dir", tempDir, "verify"), "ok, 1 entries")
+}
+
+func TestVerifyACorruptedSegment(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testCliVerifyCorrupted")
+	defer os.RemoveAll(tempDir)
+
+	runCommand(t, "-dir", tempDir, "put", "topic", "microservices")
+	segmentFiles, _ := kvlog.ListSegmentFiles(tempDir)
+	os.Truncate(segmentFiles[0].Path, segmentFiles[0].Size-3)
+
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"-dir", tempDir, "verify"}, nil, &stdout))
+	require.Contains(t, stdout.String(), "failed after 0 entries")
+}
+
+func TestRepairATornSegmentTail(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testCliRepair")
+	defer os.RemoveAll(tempDir)
+
+	runCommand(t, "-dir", tempDir, "put", "topic", "microservices")
+	runCommand(t, "-dir", tempDir, "put", "disk", "ssd")
+	segmentFiles, _ := kvlog.ListSegmentFiles(tempDir)
+	os.Truncate(segmentFiles[1].Path, segmentFiles[1].Size-3)
+
+	require.Contains(t, runCommand(t, "-dir", tempDir, "repair"), "truncated to 0 bytes, 0 entries")
+	runCommand(t, "-dir", tempDir, "verify")
+	require.Equal(t, "microservices\n", runCommand(t, "-dir", tempDir, "get", "topic"))
+}
+
+func TestReadCommandsDoNotChangeTheDirectory(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testCliReadOnly")
+	defer os.RemoveAll(tempDir)
+	runCommand(t, "-dir", tempDir, "put", "topic", "microservices")
+	segmentFiles, _ := kvlog.ListSegmentFiles(tempDir)
+
+	runCommand(t, "-dir", tempDir, "get", "topic")
+	runCommand(t, "-dir", tempDir, "scan")
+	runCommand(t, "-dir", tempDir, "stats")
+
+	afterReads, _ := kvlog.ListSegmentFiles(tempDir)
+	require.Equal(t, segmentFiles, afterReads)
+}
+
+func TestCommandsOnAnEncryptedDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	keyFile := path.Join(t.TempDir(), "keys")
+	os.WriteFile(keyFile, []byte("1 000102030405060708090a0b0c0d0e0f\n"), 0600)
+
+	runCommand(t, "-dir", tempDir, "-key-file", keyFile, "put", "topic", "microservices")
+	require.Equal(t, "microservices\n", runCommand(t, "-dir", tempDir, "-key-file", keyFile, "get", "topic"))
+
+	segmentFiles, _ := kvlog.ListSegmentFiles(tempDir)
+	dump := runCommand(t, "-key-file", keyFile, "dump-segment", segmentFiles[0].Path)
+	require.Contains(t, dump, "key=topic value=microservices")
+	require.Contains(t, runCommand(t, "-dir", tempDir, "-key-file", keyFile, "verify"), "ok, 1 entries")
+
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"dump-segment", segmentFiles[0].Path}, nil, &stdout))
+}
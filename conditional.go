@@ -0,0 +1,36 @@
+package bitcask
+
+import "ashishkujoy/bitcask/kv"
+
+// Version identifies a write of a key, see kv.Version
+type Version = kv.Version
+
+// CompareAndSwap puts value as the value of the key if its current value is equal to expected, and returns true if it did. A missing key never matches.
+func (db *DB[Key]) CompareAndSwap(key Key, expected []byte, value []byte) (bool, error) {
+	return db.kvStore.CompareAndSwap(key, expected, value)
+}
+
+// PutIfAbsent puts the key value pair if the key is missing, and returns true if it did
+func (db *DB[Key]) PutIfAbsent(key Key, value []byte) (bool, error) {
+	return db.kvStore.PutIfAbsent(key, value)
+}
+
+// DeleteIfEquals deletes the key if its current value is equal to expected, and returns true if it did. A missing key never matches.
+func (db *DB[Key]) DeleteIfEquals(key Key, expected []byte) (bool, error) {
+	return db.kvStore.DeleteIfEquals(key, expected)
+}
+
+// GetVersioned gets the value of the key along with its version. A missing key is reported as ErrKeyNotFound.
+func (db *DB[Key]) GetVersioned(key Key) ([]byte, Version, error) {
+	return db.kvStore.GetVersioned(key)
+}
+
+// CompareVersionAndSwap puts value as the value of the key if the key was not written since GetVersioned returned version, and returns true if it did
+func (db *DB[Key]) CompareVersionAndSwap(key Key, version Version, value []byte) (bool, error) {
+	return db.kvStore.CompareVersionAndSwap(key, version, value)
+}
+
+// DeleteIfVersion deletes the key if it was not written since GetVersioned returned version, and returns true if it did
+func (db *DB[Key]) DeleteIfVersion(key Key, version Version) (bool, error) {
+	return db.kvStore.DeleteIfVersion(key, version)
+}
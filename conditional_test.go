@@ -0,0 +1,119 @@
+package bitcask
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalWrites(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testConditionalWrites")
+	defer os.RemoveAll(tempDir)
+	db := openWithSegmentSize(t, tempDir, 1024)
+	defer db.Shutdown()
+
+	put, err := db.PutIfAbsent("Topic", []byte("Databases"))
+	require.NoError(t, err)
+	require.True(t, put)
+	put, err = db.PutIfAbsent("Topic", []byte("Microservices"))
+	require.NoError(t, err)
+	require.False(t, put)
+
+	swapped, err := db.CompareAndSwap("Topic", []byte("Microservices"), []byte("Networks"))
+	require.NoError(t, err)
+	require.False(t, swapped)
+	swapped, err = db.CompareAndSwap("Topic", []byte("Databases"), []byte("Networks"))
+	require.NoError(t, err)
+	require.True(t, swapped)
+	swapped, err = db.CompareAndSwap("Disk", nil, []byte("SSD"))
+	require.NoError(t, err)
+	require.False(t, swapped)
+
+	deleted, err := db.DeleteIfEquals("Topic", []byte("Databases"))
+	require.NoError(t, err)
+	require.False(t, deleted)
+	deleted, err = db.DeleteIfEquals("Topic", []byte("Networks"))
+	require.NoError(t, err)
+	require.True(t, deleted)
+	_, err = db.Get("Topic")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestVersionedWritesAcrossMergeAndReopen(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testVersionedWrites")
+	defer os.RemoveAll(tempDir)
+	db := openWithSegmentSize(t, tempDir, 16)
+
+	require.NoError(t, db.Put("Topic", []byte("Databases")))
+	_, version, err := db.GetVersioned("Topic")
+	require.NoError(t, err)
+
+	require.NoError(t, db.Put("Topic", []byte("Databases")))
+	swapped, err := db.CompareVersionAndSwap("Topic", version, []byte("Microservices"))
+	require.NoError(t, err)
+	require.False(t, swapped, "a key written again with the same value is at a new version")
+
+	_, version, err = db.GetVersioned("Topic")
+	require.NoError(t, err)
+	for _, disk := range []string{"HDD", "SSD", "NVMe"} {
+		require.NoError(t, db.Put("Disk", []byte(disk)))
+	}
+	require.NoError(t, db.Merge())
+	swapped, err = db.CompareVersionAndSwap("Topic", version, []byte("Microservices"))
+	require.NoError(t, err)
+	require.True(t, swapped, "merge keeps the version of a key")
+
+	_, version, err = db.GetVersioned("Topic")
+	require.NoError(t, err)
+	db.Shutdown()
+
+	db = openWithSegmentSize(t, tempDir, 16)
+	defer db.Shutdown()
+	deleted, err := db.DeleteIfVersion("Topic", version)
+	require.NoError(t, err)
+	require.False(t, deleted, "versions do not survive reopening")
+
+	value, reloadedVersion, err := db.GetVersioned("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Microservices", string(value))
+	deleted, err = db.DeleteIfVersion("Topic", reloadedVersion)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	_, _, err = db.GetVersioned("Topic")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestCompareAndSwapIsAtomic(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testCompareAndSwapIsAtomic")
+	defer os.RemoveAll(tempDir)
+	db := openWithSegmentSize(t, tempDir, 1024)
+	defer db.Shutdown()
+	require.NoError(t, db.Put("Counter", []byte("0")))
+
+	var wait sync.WaitGroup
+	for range 8 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for increments := 0; increments < 25; {
+				value, err := db.Get("Counter")
+				require.NoError(t, err)
+				count, _ := strconv.Atoi(string(value))
+				swapped, err := db.CompareAndSwap("Counter", value, []byte(strconv.Itoa(count+1)))
+				require.NoError(t, err)
+				if swapped {
+					increments++
+				}
+			}
+		}()
+	}
+	wait.Wait()
+
+	value, err := db.Get("Counter")
+	require.NoError(t, err)
+	require.Equal(t, "200", string(value))
+}
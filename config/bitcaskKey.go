@@ -4,7 +4,13 @@ type Serializable interface {
 	Serialize() []byte
 }
 
+// BitcaskKey is the constraint for the keys of a database. Keys are converted to and from bytes by a KeyCodec.
 type BitcaskKey interface {
 	comparable
+}
+
+// SerializableKey is a key which serializes itself, as taken by the positional constructors
+type SerializableKey interface {
+	BitcaskKey
 	Serializable
 }
@@ -0,0 +1,11 @@
+package config
+
+// Compression identifies the codec used to compress the value of an entry, which is recorded per entry
+type Compression byte
+
+const (
+	// NoCompression stores values as they are
+	NoCompression Compression = iota
+	// GzipCompression compresses values using gzip from the standard library
+	GzipCompression
+)
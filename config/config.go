@@ -1,27 +1,68 @@
 package config
 
-import "ashishkujoy/bitcask/clock"
+import (
+	"ashishkujoy/bitcask/clock"
+	"log/slog"
+)
 
 type Config[Key BitcaskKey] struct {
 	directory           string
 	maxSegmentSizeBytes uint64
 	mergeConfig         *MergeConfig[Key]
 	clock               clock.Clock
+	compression         Compression
+	keyProvider         KeyProvider
+	maxKeySize          uint32
+	maxValueSize        uint32
+	syncPolicy          SyncPolicy
+	keyCodec            KeyCodec[Key]
+	logger              *slog.Logger
+	onError             func(error)
+	listener            Listener
+	readOnly            bool
 }
 
-func NewConfig[Key BitcaskKey](directory string, maxSegmentSizeBytes uint64, mergeConfig *MergeConfig[Key]) *Config[Key] {
+// NewConfig creates a configuration for keys which serialize themselves. Keys are decoded using the keyMapper of mergeConfig.
+func NewConfig[Key SerializableKey](directory string, maxSegmentSizeBytes uint64, mergeConfig *MergeConfig[Key]) *Config[Key] {
 	return NewConfigWithClock[Key](directory, maxSegmentSizeBytes, mergeConfig, clock.NewSystemClock())
 }
 
-func NewConfigWithClock[Key BitcaskKey](directory string, maxSegmentSizeBytes uint64, mergeConfig *MergeConfig[Key], clock clock.Clock) *Config[Key] {
+func NewConfigWithClock[Key SerializableKey](directory string, maxSegmentSizeBytes uint64, mergeConfig *MergeConfig[Key], clock clock.Clock) *Config[Key] {
+	keyCodec := serializableKeyCodec[Key]{}
+	if mergeConfig != nil {
+		keyCodec.keyMapper = mergeConfig.KeyMapper()
+	}
 	return &Config[Key]{
 		directory:           directory,
 		maxSegmentSizeBytes: maxSegmentSizeBytes,
 		mergeConfig:         mergeConfig,
 		clock:               clock,
+		keyCodec:            keyCodec,
 	}
 }
 
+// NewConfigWithCompression creates a configuration which compresses every value using the given codec before it is appended to a segment
+func NewConfigWithCompression[Key SerializableKey](directory string, maxSegmentSizeBytes uint64, mergeConfig *MergeConfig[Key], compression Compression) *Config[Key] {
+	config := NewConfig[Key](directory, maxSegmentSizeBytes, mergeConfig)
+	config.compression = compression
+	return config
+}
+
+// NewConfigWithKeyProvider creates a configuration which encrypts values at rest using AES-GCM with the keys supplied by keyProvider
+func NewConfigWithKeyProvider[Key SerializableKey](directory string, maxSegmentSizeBytes uint64, mergeConfig *MergeConfig[Key], keyProvider KeyProvider) *Config[Key] {
+	config := NewConfig[Key](directory, maxSegmentSizeBytes, mergeConfig)
+	config.keyProvider = keyProvider
+	return config
+}
+
+// NewConfigWithSizeLimits creates a configuration which rejects keys longer than maxKeySize bytes and values longer than maxValueSize bytes, 0 meaning unbounded
+func NewConfigWithSizeLimits[Key SerializableKey](directory string, maxSegmentSizeBytes uint64, mergeConfig *MergeConfig[Key], maxKeySize uint32, maxValueSize uint32) *Config[Key] {
+	config := NewConfig[Key](directory, maxSegmentSizeBytes, mergeConfig)
+	config.maxKeySize = maxKeySize
+	config.maxValueSize = maxValueSize
+	return config
+}
+
 func (config *Config[Key]) Directory() string {
 	return config.directory
 }
@@ -37,3 +78,54 @@ func (config *Config[Key]) Clock() clock.Clock {
 func (config *Config[Key]) MergeConfig() *MergeConfig[Key] {
 	return config.mergeConfig
 }
+
+func (config *Config[Key]) Compression() Compression {
+	return config.compression
+}
+
+func (config *Config[Key]) KeyProvider() KeyProvider {
+	return config.keyProvider
+}
+
+func (config *Config[Key]) MaxKeySize() uint32 {
+	return config.maxKeySize
+}
+
+func (config *Config[Key]) MaxValueSize() uint32 {
+	return config.maxValueSize
+}
+
+func (config *Config[Key]) SyncPolicy() SyncPolicy {
+	return config.syncPolicy
+}
+
+// KeyCodec returns the codec set with WithKeyCodec, or pairs Serialize with the keyMapper of the MergeConfig
+func (config *Config[Key]) KeyCodec() KeyCodec[Key] {
+	return config.keyCodec
+}
+
+// Logger returns the logger set with WithLogger, or a logger discarding everything
+func (config *Config[Key]) Logger() *slog.Logger {
+	if config.logger == nil {
+		return DiscardLogger
+	}
+	return config.logger
+}
+
+// OnError returns the callback set with WithOnError, which is nil if none is set
+func (config *Config[Key]) OnError() func(error) {
+	return config.onError
+}
+
+// Listener returns the listener set with WithListener, or a NoopListener
+func (config *Config[Key]) Listener() Listener {
+	if config.listener == nil {
+		return NoopListener{}
+	}
+	return config.listener
+}
+
+// ReadOnly returns true if the configuration was created with WithReadOnly
+func (config *Config[Key]) ReadOnly() bool {
+	return config.readOnly
+}
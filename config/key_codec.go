@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// KeyCodec converts keys to and from the bytes stored in segments and in the KeyDirectory, which orders keys by those bytes
+type KeyCodec[Key any] interface {
+	// Encode returns the bytes of the key as they are stored in a segment
+	Encode(key Key) []byte
+	// Decode reverses Encode
+	Decode(encoded []byte) (Key, error)
+}
+
+// StringKeyCodec stores string keys as their bytes
+type StringKeyCodec[Key ~string] struct{}
+
+func (codec StringKeyCodec[Key]) Encode(key Key) []byte {
+	return []byte(key)
+}
+
+func (codec StringKeyCodec[Key]) Decode(encoded []byte) (Key, error) {
+	return Key(encoded), nil
+}
+
+// BytesKey is a key made of raw bytes. A []byte can not be a key because keys must be comparable, so the bytes are held in a string.
+type BytesKey string
+
+// NewBytesKey creates a BytesKey holding a copy of the bytes
+func NewBytesKey(key []byte) BytesKey {
+	return BytesKey(key)
+}
+
+// Bytes returns a copy of the bytes of the key
+func (key BytesKey) Bytes() []byte {
+	return []byte(key)
+}
+
+// BytesKeyCodec stores a BytesKey as its bytes
+type BytesKeyCodec = StringKeyCodec[BytesKey]
+
+var errInvalidIntegerKeyLength = errors.New("integer key must be 8 bytes")
+
+// Uint64KeyCodec stores unsigned integer keys as 8 big-endian bytes, so the encoded keys sort in numeric order
+type Uint64KeyCodec[Key ~uint64] struct{}
+
+func (codec Uint64KeyCodec[Key]) Encode(key Key) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(key))
+}
+
+func (codec Uint64KeyCodec[Key]) Decode(encoded []byte) (Key, error) {
+	if len(encoded) != 8 {
+		return 0, fmt.Errorf("%w, got %v bytes", errInvalidIntegerKeyLength, len(encoded))
+	}
+	return Key(binary.BigEndian.Uint64(encoded)), nil
+}
+
+// Int64KeyCodec stores signed integer keys as 8 big-endian bytes with the sign bit flipped, so negative keys sort before positive keys
+type Int64KeyCodec[Key ~int64] struct{}
+
+func (codec Int64KeyCodec[Key]) Encode(key Key) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(key)^(1<<63))
+}
+
+func (codec Int64KeyCodec[Key]) Decode(encoded []byte) (Key, error) {
+	if len(encoded) != 8 {
+		return 0, fmt.Errorf("%w, got %v bytes", errInvalidIntegerKeyLength, len(encoded))
+	}
+	return Key(int64(binary.BigEndian.Uint64(encoded) ^ (1 << 63))), nil
+}
+
+// Pair is a composite key made of two keys. Pairs nest, so Pair[A, Pair[B, C]] is a key of three parts.
+type Pair[First comparable, Second comparable] struct {
+	First  First
+	Second Second
+}
+
+// PairKeyCodec stores a Pair as the first part, with 0x00 escaped as 0x00 0xFF and ending with 0x00 0x01, followed by the second part, so pairs sort by part
+type PairKeyCodec[First comparable, Second comparable] struct {
+	first  KeyCodec[First]
+	second KeyCodec[Second]
+}
+
+// NewPairKeyCodec creates a PairKeyCodec from the codecs of the two parts
+func NewPairKeyCodec[First comparable, Second comparable](first KeyCodec[First], second KeyCodec[Second]) *PairKeyCodec[First, Second] {
+	return &PairKeyCodec[First, Second]{first: first, second: second}
+}
+
+var (
+	pairEscape     = []byte{0x00, 0xFF}
+	pairTerminator = []byte{0x00, 0x01}
+)
+
+func (codec *PairKeyCodec[First, Second]) Encode(key Pair[First, Second]) []byte {
+	first := codec.first.Encode(key.First)
+	second := codec.second.Encode(key.Second)
+
+	encoded := make([]byte, 0, len(first)+len(pairTerminator)+len(second))
+	for _, b := range first {
+		if b == 0x00 {
+			encoded = append(encoded, pairEscape...)
+		} else {
+			encoded = append(encoded, b)
+		}
+	}
+	encoded = append(encoded, pairTerminator...)
+	return append(encoded, second...)
+}
+
+func (codec *PairKeyCodec[First, Second]) Decode(encoded []byte) (Pair[First, Second], error) {
+	var pair Pair[First, Second]
+	first := make([]byte, 0, len(encoded))
+	index := 0
+	for {
+		if index >= len(encoded) {
+			return pair, errors.New("pair key is missing the terminator of its first part")
+		}
+		if encoded[index] != 0x00 {
+			first = append(first, encoded[index])
+			index++
+			continue
+		}
+		if index+1 >= len(encoded) {
+			return pair, errors.New("pair key ends in the middle of an escape sequence")
+		}
+		if bytes.Equal(encoded[index:index+2], pairTerminator) {
+			index += len(pairTerminator)
+			break
+		}
+		if !bytes.Equal(encoded[index:index+2], pairEscape) {
+			return pair, fmt.Errorf("pair key has an invalid escape sequence at byte %v", index)
+		}
+		first = append(first, 0x00)
+		index += len(pairEscape)
+	}
+
+	var err error
+	if pair.First, err = codec.first.Decode(first); err != nil {
+		return pair, err
+	}
+	if pair.Second, err = codec.second.Decode(encoded[index:]); err != nil {
+		return pair, err
+	}
+	return pair, nil
+}
+
+// serializableKeyCodec pairs Serialize with a keyMapper, as done by the positional constructors
+type serializableKeyCodec[Key SerializableKey] struct {
+	keyMapper func([]byte) Key
+}
+
+func (codec serializableKeyCodec[Key]) Encode(key Key) []byte {
+	return key.Serialize()
+}
+
+func (codec serializableKeyCodec[Key]) Decode(encoded []byte) (Key, error) {
+	if codec.keyMapper == nil {
+		var key Key
+		return key, errors.New("no keyMapper is configured to decode keys")
+	}
+	return codec.keyMapper(encoded), nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringAndBytesKeyCodecs(t *testing.T) {
+	stringCodec := StringKeyCodec[string]{}
+	key, err := stringCodec.Decode(stringCodec.Encode("topic"))
+	require.NoError(t, err)
+	require.Equal(t, "topic", key)
+
+	bytesCodec := BytesKeyCodec{}
+	bytesKey, err := bytesCodec.Decode(bytesCodec.Encode(NewBytesKey([]byte{0x00, 0xFF, 0x10})))
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0xFF, 0x10}, bytesKey.Bytes())
+}
+
+func TestInt64KeyCodecPreservesOrder(t *testing.T) {
+	codec := Int64KeyCodec[int64]{}
+	keys := []int64{-1 << 63, -100, -1, 0, 1, 100, 1<<63 - 1}
+
+	for index := 1; index < len(keys); index++ {
+		require.Equal(t, -1, bytes.Compare(codec.Encode(keys[index-1]), codec.Encode(keys[index])))
+	}
+	for _, key := range keys {
+		decoded, err := codec.Decode(codec.Encode(key))
+		require.NoError(t, err)
+		require.Equal(t, key, decoded)
+	}
+}
+
+func TestUint64KeyCodecPreservesOrder(t *testing.T) {
+	codec := Uint64KeyCodec[uint64]{}
+	keys := []uint64{0, 1, 255, 256, 1<<64 - 1}
+
+	for index := 1; index < len(keys); index++ {
+		require.Equal(t, -1, bytes.Compare(codec.Encode(keys[index-1]), codec.Encode(keys[index])))
+	}
+	decoded, err := codec.Decode(codec.Encode(256))
+	require.NoError(t, err)
+	require.Equal(t, uint64(256), decoded)
+
+	_, err = codec.Decode([]byte{1, 2})
+	require.Error(t, err)
+}
+
+func TestPairKeyCodec(t *testing.T) {
+	codec := NewPairKeyCodec[string, int64](StringKeyCodec[string]{}, Int64KeyCodec[int64]{})
+	pairs := []Pair[string, int64]{
+		{First: "user", Second: -5},
+		{First: "user", Second: 5},
+		{First: "user\x00", Second: -5},
+		{First: "user\x00\x01", Second: 0},
+		{First: "users", Second: 0},
+	}
+
+	encoded := make([][]byte, len(pairs))
+	for index, pair := range pairs {
+		encoded[index] = codec.Encode(pair)
+		decoded, err := codec.Decode(encoded[index])
+		require.NoError(t, err)
+		require.Equal(t, pair, decoded)
+	}
+	require.True(t, slices.IsSortedFunc(encoded, bytes.Compare))
+
+	_, err := codec.Decode([]byte("user"))
+	require.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// KeyProvider supplies the AES keys used to encrypt values at rest, new segments being written with the current key
+type KeyProvider interface {
+	// CurrentKeyId returns the id of the key used to encrypt new segments
+	CurrentKeyId() uint32
+	// Key returns the AES-128, AES-192 or AES-256 key identified by keyId
+	Key(keyId uint32) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider backed by an in-memory set of keys
+type StaticKeyProvider struct {
+	keys         map[uint32][]byte
+	currentKeyId atomic.Uint32
+}
+
+// NewStaticKeyProvider creates a StaticKeyProvider which encrypts new segments with the key identified by currentKeyId
+func NewStaticKeyProvider(keys map[uint32][]byte, currentKeyId uint32) *StaticKeyProvider {
+	provider := &StaticKeyProvider{keys: keys}
+	provider.currentKeyId.Store(currentKeyId)
+	return provider
+}
+
+func (provider *StaticKeyProvider) CurrentKeyId() uint32 {
+	return provider.currentKeyId.Load()
+}
+
+// Rotate makes keyId the current key. Segments created from now on, including the ones written by merge, are encrypted with it.
+func (provider *StaticKeyProvider) Rotate(keyId uint32) {
+	provider.currentKeyId.Store(keyId)
+}
+
+func (provider *StaticKeyProvider) Key(keyId uint32) ([]byte, error) {
+	key, ok := provider.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("no encryption key with id %v", keyId)
+	}
+	return key, nil
+}
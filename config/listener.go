@@ -0,0 +1,72 @@
+package config
+
+import "time"
+
+// Listener is told about the lifecycle events of a database. Its callbacks, like the WithOnError one, may run while the store is locked, so they must not call the database.
+type Listener interface {
+	// OnSegmentRolledOver is called when the active segment becomes inactive and a new active segment takes its place
+	OnSegmentRolledOver(event SegmentRolledOver)
+	// OnSegmentCreated is called for every new segment file, whether it is a new active segment or a segment written by merge
+	OnSegmentCreated(event SegmentCreated)
+	// OnSegmentRemoved is called when the file of a segment is removed, which for a segment pinned by a backup happens once it is unpinned
+	OnSegmentRemoved(event SegmentRemoved)
+	// OnMergeStarted is called when a merge starts, once the running merge, if any, has finished
+	OnMergeStarted(event MergeStarted)
+	// OnMergeFinished is called when a merge ends, whether it merged segments, found too few of them or failed
+	OnMergeFinished(event MergeFinished)
+	// OnReloadFinished is called once the keys of the existing segments are reloaded, when the store is opened
+	OnReloadFinished(event ReloadFinished)
+	// OnCorruptionDetected is called when an entry read from a segment is corrupted
+	OnCorruptionDetected(event CorruptionDetected)
+}
+
+// SegmentRolledOver describes the rollover of the active segment
+type SegmentRolledOver struct {
+	FileId     uint64 // the segment which became inactive
+	NextFileId uint64 // the new active segment
+	Bytes      int64  // the size of the segment which became inactive
+}
+
+// SegmentCreated describes a new segment file
+type SegmentCreated struct {
+	FileId uint64
+}
+
+// SegmentRemoved describes a removed segment file
+type SegmentRemoved struct {
+	FileId uint64
+}
+
+// MergeStarted describes a merge which started
+type MergeStarted struct{}
+
+// MergeFinished describes a merge which ended, with Err set if it failed
+type MergeFinished struct {
+	MergedSegments int
+	Duration       time.Duration
+	Err            error
+}
+
+// ReloadFinished describes the reload of the existing segments
+type ReloadFinished struct {
+	Segments int
+	Keys     int
+	Duration time.Duration
+}
+
+// CorruptionDetected describes a corrupted entry, Err tells where it is and what is wrong with it
+type CorruptionDetected struct {
+	FileId uint64
+	Err    error
+}
+
+// NoopListener ignores every event, it is the listener used when none is set with WithListener
+type NoopListener struct{}
+
+func (NoopListener) OnSegmentRolledOver(SegmentRolledOver)   {}
+func (NoopListener) OnSegmentCreated(SegmentCreated)         {}
+func (NoopListener) OnSegmentRemoved(SegmentRemoved)         {}
+func (NoopListener) OnMergeStarted(MergeStarted)             {}
+func (NoopListener) OnMergeFinished(MergeFinished)           {}
+func (NoopListener) OnReloadFinished(ReloadFinished)         {}
+func (NoopListener) OnCorruptionDetected(CorruptionDetected) {}
@@ -0,0 +1,16 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+)
+
+// DiscardLogger is the logger used when none is set with WithLogger. It discards every record without formatting it.
+var DiscardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool   { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error  { return nil }
+func (handler discardHandler) WithAttrs([]slog.Attr) slog.Handler { return handler }
+func (handler discardHandler) WithGroup(string) slog.Handler      { return handler }
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"ashishkujoy/bitcask/clock"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultMaxSegmentSizeBytes = 64 * 1024 * 1024
+	defaultMergeInterval       = 5 * time.Minute
+)
+
+// Options collects the settings applied by Option functions, the others keeping their defaults (64MiB segments, a merge every 5 minutes)
+type Options struct {
+	maxSegmentSizeBytes uint64
+	mergeInterval       time.Duration
+	mergeSegmentsToRead int
+	clock               clock.Clock
+	syncPolicy          SyncPolicy
+	keyCodec            any
+	compression         Compression
+	keyProvider         KeyProvider
+	maxKeySize          uint32
+	maxValueSize        uint32
+	logger              *slog.Logger
+	onError             func(error)
+	listener            Listener
+	readOnly            bool
+}
+
+// Option changes a single setting of the configuration created by New
+type Option func(*Options)
+
+// WithMaxSegmentSize sets the size in bytes beyond which the active segment is rolled over
+func WithMaxSegmentSize(maxSegmentSizeBytes uint64) Option {
+	return func(options *Options) {
+		options.maxSegmentSizeBytes = maxSegmentSizeBytes
+	}
+}
+
+// WithMergeInterval sets the duration between two runs of merge
+func WithMergeInterval(interval time.Duration) Option {
+	return func(options *Options) {
+		options.mergeInterval = interval
+	}
+}
+
+// WithMergeSegmentsToRead limits the number of inactive segments read by a single run of merge. By default, all the inactive segments are read.
+func WithMergeSegmentsToRead(totalSegmentsToRead int) Option {
+	return func(options *Options) {
+		options.mergeSegmentsToRead = totalSegmentsToRead
+	}
+}
+
+// WithClock sets the clock used to generate file ids and entry timestamps
+func WithClock(clock clock.Clock) Option {
+	return func(options *Options) {
+		options.clock = clock
+	}
+}
+
+// WithSyncPolicy sets when appended entries are flushed to the disk
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(options *Options) {
+		options.syncPolicy = policy
+	}
+}
+
+// WithKeyCodec sets the codec used to convert keys to and from bytes. It is required, and must be a codec for the key type of the configuration.
+func WithKeyCodec[Key BitcaskKey](codec KeyCodec[Key]) Option {
+	return func(options *Options) {
+		options.keyCodec = codec
+	}
+}
+
+// WithCompression sets the codec used to compress values
+func WithCompression(compression Compression) Option {
+	return func(options *Options) {
+		options.compression = compression
+	}
+}
+
+// WithKeyProvider enables encryption of values at rest with the keys supplied by keyProvider
+func WithKeyProvider(keyProvider KeyProvider) Option {
+	return func(options *Options) {
+		options.keyProvider = keyProvider
+	}
+}
+
+// WithMaxKeySize rejects keys which serialize to more than maxKeySize bytes
+func WithMaxKeySize(maxKeySize uint32) Option {
+	return func(options *Options) {
+		options.maxKeySize = maxKeySize
+	}
+}
+
+// WithMaxValueSize rejects values longer than maxValueSize bytes
+func WithMaxValueSize(maxValueSize uint32) Option {
+	return func(options *Options) {
+		options.maxValueSize = maxValueSize
+	}
+}
+
+// WithLogger sets the logger of the merges and of the errors which happen in the background, none of which are logged by default
+func WithLogger(logger *slog.Logger) Option {
+	return func(options *Options) {
+		options.logger = logger
+	}
+}
+
+// WithOnError sets a callback called with the errors which happen in the background, such as a failed scheduled merge
+func WithOnError(onError func(error)) Option {
+	return func(options *Options) {
+		options.onError = onError
+	}
+}
+
+// WithListener sets the listener told about the lifecycle events of the database, such as segment rollovers and merges
+func WithListener(listener Listener) Option {
+	return func(options *Options) {
+		options.listener = listener
+	}
+}
+
+// WithReadOnly opens the directory without changing it: no segment is created, merge never runs and every write fails with ErrReadOnly
+func WithReadOnly() Option {
+	return func(options *Options) {
+		options.readOnly = true
+	}
+}
+
+// New creates a configuration for the directory by applying the options over the defaults, and validates the result
+func New[Key BitcaskKey](directory string, opts ...Option) (*Config[Key], error) {
+	options := &Options{
+		maxSegmentSizeBytes: defaultMaxSegmentSizeBytes,
+		mergeInterval:       defaultMergeInterval,
+		clock:               clock.NewSystemClock(),
+		syncPolicy:          SyncNever,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if directory == "" {
+		return nil, errors.New("directory is required")
+	}
+	if options.maxSegmentSizeBytes == 0 {
+		return nil, errors.New("maximum segment size must be positive")
+	}
+	if options.mergeInterval <= 0 {
+		return nil, fmt.Errorf("merge interval must be positive, got %v", options.mergeInterval)
+	}
+	if options.mergeSegmentsToRead < 0 {
+		return nil, fmt.Errorf("segments to read in a merge can not be negative, got %v", options.mergeSegmentsToRead)
+	}
+	if options.syncPolicy.SyncInterval() < 0 {
+		return nil, fmt.Errorf("sync interval can not be negative, got %v", options.syncPolicy.SyncInterval())
+	}
+	if options.clock == nil {
+		return nil, errors.New("clock is required")
+	}
+	if options.keyCodec == nil {
+		return nil, errors.New("key codec is required")
+	}
+	keyCodec, ok := options.keyCodec.(KeyCodec[Key])
+	if !ok {
+		var key Key
+		return nil, fmt.Errorf("key codec %T does not convert keys of type %T", options.keyCodec, key)
+	}
+
+	mergeConfig := &MergeConfig[Key]{
+		totalSegmentsToRead:   options.mergeSegmentsToRead,
+		shouldReadAllSegments: options.mergeSegmentsToRead == 0,
+		runMergeEvery:         options.mergeInterval,
+	}
+
+	return &Config[Key]{
+		directory:           directory,
+		maxSegmentSizeBytes: options.maxSegmentSizeBytes,
+		mergeConfig:         mergeConfig,
+		clock:               options.clock,
+		compression:         options.compression,
+		keyProvider:         options.keyProvider,
+		maxKeySize:          options.maxKeySize,
+		maxValueSize:        options.maxValueSize,
+		syncPolicy:          options.syncPolicy,
+		keyCodec:            keyCodec,
+		logger:              options.logger,
+		onError:             options.onError,
+		listener:            options.listener,
+		readOnly:            options.readOnly,
+	}, nil
+}
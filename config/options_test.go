@@ -0,0 +1,71 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type serializableKey string
+
+func (key serializableKey) Serialize() []byte {
+	return []byte(key)
+}
+
+type otherKey string
+
+func (key otherKey) Serialize() []byte {
+	return []byte(key)
+}
+
+func TestNewConfigWithDefaults(t *testing.T) {
+	config, err := New[serializableKey]("data", WithKeyCodec[serializableKey](StringKeyCodec[serializableKey]{}))
+	require.NoError(t, err)
+
+	require.Equal(t, "data", config.Directory())
+	require.Equal(t, uint64(defaultMaxSegmentSizeBytes), config.MaxSegmentSizeInBytes())
+	require.Equal(t, defaultMergeInterval, config.MergeConfig().RunMergeEvery())
+	require.True(t, config.MergeConfig().ShouldReadAllSegments())
+	require.Equal(t, SyncNever, config.SyncPolicy())
+	require.Equal(t, StringKeyCodec[serializableKey]{}, config.KeyCodec())
+}
+
+func TestNewConfigWithOptions(t *testing.T) {
+	config, err := New[serializableKey](
+		"data",
+		WithKeyCodec[serializableKey](StringKeyCodec[serializableKey]{}),
+		WithMaxSegmentSize(1024),
+		WithMergeInterval(time.Second),
+		WithMergeSegmentsToRead(4),
+		WithSyncPolicy(SyncEvery(time.Second)),
+		WithCompression(GzipCompression),
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(1024), config.MaxSegmentSizeInBytes())
+	require.Equal(t, time.Second, config.MergeConfig().RunMergeEvery())
+	require.False(t, config.MergeConfig().ShouldReadAllSegments())
+	require.Equal(t, 4, config.MergeConfig().TotalSegmentsToRead())
+	require.Equal(t, time.Second, config.SyncPolicy().SyncInterval())
+	require.Equal(t, GzipCompression, config.Compression())
+}
+
+func TestNewConfigWithInvalidOptions(t *testing.T) {
+	codec := WithKeyCodec[serializableKey](StringKeyCodec[serializableKey]{})
+
+	_, err := New[serializableKey]("", codec)
+	require.Error(t, err)
+
+	_, err = New[serializableKey]("data")
+	require.Error(t, err)
+
+	_, err = New[serializableKey]("data", codec, WithMaxSegmentSize(0))
+	require.Error(t, err)
+
+	_, err = New[serializableKey]("data", codec, WithMergeInterval(-time.Second))
+	require.Error(t, err)
+
+	_, err = New[otherKey]("data", codec)
+	require.Error(t, err)
+}
@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+// SyncPolicy decides when appended entries are flushed from the kernel page cache to the disk
+type SyncPolicy struct {
+	syncEveryWrite bool
+	syncInterval   time.Duration
+}
+
+var (
+	// SyncNever leaves flushing to the operating system, and to explicit calls to Sync
+	SyncNever = SyncPolicy{}
+	// SyncAlways flushes the active segment after every write
+	SyncAlways = SyncPolicy{syncEveryWrite: true}
+)
+
+// SyncEvery flushes all the segments every fixed duration
+func SyncEvery(interval time.Duration) SyncPolicy {
+	return SyncPolicy{syncInterval: interval}
+}
+
+func (policy SyncPolicy) SyncsEveryWrite() bool {
+	return policy.syncEveryWrite
+}
+
+func (policy SyncPolicy) SyncInterval() time.Duration {
+	return policy.syncInterval
+}
@@ -2,26 +2,58 @@ package bitcask
 
 import (
 	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/internal/dirlock"
 	"ashishkujoy/bitcask/kv"
 	"ashishkujoy/bitcask/merge"
+	"context"
+	"fmt"
+	"os"
 )
 
 // DB is the key/value database. It contains a `KVStore` and a `MergeWorker`
 // 1. KVStore is an abstraction that encapsulates append-only log segments and KeyDirectory which is an in-memory hashmap
-// 2. Worker encapsulates the goroutine that performs merge and compaction of inactive segments
+// 2. Worker encapsulates the goroutine that performs merge and compaction of inactive segments, which a read-only database does not have
 type DB[Key config.BitcaskKey] struct {
-	kvStore *kv.KVStore[Key]
-	worker  *merge.Worker[Key]
+	kvStore         *kv.KVStore[Key]
+	worker          *merge.Worker[Key]
+	syncWorker      *syncWorker[Key]
+	unlockDirectory func() error // releases the lock taken on the directory
+}
+
+// Open creates the directory if it does not exist and starts a new database instance in it. A key codec must be given using WithKeyCodec.
+func Open[Key config.BitcaskKey](directory string, opts ...Option) (*DB[Key], error) {
+	config, err := config.New[Key](directory, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !config.ReadOnly() {
+		if err := os.MkdirAll(directory, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return NewDB(config)
 }
 
 // NewDB takes a configuration and starts a new database instance.
 func NewDB[Key config.BitcaskKey](config *config.Config[Key]) (*DB[Key], error) {
+	if config.ReadOnly() {
+		return newReadOnlyDB(config)
+	}
+	unlockDirectory, err := dirlock.Exclusive(config.Directory())
+	if err != nil {
+		return nil, fmt.Errorf("locking directory %v: %w", config.Directory(), err)
+	}
 	store, err := kv.NewKVStore(config)
 	if err != nil {
+		unlockDirectory()
 		return nil, err
 	}
-	worker := merge.NewWorker(store, config.MergeConfig())
-	return &DB[Key]{kvStore: store, worker: worker}, nil
+	worker := merge.NewWorkerFromConfig(store, config)
+	db := &DB[Key]{kvStore: store, worker: worker, unlockDirectory: unlockDirectory}
+	if interval := config.SyncPolicy().SyncInterval(); interval > 0 {
+		db.syncWorker = newSyncWorker(store, interval, config.Logger(), config.OnError())
+	}
+	return db, nil
 }
 
 // Put adds a key value pair in the append-only log, followed by an entry in the hashmap inside KeyDirectory
@@ -29,6 +61,16 @@ func (db *DB[Key]) Put(key Key, value []byte) error {
 	return db.kvStore.Put(key, value)
 }
 
+// PutContext is Put giving up with ctx.Err() if ctx is done while it waits for the lock, which merge holds while it reads and writes back segments
+func (db *DB[Key]) PutContext(ctx context.Context, key Key, value []byte) error {
+	return db.kvStore.PutContext(ctx, key, value)
+}
+
+// PutAll adds the key value pairs in the append-only log, in order. It is not atomic: the pairs before a failed one stay added.
+func (db *DB[Key]) PutAll(pairs []kv.KeyValue[Key]) error {
+	return db.kvStore.PutAll(pairs)
+}
+
 // Update adds a key value pair in the append-only log, followed by updating the entry in the hashmap inside KeyDirectory
 // Both Update and Delete operations are append-only operations wrt log, but they are in-place update operations wrt KeyDirectory.
 func (db *DB[Key]) Update(key Key, value []byte) error {
@@ -41,25 +83,95 @@ func (db *DB[Key]) Delete(key Key) error {
 	return db.kvStore.Delete(key)
 }
 
+// DeleteContext is Delete giving up with ctx.Err() if ctx is done while it waits for the lock
+func (db *DB[Key]) DeleteContext(ctx context.Context, key Key) error {
+	return db.kvStore.DeleteContext(ctx, key)
+}
+
 // SilentGet gets the value corresponding to the key. Returns value, true if the value is found, else returns nil, false
 func (db *DB[Key]) SilentGet(key Key) ([]byte, bool) {
 	return db.kvStore.SilentGet(key)
 }
 
-// Get gets the value corresponding to the key. Returns value, nil if the value is found, else returns nil, error
+// Lookup is SilentGet returning a failure to read the value as an error, instead of reporting the key as missing
+func (db *DB[Key]) Lookup(key Key) ([]byte, bool, error) {
+	return db.kvStore.Lookup(key)
+}
+
+// Get gets the value corresponding to the key. Returns value, nil if the value is found, else returns nil, error (ErrKeyNotFound if missing)
 func (db *DB[Key]) Get(key Key) ([]byte, error) {
 	return db.kvStore.Get(key)
 }
 
+// GetContext is Get giving up with ctx.Err() if ctx is done while it waits for the lock
+func (db *DB[Key]) GetContext(ctx context.Context, key Key) ([]byte, error) {
+	return db.kvStore.GetContext(ctx, key)
+}
+
+// Scan calls fn with every key starting with prefix, in encoded order, and its value, until fn returns false. A nil prefix scans all the keys.
+func (db *DB[Key]) Scan(prefix []byte, fn func(key Key, value []byte) bool) error {
+	return db.kvStore.Scan(prefix, fn)
+}
+
+// ScanKeys is Scan without reading the values
+func (db *DB[Key]) ScanKeys(prefix []byte, fn func(key Key) bool) error {
+	return db.kvStore.ScanKeys(prefix, fn)
+}
+
+// Len returns the number of live keys
+func (db *DB[Key]) Len() int {
+	return db.kvStore.Len()
+}
+
+// Merge runs a merge of the inactive segments right away, instead of waiting for the merge goroutine. A read-only database returns ErrReadOnly.
+func (db *DB[Key]) Merge() error {
+	if db.worker == nil {
+		return ErrReadOnly
+	}
+	return db.worker.Merge()
+}
+
+// MergeContext is Merge giving up with ctx.Err() if ctx is done before the merged entries start being written back
+func (db *DB[Key]) MergeContext(ctx context.Context) error {
+	if db.worker == nil {
+		return ErrReadOnly
+	}
+	return db.worker.MergeContext(ctx)
+}
+
+// Subscribe returns a subscription, to be closed once no longer needed, to the committed writes of the keys starting with prefix, see kv.KVStore.Subscribe
+func (db *DB[Key]) Subscribe(prefix []byte, bufferSize int, policy OverflowPolicy) *kv.Subscription[Key] {
+	return db.kvStore.Subscribe(prefix, bufferSize, policy)
+}
+
+// LogReader returns a reader of the log starting at fromPosition, see kv.KVStore.LogReader
+func (db *DB[Key]) LogReader(fromPosition kv.Position) (*kv.LogReader[Key], error) {
+	return db.kvStore.LogReader(fromPosition)
+}
+
+// KeyCodec returns the codec used to convert keys to and from the bytes stored in the segments
+func (db *DB[Key]) KeyCodec() config.KeyCodec[Key] {
+	return db.kvStore.KeyCodec()
+}
+
 // Shutdown performs a shutdown of the database that involves stopping the merge worker goroutine and shutting down the KVStore
 func (db *DB[Key]) Shutdown() {
-	db.worker.Stop()
+	if db.worker != nil {
+		db.worker.Stop()
+	}
+	if db.syncWorker != nil {
+		db.syncWorker.stop()
+	}
 	db.kvStore.Shutdown()
+	if db.unlockDirectory != nil {
+		db.unlockDirectory()
+		db.unlockDirectory = nil
+	}
 }
 
 // Sync performs a sync of all the active and inactive segments. This implementation uses the Segment vocabulary over DataFile vocabulary
-func (db *DB[Key]) Sync() {
-	db.kvStore.Sync()
+func (db *DB[Key]) Sync() error {
+	return db.kvStore.Sync()
 }
 
 // clearLog removes all the log files
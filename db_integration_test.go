@@ -2,8 +2,12 @@ package bitcask
 
 import (
 	"ashishkujoy/bitcask/config"
+	"os"
+	"path"
 	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -58,7 +62,7 @@ func TestGetANonExistent(t *testing.T) {
 	defer db.clearLog()
 
 	_, err := db.Get("Topic")
-	require.Error(t, err)
+	require.ErrorIs(t, err, ErrKeyNotFound)
 }
 
 func TestUpdateAndDoASilentGet(t *testing.T) {
@@ -123,6 +127,7 @@ func TestReloadDb(t *testing.T) {
 		key := strconv.Itoa(count)
 		db.Put(serializableKey(key), []byte(key))
 	}
+	db.Shutdown()
 
 	newDb, _ := NewDB(config)
 	defer newDb.Shutdown()
@@ -130,8 +135,126 @@ func TestReloadDb(t *testing.T) {
 
 	for count := 1; count <= 100; count++ {
 		key := strconv.Itoa(count)
-		value, err := db.Get(serializableKey(key))
+		value, err := newDb.Get(serializableKey(key))
 		require.NoError(t, err)
 		require.Equal(t, value, []byte(key))
 	}
 }
+
+func TestSyncReportsItsFailure(t *testing.T) {
+	config := config.NewConfig(t.TempDir(), 8, config.NewMergeConfig(2, keyMapper))
+	db, _ := NewDB(config)
+
+	db.Put("Topic", []byte("Microservices"))
+	require.NoError(t, db.Sync())
+
+	db.Shutdown()
+	require.ErrorIs(t, db.Sync(), ErrClosed)
+}
+
+func TestPutWithKeyAndValueSizeLimits(t *testing.T) {
+	config := config.NewConfigWithSizeLimits(".", 1024, config.NewMergeConfig(2, keyMapper), 16, 64)
+	db, _ := NewDB(config)
+	defer db.Shutdown()
+	defer db.clearLog()
+
+	require.ErrorIs(t, db.Put("", []byte("Microservices")), ErrEmptyKey)
+	require.ErrorIs(t, db.Put("A key longer than sixteen bytes", []byte("Microservices")), ErrKeyTooLarge)
+	require.ErrorIs(t, db.Put("Topic", make([]byte, 65)), ErrValueTooLarge)
+
+	require.NoError(t, db.Put("Topic", make([]byte, 64)))
+}
+
+func TestPutAnEntryLargerThanASegment(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testEntryLargerThanASegment")
+	defer os.RemoveAll(tempDir)
+	codec := WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{})
+	db, err := Open[serializableKey](tempDir, codec, WithMaxSegmentSize(64))
+	require.NoError(t, err)
+
+	require.NoError(t, db.Put("Topic", make([]byte, 128)))
+	require.NoError(t, db.Put("Disk", []byte("SSD")))
+	db.Shutdown()
+
+	db, err = Open[serializableKey](tempDir, codec, WithMaxSegmentSize(64))
+	require.NoError(t, err)
+	defer db.Shutdown()
+	value, err := db.Get("Topic")
+	require.NoError(t, err)
+	require.Len(t, value, 128)
+}
+
+func TestOpenWithOptionsAndReopen(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testOpen")
+	defer os.RemoveAll(tempDir)
+	directory := path.Join(tempDir, "db")
+
+	db, err := Open[serializableKey](
+		directory,
+		WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}),
+		WithMaxSegmentSize(8),
+		WithSyncPolicy(config.SyncAlways),
+	)
+	require.NoError(t, err)
+
+	db.Put("Topic", []byte("Microservices"))
+	db.Put("Disk", []byte("SSD"))
+	db.Shutdown()
+
+	newDb, err := Open[serializableKey](directory, WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}))
+	require.NoError(t, err)
+	defer newDb.Shutdown()
+
+	value, err := newDb.Get("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Microservices", string(value))
+}
+
+func TestOpenWithoutAKeyCodec(t *testing.T) {
+	_, err := Open[serializableKey](".")
+	require.Error(t, err)
+}
+
+func TestOpenWithAnIntegerKey(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testOpenWithIntegerKey")
+	defer os.RemoveAll(tempDir)
+
+	db, err := Open[int64](tempDir, WithKeyCodec[int64](config.Int64KeyCodec[int64]{}), WithMaxSegmentSize(8))
+	require.NoError(t, err)
+	defer db.Shutdown()
+
+	db.Put(-10, []byte("minus ten"))
+	db.Put(10, []byte("ten"))
+
+	value, err := db.Get(-10)
+	require.NoError(t, err)
+	require.Equal(t, "minus ten", string(value))
+}
+
+func TestSyncEveryWithInactiveSegmentsReportsNoError(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testSyncEvery")
+	defer os.RemoveAll(tempDir)
+	codec := WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{})
+
+	db, err := Open[serializableKey](tempDir, codec, WithMaxSegmentSize(8))
+	require.NoError(t, err)
+	db.Put("Topic", []byte("Microservices"))
+	db.Put("Disk", []byte("SSD"))
+	db.Shutdown()
+
+	var reported atomic.Int32
+	db, err = Open[serializableKey](
+		tempDir,
+		codec,
+		WithMaxSegmentSize(8),
+		WithSyncPolicy(config.SyncEvery(10*time.Millisecond)),
+		WithOnError(func(error) { reported.Add(1) }),
+	)
+	require.NoError(t, err)
+	defer db.Shutdown()
+
+	db.Put("Engine", []byte("bitcask"))
+	db.Put("Language", []byte("go"))
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(t, reported.Load())
+}
@@ -0,0 +1,54 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/config"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenReadOnlyHoldsASharedLockOnTheDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	readOnly, err := OpenReadOnly[serializableKey](tempDir, WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}))
+	require.NoError(t, err)
+	other, err := OpenReadOnly[serializableKey](tempDir, WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}))
+	require.NoError(t, err)
+
+	directory, err := os.Open(tempDir)
+	require.NoError(t, err)
+	defer directory.Close()
+	require.ErrorIs(t, syscall.Flock(int(directory.Fd()), syscall.LOCK_EX|syscall.LOCK_NB), syscall.EWOULDBLOCK)
+
+	readOnly.Shutdown()
+	other.Shutdown()
+	require.NoError(t, syscall.Flock(int(directory.Fd()), syscall.LOCK_EX|syscall.LOCK_NB))
+	_, err = OpenReadOnly[serializableKey](tempDir, WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}))
+	require.ErrorIs(t, err, syscall.EWOULDBLOCK)
+}
+
+func TestOpenHoldsAnExclusiveLockOnTheDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	codec := WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{})
+	db, err := Open[serializableKey](tempDir, codec)
+	require.NoError(t, err)
+
+	_, err = Open[serializableKey](tempDir, codec)
+	require.ErrorIs(t, err, syscall.EWOULDBLOCK, "a writer excludes another writer")
+	_, err = OpenReadOnly[serializableKey](tempDir, codec)
+	require.ErrorIs(t, err, syscall.EWOULDBLOCK, "a writer excludes a reader")
+
+	db.Shutdown()
+	readOnly, err := OpenReadOnly[serializableKey](tempDir, codec)
+	require.NoError(t, err)
+	_, err = Open[serializableKey](tempDir, codec)
+	require.ErrorIs(t, err, syscall.EWOULDBLOCK, "a reader excludes a writer")
+
+	readOnly.Shutdown()
+	db, err = Open[serializableKey](tempDir, codec)
+	require.NoError(t, err)
+	db.Shutdown()
+}
@@ -0,0 +1,42 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/kv"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"errors"
+)
+
+var (
+	// ErrKeyNotFound is returned by Get when the key is not present in the database
+	ErrKeyNotFound = kv.ErrKeyNotFound
+	// ErrSegmentNotFound is returned when a read refers to a segment which is no longer present
+	ErrSegmentNotFound = kvlog.ErrSegmentNotFound
+	// ErrCorrupted is returned when an entry read from a segment is truncated, has inconsistent sizes or fails to decrypt or decompress
+	ErrCorrupted = kvlog.ErrCorrupted
+	// ErrClosed is returned by every operation performed after Shutdown
+	ErrClosed = kv.ErrClosed
+	// ErrPositionCompacted is returned by a LogReader when the segment of its position has been removed by merge
+	ErrPositionCompacted = kv.ErrPositionCompacted
+	// ErrConflict is returned by Txn when a key read by the transaction was written by someone else before it committed, the transaction can be retried
+	ErrConflict = kv.ErrConflict
+	// ErrTxnDone is returned by every operation of a transaction performed after Txn returned
+	ErrTxnDone = kv.ErrTxnDone
+	// ErrDefaultKeyspace is returned by Drop of the default keyspace, which can not be dropped
+	ErrDefaultKeyspace = kv.ErrDefaultKeyspace
+	// ErrEmptyKey is returned by Put, Update and Delete when the key serializes to zero bytes
+	ErrEmptyKey = kvlog.ErrEmptyKey
+	// ErrKeyTooLarge is returned by Put, Update and Delete when the serialized key is longer than the configured maximum key size
+	ErrKeyTooLarge = kvlog.ErrKeyTooLarge
+	// ErrValueTooLarge is returned by Put and Update when the value is longer than the configured maximum value size
+	ErrValueTooLarge = kvlog.ErrValueTooLarge
+	// ErrEntryTooLarge is returned by Put and Update when the entry, as stored, takes more bytes than a segment file can address
+	ErrEntryTooLarge = kvlog.ErrEntryTooLarge
+	// ErrBatchTooLarge is returned by Txn when the writes of the transaction, as stored, take more bytes than a segment file can address
+	ErrBatchTooLarge = kvlog.ErrBatchTooLarge
+	// ErrReadOnly is returned by every write to a database opened with OpenReadOnly
+	ErrReadOnly = kvlog.ErrReadOnly
+	// ErrInvalidBackup is returned by Restore when the manifest of a backup is missing or does not match the segments in the backup
+	ErrInvalidBackup = errors.New("invalid backup")
+	// ErrInvalidExport is returned by Import when the stream is malformed or its checksums do not match
+	ErrInvalidExport = errors.New("invalid export stream")
+)
@@ -0,0 +1,167 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/kv"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// exportMagic starts every export stream
+var exportMagic = [8]byte{'B', 'C', 'S', 'K', 'D', 'U', 'M', 'P'}
+
+const (
+	exportVersion   uint32 = 2
+	importBatchSize        = 1024
+	// exportVersionWithoutKeyspaces is the version whose records carry no keyspace
+	exportVersionWithoutKeyspaces uint32 = 1
+	// exportHeaderSize is the size of magic | version | key_count | checksum
+	exportHeaderSize = 8 + 4 + 8 + 4
+)
+
+// Export writes the live key value pairs of every keyspace, as of the time Export is called, to w as a header followed by one record per key:
+//
+//	header: ┌──────────────────┬─────────┬───────────┬──────────┐
+//	        │ magic "BCSKDUMP" │ version │ key_count │ checksum │
+//	        └──────────────────┴─────────┴───────────┴──────────┘
+//	record: ┌───────────────┬──────────┬────────────┬──────────┬─────┬───────┬──────────┐
+//	        │ keyspace_size │ key_size │ value_size │ keyspace │ key │ value │ checksum │
+//	        └───────────────┴──────────┴────────────┴──────────┴─────┴───────┴──────────┘
+//
+// Integers are little-endian, key_count a uint64 and the others uint32. A checksum is the CRC-32 (IEEE) of the bytes before it, and keyspace is "" for the default keyspace.
+// Version 1 records have no keyspace_size and no keyspace.
+func (db *DB[Key]) Export(w io.Writer) error {
+	snapshot, err := db.kvStore.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	writer := bufio.NewWriter(w)
+	header := make([]byte, exportHeaderSize-4)
+	copy(header, exportMagic[:])
+	binary.LittleEndian.PutUint32(header[8:], exportVersion)
+	keyspaces := append([]string{""}, snapshot.Keyspaces()...)
+	keyCount := 0
+	for _, keyspace := range keyspaces {
+		keyCount += snapshot.KeyspaceLen(keyspace)
+	}
+	binary.LittleEndian.PutUint64(header[12:], uint64(keyCount))
+	if _, err := writer.Write(binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(header))); err != nil {
+		return err
+	}
+
+	keyCodec := db.kvStore.KeyCodec()
+	for _, keyspace := range keyspaces {
+		scanErr := snapshot.ScanKeyspace(keyspace, nil, func(key Key, value []byte) bool {
+			err = writeExportRecord(writer, keyspace, keyCodec.Encode(key), value)
+			return err == nil
+		})
+		if scanErr != nil {
+			return scanErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Import puts the key value pairs of a stream written by Export in batches, which stay put if a later record is malformed. A malformed stream is reported as ErrInvalidExport.
+func (db *DB[Key]) Import(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	header := make([]byte, exportHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return fmt.Errorf("%w: header can not be read: %v", ErrInvalidExport, err)
+	}
+	if [8]byte(header[:8]) != exportMagic {
+		return fmt.Errorf("%w: stream does not start with the export magic", ErrInvalidExport)
+	}
+	if crc32.ChecksumIEEE(header[:exportHeaderSize-4]) != binary.LittleEndian.Uint32(header[exportHeaderSize-4:]) {
+		return fmt.Errorf("%w: checksum of the header does not match", ErrInvalidExport)
+	}
+	version := binary.LittleEndian.Uint32(header[8:])
+	if version != exportVersion && version != exportVersionWithoutKeyspaces {
+		return fmt.Errorf("%w: unsupported version %v", ErrInvalidExport, version)
+	}
+	keyCount := binary.LittleEndian.Uint64(header[12:])
+
+	keyCodec := db.kvStore.KeyCodec()
+	batch := make([]kv.KeyValue[Key], 0, importBatchSize)
+	batchKeyspace := ""
+	for index := uint64(0); index < keyCount; index++ {
+		keyspace, encodedKey, value, err := readExportRecord(reader, version == exportVersion)
+		if err != nil {
+			return fmt.Errorf("%w: record %v: %v", ErrInvalidExport, index, err)
+		}
+		key, err := keyCodec.Decode(encodedKey)
+		if err != nil {
+			return fmt.Errorf("%w: key of record %v can not be decoded: %v", ErrInvalidExport, index, err)
+		}
+		if len(batch) == importBatchSize || (len(batch) > 0 && keyspace != batchKeyspace) {
+			if err := db.kvStore.Keyspace(batchKeyspace).PutAll(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		batchKeyspace = keyspace
+		batch = append(batch, kv.KeyValue[Key]{Key: key, Value: value})
+	}
+	if _, err := reader.ReadByte(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: stream has data after its %v records", ErrInvalidExport, keyCount)
+	}
+	return db.kvStore.Keyspace(batchKeyspace).PutAll(batch)
+}
+
+func writeExportRecord(writer io.Writer, keyspace string, encodedKey []byte, value []byte) error {
+	record := make([]byte, 12, 12+len(keyspace)+len(encodedKey)+len(value)+4)
+	binary.LittleEndian.PutUint32(record, uint32(len(keyspace)))
+	binary.LittleEndian.PutUint32(record[4:], uint32(len(encodedKey)))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(value)))
+	record = append(append(append(record, keyspace...), encodedKey...), value...)
+	_, err := writer.Write(binary.LittleEndian.AppendUint32(record, crc32.ChecksumIEEE(record)))
+	return err
+}
+
+// readExportRecord reads a record, whose sizes start with the size of its keyspace if withKeyspace is set, and returns its keyspace, key and value
+func readExportRecord(reader io.Reader, withKeyspace bool) (string, []byte, []byte, error) {
+	sizes := make([]byte, 8)
+	if withKeyspace {
+		sizes = make([]byte, 12)
+	}
+	if _, err := io.ReadFull(reader, sizes); err != nil {
+		return "", nil, nil, err
+	}
+	var keyspaceSize uint64
+	if withKeyspace {
+		keyspaceSize = uint64(binary.LittleEndian.Uint32(sizes))
+	}
+	keySize := uint64(binary.LittleEndian.Uint32(sizes[len(sizes)-8:]))
+	valueSize := uint64(binary.LittleEndian.Uint32(sizes[len(sizes)-4:]))
+	bodySize := int64(keyspaceSize + keySize + valueSize + 4)
+
+	// the record is read into a growing buffer, so that corrupted sizes do not allocate more than the bytes the stream holds
+	var buffer bytes.Buffer
+	buffer.Write(sizes)
+	n, err := buffer.ReadFrom(io.LimitReader(reader, bodySize))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if n < bodySize {
+		return "", nil, nil, io.ErrUnexpectedEOF
+	}
+	record := buffer.Bytes()
+	checksumOffset := len(record) - 4
+	if crc32.ChecksumIEEE(record[:checksumOffset]) != binary.LittleEndian.Uint32(record[checksumOffset:]) {
+		return "", nil, nil, errors.New("checksum does not match")
+	}
+	body := record[len(sizes):checksumOffset]
+	return string(body[:keyspaceSize]), body[keyspaceSize : keyspaceSize+keySize], body[keyspaceSize+keySize:], nil
+}
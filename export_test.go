@@ -0,0 +1,152 @@
+package bitcask
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportAndImport(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testExport")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	defer db.Shutdown()
+	for count := 1; count <= 2000; count++ {
+		db.Put(serializableKey("key-"+strconv.Itoa(count)), []byte("value-"+strconv.Itoa(count)))
+	}
+	db.Delete("key-10")
+
+	var stream bytes.Buffer
+	require.NoError(t, db.Export(&stream))
+
+	other := openWithSegmentSize(t, path.Join(tempDir, "other"), 1024)
+	defer other.Shutdown()
+	require.NoError(t, other.Import(bytes.NewReader(stream.Bytes())))
+
+	require.Equal(t, 1999, other.Len())
+	value, err := other.Get("key-2000")
+	require.NoError(t, err)
+	require.Equal(t, "value-2000", string(value))
+	_, err = other.Get("key-10")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestExportAndImportTheKeyspaces(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testExportKeyspaces")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	defer db.Shutdown()
+	db.Put("topic", []byte("microservices"))
+	db.Keyspace("users").Put("topic", []byte("alice"))
+	db.Keyspace("orders").Put("topic", []byte("book"))
+	db.Keyspace("orders").Put("disk", []byte("ssd"))
+	db.Keyspace("dropped").Put("topic", []byte("gone"))
+	db.Keyspace("dropped").Drop()
+
+	var stream bytes.Buffer
+	require.NoError(t, db.Export(&stream))
+
+	other := openWithSegmentSize(t, path.Join(tempDir, "other"), 64)
+	defer other.Shutdown()
+	require.NoError(t, other.Import(bytes.NewReader(stream.Bytes())))
+
+	require.Equal(t, []string{"orders", "users"}, other.Keyspaces())
+	require.Equal(t, 1, other.Keyspace("users").Len())
+	require.Equal(t, 2, other.Keyspace("orders").Len())
+	value, err := other.Keyspace("users").Get("topic")
+	require.NoError(t, err)
+	require.Equal(t, "alice", string(value))
+	value, err = other.Get("topic")
+	require.NoError(t, err)
+	require.Equal(t, "microservices", string(value))
+}
+
+func TestImportAVersion1Stream(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testImportVersion1")
+	defer os.RemoveAll(tempDir)
+
+	header := append(bytes.Clone(exportMagic[:]), 1, 0, 0, 0)
+	header = binary.LittleEndian.AppendUint64(header, 1)
+	header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(header))
+	record := binary.LittleEndian.AppendUint32(nil, uint32(len("topic")))
+	record = binary.LittleEndian.AppendUint32(record, uint32(len("microservices")))
+	record = append(record, "topicmicroservices"...)
+	record = binary.LittleEndian.AppendUint32(record, crc32.ChecksumIEEE(record))
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	defer db.Shutdown()
+	require.NoError(t, db.Import(bytes.NewReader(append(header, record...))))
+
+	value, err := db.Get("topic")
+	require.NoError(t, err)
+	require.Equal(t, "microservices", string(value))
+	require.Empty(t, db.Keyspaces())
+}
+
+func TestExportInKeyOrder(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testExportOrder")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	defer db.Shutdown()
+	db.Put("disk", []byte("ssd"))
+	db.Put("topic", []byte("microservices"))
+	db.Put("engine", []byte("bitcask"))
+
+	var stream bytes.Buffer
+	require.NoError(t, db.Export(&stream))
+
+	content := stream.String()
+	require.Less(t, bytes.Index([]byte(content), []byte("disk")), bytes.Index([]byte(content), []byte("engine")))
+	require.Less(t, bytes.Index([]byte(content), []byte("engine")), bytes.Index([]byte(content), []byte("topic")))
+}
+
+func TestImportATamperedStream(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testImportTampered")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	defer db.Shutdown()
+	db.Put("topic", []byte("microservices"))
+
+	var stream bytes.Buffer
+	require.NoError(t, db.Export(&stream))
+	tampered := stream.Bytes()
+	tampered[len(tampered)-6] ^= 0xFF
+
+	other := openWithSegmentSize(t, path.Join(tempDir, "other"), 64)
+	defer other.Shutdown()
+	require.ErrorIs(t, other.Import(bytes.NewReader(tampered)), ErrInvalidExport)
+	require.ErrorIs(t, other.Import(bytes.NewReader(tampered[:10])), ErrInvalidExport)
+	require.Equal(t, 0, other.Len())
+}
+
+func TestImportAStreamWithCorruptedSizesOrTrailingData(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testImportMalformed")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, path.Join(tempDir, "db"), 64)
+	defer db.Shutdown()
+	db.Put("topic", []byte("microservices"))
+
+	var stream bytes.Buffer
+	require.NoError(t, db.Export(&stream))
+	other := openWithSegmentSize(t, path.Join(tempDir, "other"), 64)
+	defer other.Shutdown()
+
+	oversized := bytes.Clone(stream.Bytes())
+	copy(oversized[exportHeaderSize:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	require.ErrorIs(t, other.Import(bytes.NewReader(oversized)), ErrInvalidExport)
+
+	trailing := append(bytes.Clone(stream.Bytes()), 0)
+	require.ErrorIs(t, other.Import(bytes.NewReader(trailing)), ErrInvalidExport)
+	require.Equal(t, 0, other.Len())
+}
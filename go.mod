@@ -2,11 +2,13 @@ module ashishkujoy/bitcask
 
 go 1.23.1
 
-require github.com/stretchr/testify v1.9.0
+require (
+	github.com/hashicorp/go-immutable-radix/v2 v2.1.0
+	github.com/stretchr/testify v1.9.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/hashicorp/go-immutable-radix/v2 v2.1.0 // indirect
 	github.com/hashicorp/golang-lru/v2 v2.0.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,184 @@
+// Package httpapi exposes a DB over HTTP with a handler which can be mounted in any net/http server:
+//
+//	GET    /kv/{key}       the value of the key, as the raw response body
+//	PUT    /kv/{key}       puts the request body as the value of the key
+//	DELETE /kv/{key}       deletes the key
+//	GET    /kv?prefix=     streams the keys starting with the prefix, along with their values, as newline-delimited JSON
+//	POST   /merge          merges the inactive segments
+//	GET    /stats          the statistics of the DB, see bitcask.Stats, as JSON
+//
+// Keys are decoded using the key codec of the DB, and errors are reported as {"error": "..."}.
+package httpapi
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// scanFlushInterval is the number of scanned entries after which the response is flushed to the client
+const scanFlushInterval = 64
+
+// ScanEntry is a line of the response of a scan, Error being set on the last line of a scan which failed midway
+type ScanEntry struct {
+	Key   string `json:"key,omitempty"`
+	Value []byte `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type handler[Key config.BitcaskKey] struct {
+	db *bitcask.DB[Key]
+}
+
+// NewHandler creates the http.Handler of the API over db
+func NewHandler[Key config.BitcaskKey](db *bitcask.DB[Key]) http.Handler {
+	handler := &handler[Key]{db: db}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /kv/{key...}", handler.get)
+	mux.HandleFunc("PUT /kv/{key...}", handler.put)
+	mux.HandleFunc("DELETE /kv/{key...}", handler.delete)
+	mux.HandleFunc("GET /kv", handler.scan)
+	mux.HandleFunc("POST /merge", handler.merge)
+	mux.HandleFunc("GET /stats", handler.stats)
+	return mux
+}
+
+func (handler *handler[Key]) get(writer http.ResponseWriter, request *http.Request) {
+	key, ok := handler.decodeKey(writer, request)
+	if !ok {
+		return
+	}
+	value, err := handler.db.Get(key)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/octet-stream")
+	writer.Write(value)
+}
+
+func (handler *handler[Key]) put(writer http.ResponseWriter, request *http.Request) {
+	key, ok := handler.decodeKey(writer, request)
+	if !ok {
+		return
+	}
+	value, err := io.ReadAll(request.Body)
+	if err != nil {
+		writeErrorWithStatus(writer, http.StatusBadRequest, err)
+		return
+	}
+	if err := handler.db.Put(key, value); err != nil {
+		writeError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// delete deletes the key if it exists, so deleting a missing key appends no tombstone and is reported as not found
+func (handler *handler[Key]) delete(writer http.ResponseWriter, request *http.Request) {
+	key, ok := handler.decodeKey(writer, request)
+	if !ok {
+		return
+	}
+	_, found, err := handler.db.Lookup(key)
+	if err == nil && !found {
+		err = bitcask.ErrKeyNotFound
+	}
+	if err == nil {
+		err = handler.db.Delete(key)
+	}
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// scan streams a line of JSON per key, flushing every scanFlushInterval keys
+func (handler *handler[Key]) scan(writer http.ResponseWriter, request *http.Request) {
+	var prefix []byte
+	if request.URL.Query().Has("prefix") {
+		prefix = []byte(request.URL.Query().Get("prefix"))
+	}
+
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+	controller := http.NewResponseController(writer)
+	encoder := json.NewEncoder(writer)
+	keyCodec := handler.db.KeyCodec()
+	var writeErr error
+	scanned := 0
+	err := handler.db.Scan(prefix, func(key Key, value []byte) bool {
+		if writeErr = request.Context().Err(); writeErr != nil {
+			return false
+		}
+		if writeErr = encoder.Encode(ScanEntry{Key: string(keyCodec.Encode(key)), Value: value}); writeErr != nil {
+			return false
+		}
+		if scanned++; scanned%scanFlushInterval == 0 {
+			writeErr = controller.Flush()
+		}
+		return writeErr == nil
+	})
+	if err != nil && writeErr == nil {
+		if scanned == 0 {
+			writeError(writer, err)
+			return
+		}
+		encoder.Encode(ScanEntry{Error: err.Error()})
+	}
+}
+
+func (handler *handler[Key]) merge(writer http.ResponseWriter, request *http.Request) {
+	if err := handler.db.Merge(); err != nil {
+		writeError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *handler[Key]) stats(writer http.ResponseWriter, request *http.Request) {
+	stats, err := handler.db.Stats()
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, stats)
+}
+
+func (handler *handler[Key]) decodeKey(writer http.ResponseWriter, request *http.Request) (Key, bool) {
+	key, err := handler.db.KeyCodec().Decode([]byte(request.PathValue("key")))
+	if err != nil {
+		writeErrorWithStatus(writer, http.StatusBadRequest, err)
+		return key, false
+	}
+	return key, true
+}
+
+// writeError reports err with the status matching it
+func writeError(writer http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, bitcask.ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, bitcask.ErrEmptyKey), errors.Is(err, bitcask.ErrKeyTooLarge):
+		status = http.StatusBadRequest
+	case errors.Is(err, bitcask.ErrValueTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, bitcask.ErrClosed):
+		status = http.StatusServiceUnavailable
+	}
+	writeErrorWithStatus(writer, status, err)
+}
+
+func writeErrorWithStatus(writer http.ResponseWriter, status int, err error) {
+	writeJSON(writer, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(writer http.ResponseWriter, status int, body any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(body)
+}
@@ -0,0 +1,107 @@
+package httpapi
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func openDB(t *testing.T) *bitcask.DB[config.BytesKey] {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testHttpApi")
+	db, err := bitcask.Open[config.BytesKey](tempDir, bitcask.WithKeyCodec[config.BytesKey](config.BytesKeyCodec{}))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Shutdown()
+		os.RemoveAll(tempDir)
+	})
+	return db
+}
+
+func do(t *testing.T, handler http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return recorder
+}
+
+func TestPutGetAndDeleteAKey(t *testing.T) {
+	handler := NewHandler(openDB(t))
+
+	response := do(t, handler, http.MethodPut, "/kv/users%2F1", "Microservices")
+	require.Equal(t, http.StatusNoContent, response.Code)
+
+	response = do(t, handler, http.MethodGet, "/kv/users%2F1", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "Microservices", response.Body.String())
+
+	response = do(t, handler, http.MethodDelete, "/kv/users%2F1", "")
+	require.Equal(t, http.StatusNoContent, response.Code)
+
+	response = do(t, handler, http.MethodGet, "/kv/users%2F1", "")
+	require.Equal(t, http.StatusNotFound, response.Code)
+	require.Contains(t, response.Body.String(), `"error"`)
+
+	response = do(t, handler, http.MethodDelete, "/kv/users%2F1", "")
+	require.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestScanStreamsTheKeysWithAPrefix(t *testing.T) {
+	db := openDB(t)
+	for index := range 200 {
+		require.NoError(t, db.Put(config.BytesKey(fmt.Sprintf("user:%03d", index)), []byte("value")))
+	}
+	require.NoError(t, db.Put(config.BytesKey("order:1"), []byte("value")))
+
+	server := httptest.NewServer(NewHandler(db))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/kv?prefix=user:")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, "application/x-ndjson", response.Header.Get("Content-Type"))
+
+	var keys []string
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		var entry ScanEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		require.Empty(t, entry.Error)
+		require.Equal(t, "value", string(entry.Value))
+		keys = append(keys, entry.Key)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, keys, 200)
+	require.Equal(t, "user:000", keys[0])
+	require.Equal(t, "user:199", keys[199])
+}
+
+func TestMergeAndStatsMountedUnderAPrefix(t *testing.T) {
+	db := openDB(t)
+	require.NoError(t, db.Put(config.BytesKey("Topic"), []byte("Microservices")))
+
+	mux := http.NewServeMux()
+	mux.Handle("/bitcask/", http.StripPrefix("/bitcask", NewHandler(db)))
+
+	response := do(t, mux, http.MethodPost, "/bitcask/merge", "")
+	require.Equal(t, http.StatusNoContent, response.Code)
+
+	response = do(t, mux, http.MethodGet, "/bitcask/stats", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	var stats bitcask.Stats
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&stats))
+	require.Equal(t, 1, stats.Keys)
+	require.Equal(t, uint64(1), stats.Writes)
+
+	response = do(t, mux, http.MethodGet, "/bitcask/kv/Topic", "")
+	body, _ := io.ReadAll(response.Body)
+	require.Equal(t, "Microservices", string(body))
+}
@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+// Package dirlock locks a bitcask directory without waiting: a writable database and Repair lock it exclusively, a read-only database and Verify share it.
+package dirlock
+
+import (
+	"os"
+	"syscall"
+)
+
+// Shared takes a shared flock on the directory itself and returns the function releasing it
+func Shared(directory string) (func() error, error) {
+	return lockDirectory(directory, syscall.LOCK_SH)
+}
+
+// Exclusive takes an exclusive flock on the directory itself and returns the function releasing it
+func Exclusive(directory string) (func() error, error) {
+	return lockDirectory(directory, syscall.LOCK_EX)
+}
+
+func lockDirectory(directory string, how int) (func() error, error) {
+	file, err := os.Open(directory)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file.Close, nil
+}
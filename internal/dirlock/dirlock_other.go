@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package dirlock
+
+import "os"
+
+// Shared only checks that the directory can be opened, on the platforms without flock
+func Shared(directory string) (func() error, error) {
+	return Exclusive(directory)
+}
+
+// Exclusive only checks that the directory can be opened, on the platforms without flock
+func Exclusive(directory string) (func() error, error) {
+	file, err := os.Open(directory)
+	if err != nil {
+		return nil, err
+	}
+	return file.Close, nil
+}
@@ -0,0 +1,105 @@
+// Package rpc defines the request and response frames of the binary protocol shared by the remote server and the client.
+//
+// A client sends a request frame, whose kind is the operation, and reads the response frames before sending the next request:
+//
+//	OpGet     key                        → StatusOK value
+//	OpPut     key value                  → StatusOK
+//	OpDelete  key                        → StatusOK
+//	OpPutAll  count (key value)*         → StatusOK
+//	OpScan    prefix                     → StatusEntry key value ... StatusEnd
+//	OpPing                               → StatusOK
+//
+// Any request can be answered with StatusError, an error code followed by the message of the error.
+package rpc
+
+import (
+	"ashishkujoy/bitcask"
+	"errors"
+)
+
+// MaxRequestPayload bounds the payload of a request frame, read from clients the server knows nothing of
+const MaxRequestPayload = 64 * 1024 * 1024
+
+// Operations, the kinds of the request frames
+const (
+	OpGet byte = iota + 1
+	OpPut
+	OpDelete
+	OpPutAll
+	OpScan
+	OpPing
+)
+
+// Statuses, the kinds of the response frames
+const (
+	StatusOK byte = iota + 1
+	StatusEntry
+	StatusEnd
+	StatusError
+)
+
+// Codes of the errors, which map the errors of the DB to the errors returned by the client
+const (
+	CodeOther byte = iota + 1
+	CodeKeyNotFound
+	CodeEmptyKey
+	CodeKeyTooLarge
+	CodeValueTooLarge
+	CodeClosed
+	CodeBadRequest
+)
+
+var codes = []struct {
+	code byte
+	err  error
+}{
+	{CodeKeyNotFound, bitcask.ErrKeyNotFound},
+	{CodeEmptyKey, bitcask.ErrEmptyKey},
+	{CodeKeyTooLarge, bitcask.ErrKeyTooLarge},
+	{CodeValueTooLarge, bitcask.ErrValueTooLarge},
+	{CodeClosed, bitcask.ErrClosed},
+}
+
+// Error is an error reported by the server. It unwraps to the matching error of the bitcask package, so errors.Is works across the network.
+type Error struct {
+	Code    byte
+	Message string
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+func (err *Error) Unwrap() error {
+	for _, code := range codes {
+		if code.code == err.Code {
+			return code.err
+		}
+	}
+	return nil
+}
+
+// EncodeError encodes err as the payload of a StatusError frame
+func EncodeError(err error) []byte {
+	code := CodeOther
+	var remoteErr *Error
+	if errors.As(err, &remoteErr) {
+		code = remoteErr.Code
+	} else {
+		for _, candidate := range codes {
+			if errors.Is(err, candidate.err) {
+				code = candidate.code
+				break
+			}
+		}
+	}
+	return append([]byte{code}, err.Error()...)
+}
+
+// DecodeError decodes the payload of a StatusError frame
+func DecodeError(payload []byte) *Error {
+	if len(payload) == 0 {
+		return &Error{Code: CodeOther, Message: "server reported an error"}
+	}
+	return &Error{Code: payload[0], Message: string(payload[1:])}
+}
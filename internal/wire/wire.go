@@ -0,0 +1,137 @@
+// Package wire encodes the frames exchanged by the network protocols of bitcask, replication and remote.
+//
+// Every frame is encoded as
+//
+//	┌──────┬────────────────┬─────────┐
+//	│ kind │ payload_length │ payload │
+//	└──────┴────────────────┴─────────┘
+//
+// with little-endian integers, and byte strings prefixed by their uint32 length except for the last field of the payload.
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	headerSize = 1 + 4
+	// MaxPayload bounds the payload a frame can announce, so a corrupted length does not make the reader allocate gigabytes
+	MaxPayload = 256 * 1024 * 1024
+)
+
+// ErrShortPayload is returned when a field runs past the end of a payload
+var ErrShortPayload = errors.New("frame payload is too short")
+
+// LittleEndian is the byte order of the integers of frames
+var LittleEndian = binary.LittleEndian
+
+// Frame is a decoded frame
+type Frame struct {
+	Kind    byte
+	Payload []byte
+}
+
+// WriteFrame writes a frame of the given kind and payload
+func WriteFrame(writer io.Writer, kind byte, payload []byte) error {
+	if len(payload) > MaxPayload {
+		return fmt.Errorf("frame of %v bytes exceeds the maximum of %v bytes", len(payload), MaxPayload)
+	}
+	header := make([]byte, headerSize)
+	header[0] = kind
+	LittleEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := writer.Write(header); err != nil {
+		return err
+	}
+	_, err := writer.Write(payload)
+	return err
+}
+
+// ReadFrame reads the next frame
+func ReadFrame(reader *bufio.Reader) (*Frame, error) {
+	return ReadFrameUpTo(reader, MaxPayload)
+}
+
+// ReadFrameUpTo reads the next frame into a growing buffer, failing if its payload is larger than maxPayload
+func ReadFrameUpTo(reader *bufio.Reader, maxPayload uint32) (*Frame, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	length := LittleEndian.Uint32(header[1:])
+	if length > maxPayload {
+		return nil, fmt.Errorf("frame of %v bytes exceeds the maximum of %v bytes", length, maxPayload)
+	}
+	var payload bytes.Buffer
+	n, err := payload.ReadFrom(io.LimitReader(reader, int64(length)))
+	if err != nil {
+		return nil, err
+	}
+	if n < int64(length) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return &Frame{Kind: header[0], Payload: payload.Bytes()}, nil
+}
+
+// AppendBytes appends field to payload, prefixed by its uint32 length
+func AppendBytes(payload []byte, field []byte) []byte {
+	payload = LittleEndian.AppendUint32(payload, uint32(len(field)))
+	return append(payload, field...)
+}
+
+// PayloadReader decodes the fields of a payload in order, remembering the first field which runs past the end of the payload
+type PayloadReader struct {
+	payload []byte
+	err     error
+}
+
+// NewPayloadReader creates a PayloadReader of payload
+func NewPayloadReader(payload []byte) *PayloadReader {
+	return &PayloadReader{payload: payload}
+}
+
+// Err returns ErrShortPayload if a field ran past the end of the payload
+func (reader *PayloadReader) Err() error {
+	return reader.err
+}
+
+func (reader *PayloadReader) next(size int) []byte {
+	if reader.err != nil || len(reader.payload) < size {
+		reader.err = ErrShortPayload
+		return make([]byte, min(size, 8))
+	}
+	field := reader.payload[:size]
+	reader.payload = reader.payload[size:]
+	return field
+}
+
+// Byte decodes a byte
+func (reader *PayloadReader) Byte() byte {
+	return reader.next(1)[0]
+}
+
+// Uint32 decodes a uint32
+func (reader *PayloadReader) Uint32() uint32 {
+	return LittleEndian.Uint32(reader.next(4))
+}
+
+// Uint64 decodes a uint64
+func (reader *PayloadReader) Uint64() uint64 {
+	return LittleEndian.Uint64(reader.next(8))
+}
+
+// Bytes decodes a byte string prefixed by its uint32 length
+func (reader *PayloadReader) Bytes() []byte {
+	return reader.next(int(reader.Uint32()))
+}
+
+// Rest decodes the remaining bytes of the payload
+func (reader *PayloadReader) Rest() []byte {
+	rest := reader.payload
+	reader.payload = nil
+	return rest
+}
@@ -0,0 +1,13 @@
+package bitcask
+
+import "ashishkujoy/bitcask/kv"
+
+// Keyspace returns the keyspace named name, see kv.Keyspace. The keyspace named "" is the default keyspace, the one Put, Get and Delete of DB use.
+func (db *DB[Key]) Keyspace(name string) *kv.Keyspace[Key] {
+	return db.kvStore.Keyspace(name)
+}
+
+// Keyspaces returns the names of the keyspaces, other than the default keyspace, in order
+func (db *DB[Key]) Keyspaces() []string {
+	return db.kvStore.Keyspaces()
+}
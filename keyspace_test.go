@@ -0,0 +1,64 @@
+package bitcask
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyspacesKeepTheirKeysApartAcrossMergeAndReopen(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testKeyspaces")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, tempDir, 32)
+	require.NoError(t, db.Put("Topic", []byte("Databases")))
+	require.NoError(t, db.Keyspace("users").Put("Topic", []byte("Storage engines")))
+	require.NoError(t, db.Keyspace("orders").Put("Topic", []byte("Compilers")))
+	require.NoError(t, db.Keyspace("orders").Put("Disk", []byte("SSD")))
+	require.NoError(t, db.Keyspace("users").Put("Topic", []byte("Microservices")))
+
+	require.Equal(t, 1, db.Len())
+	require.Equal(t, 1, db.Keyspace("users").Len())
+	require.Equal(t, 2, db.Keyspace("orders").Len())
+	require.Equal(t, []string{"orders", "users"}, db.Keyspaces())
+	value, err := db.Keyspace("users").Get("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Microservices", string(value))
+	value, _ = db.Keyspace("").Get("Topic")
+	require.Equal(t, "Databases", string(value), "the keyspace named \"\" is the default keyspace")
+	_, err = db.Keyspace("missing").Get("Topic")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.NoError(t, db.Keyspace("orders").Drop())
+	require.Equal(t, 0, db.Keyspace("orders").Len())
+	require.Equal(t, []string{"users"}, db.Keyspaces())
+	require.ErrorIs(t, db.Keyspace("").Drop(), ErrDefaultKeyspace)
+
+	require.NoError(t, db.Merge())
+	merged, err := db.Stats()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), merged.MergeRuns)
+	require.Equal(t, 2, merged.Keys)
+	var keys []serializableKey
+	require.NoError(t, db.Keyspace("users").ScanKeys(nil, func(key serializableKey) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	require.Equal(t, []serializableKey{"Topic"}, keys)
+	db.Shutdown()
+
+	db = openWithSegmentSize(t, tempDir, 32)
+	defer db.Shutdown()
+	require.Equal(t, []string{"users"}, db.Keyspaces())
+	value, _ = db.Keyspace("users").Get("Topic")
+	require.Equal(t, "Microservices", string(value))
+	value, _ = db.Get("Topic")
+	require.Equal(t, "Databases", string(value))
+
+	require.NoError(t, db.Keyspace("orders").Put("Topic", []byte("Networks")))
+	require.Equal(t, 1, db.Keyspace("orders").Len(), "a dropped keyspace is created again empty")
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 3, stats.Keys)
+}
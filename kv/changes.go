@@ -0,0 +1,172 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// Op identifies the kind of write which changed a key
+type Op byte
+
+const (
+	OpPut Op = iota + 1
+	OpDelete
+	// OpDropKeyspace is the drop of the keyspace of the event, along with all its keys. Its Key is the zero Key.
+	OpDropKeyspace
+)
+
+// OverflowPolicy decides what happens to a change event when the buffer of a subscription is full
+type OverflowPolicy int
+
+const (
+	// DropEvents drops the event, which is counted in Subscription.Dropped, so writers never wait for a slow subscriber
+	DropEvents OverflowPolicy = iota
+	// BlockWriters makes the write wait until the subscriber has room for the event
+	BlockWriters
+)
+
+// ChangeEvent describes a committed write. Sequence numbers the writes from 1, so a gap means events were dropped or filtered out.
+type ChangeEvent[Key config.BitcaskKey] struct {
+	Keyspace string // name of the keyspace of the key, "" for the default keyspace
+	Key      Key
+	Op       Op
+	Value    []byte
+	Sequence uint64
+}
+
+// Subscription receives the change events of the keys of a keyspace whose encoded bytes start with its prefix, in the order the writes were committed
+type Subscription[Key config.BitcaskKey] struct {
+	keyspace  string
+	prefix    []byte
+	policy    OverflowPolicy
+	events    chan ChangeEvent[Key]
+	done      chan struct{}
+	stopOnce  sync.Once
+	dropped   atomic.Uint64
+	publisher *publisher[Key]
+}
+
+// Events returns the channel the events are delivered on. The channel is closed when the subscription or the store is closed.
+func (subscription *Subscription[Key]) Events() <-chan ChangeEvent[Key] {
+	return subscription.events
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (subscription *Subscription[Key]) Dropped() uint64 {
+	return subscription.dropped.Load()
+}
+
+// Close stops the delivery of events and closes the events channel. A writer blocked on this subscription is released.
+func (subscription *Subscription[Key]) Close() {
+	subscription.stop()
+	subscription.publisher.remove(subscription)
+}
+
+// stop releases the deliveries blocked on the subscription, and makes the ones to come return right away
+func (subscription *Subscription[Key]) stop() {
+	subscription.stopOnce.Do(func() { close(subscription.done) })
+}
+
+// deliver hands the event to the subscriber according to the overflow policy
+func (subscription *Subscription[Key]) deliver(event ChangeEvent[Key]) {
+	if subscription.policy == BlockWriters {
+		select {
+		case subscription.events <- event:
+		case <-subscription.done:
+		case <-subscription.publisher.closing:
+		}
+		return
+	}
+	select {
+	case subscription.events <- event:
+	default:
+		subscription.dropped.Add(1)
+	}
+}
+
+// publisher numbers the committed writes and publishes them to the subscriptions
+type publisher[Key config.BitcaskKey] struct {
+	lock          sync.Mutex
+	sequence      uint64
+	subscriptions map[*Subscription[Key]]struct{}
+	closing       chan struct{}
+	closeOnce     sync.Once
+}
+
+func newPublisher[Key config.BitcaskKey]() *publisher[Key] {
+	return &publisher[Key]{
+		subscriptions: make(map[*Subscription[Key]]struct{}),
+		closing:       make(chan struct{}),
+	}
+}
+
+func (publisher *publisher[Key]) subscribe(keyspace string, prefix []byte, bufferSize int, policy OverflowPolicy) *Subscription[Key] {
+	subscription := &Subscription[Key]{
+		keyspace:  keyspace,
+		prefix:    bytes.Clone(prefix),
+		policy:    policy,
+		events:    make(chan ChangeEvent[Key], bufferSize),
+		done:      make(chan struct{}),
+		publisher: publisher,
+	}
+
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+
+	if publisher.isClosed() {
+		subscription.stop()
+		close(subscription.events)
+		return subscription
+	}
+	publisher.subscriptions[subscription] = struct{}{}
+	return subscription
+}
+
+// publish numbers the write of the encoded key and delivers it to the matching subscriptions of the keyspace
+func (publisher *publisher[Key]) publish(keyspace string, key Key, encodedKey []byte, op Op, value []byte) {
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+
+	publisher.sequence++
+	event := ChangeEvent[Key]{Keyspace: keyspace, Key: key, Op: op, Value: value, Sequence: publisher.sequence}
+	for subscription := range publisher.subscriptions {
+		if subscription.keyspace == keyspace && (op == OpDropKeyspace || bytes.HasPrefix(encodedKey, subscription.prefix)) {
+			subscription.deliver(event)
+		}
+	}
+}
+
+func (publisher *publisher[Key]) remove(subscription *Subscription[Key]) {
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+
+	if _, ok := publisher.subscriptions[subscription]; ok {
+		delete(publisher.subscriptions, subscription)
+		close(subscription.events)
+	}
+}
+
+// close closes all the subscriptions, and the ones subscribed from now on
+func (publisher *publisher[Key]) close() {
+	publisher.closeOnce.Do(func() { close(publisher.closing) })
+
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+
+	for subscription := range publisher.subscriptions {
+		subscription.stop()
+		delete(publisher.subscriptions, subscription)
+		close(subscription.events)
+	}
+}
+
+func (publisher *publisher[Key]) isClosed() bool {
+	select {
+	case <-publisher.closing:
+		return true
+	default:
+		return false
+	}
+}
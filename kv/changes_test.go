@@ -0,0 +1,122 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newStoreForChanges(t *testing.T) (*KVStore[serializableKey], func()) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testChanges")
+	store, err := NewKVStore(config.NewConfig(tempDir, 1024, config.NewMergeConfig(2, keyMapper)))
+	require.NoError(t, err)
+	return store, func() {
+		store.Shutdown()
+		os.RemoveAll(tempDir)
+	}
+}
+
+func TestSubscribeToChangesOfAPrefix(t *testing.T) {
+	store, cleanup := newStoreForChanges(t)
+	defer cleanup()
+
+	subscription := store.Subscribe([]byte("user:"), 10, DropEvents)
+	defer subscription.Close()
+
+	store.Put("user:1", []byte("alice"))
+	store.Put("topic", []byte("bitcask"))
+	store.Delete("user:1")
+
+	event := <-subscription.Events()
+	require.Equal(t, ChangeEvent[serializableKey]{Key: "user:1", Op: OpPut, Value: []byte("alice"), Sequence: 1}, event)
+
+	event = <-subscription.Events()
+	require.Equal(t, ChangeEvent[serializableKey]{Key: "user:1", Op: OpDelete, Sequence: 3}, event)
+}
+
+func TestSubscribeToChangesOfAKeyspace(t *testing.T) {
+	store, cleanup := newStoreForChanges(t)
+	defer cleanup()
+
+	users := store.Keyspace("users")
+	subscription := users.Subscribe([]byte("user:"), 10, DropEvents)
+	defer subscription.Close()
+	defaultSubscription := store.Subscribe(nil, 10, DropEvents)
+	defer defaultSubscription.Close()
+
+	users.Put("user:1", []byte("alice"))
+	store.Put("user:1", []byte("bob"))
+	users.Delete("user:1")
+	users.Drop()
+
+	require.Equal(t, ChangeEvent[serializableKey]{Keyspace: "users", Key: "user:1", Op: OpPut, Value: []byte("alice"), Sequence: 1}, <-subscription.Events())
+	require.Equal(t, ChangeEvent[serializableKey]{Keyspace: "users", Key: "user:1", Op: OpDelete, Sequence: 3}, <-subscription.Events())
+	require.Equal(t, ChangeEvent[serializableKey]{Keyspace: "users", Op: OpDropKeyspace, Sequence: 4}, <-subscription.Events())
+
+	require.Equal(t, ChangeEvent[serializableKey]{Key: "user:1", Op: OpPut, Value: []byte("bob"), Sequence: 2}, <-defaultSubscription.Events())
+	require.Empty(t, defaultSubscription.Events())
+}
+
+func TestSubscriptionDropsEventsWhenTheBufferIsFull(t *testing.T) {
+	store, cleanup := newStoreForChanges(t)
+	defer cleanup()
+
+	subscription := store.Subscribe(nil, 1, DropEvents)
+	defer subscription.Close()
+
+	store.Put("topic", []byte("databases"))
+	store.Put("topic", []byte("microservices"))
+	store.Put("topic", []byte("bitcask"))
+
+	event := <-subscription.Events()
+	require.Equal(t, "databases", string(event.Value))
+	require.Equal(t, uint64(2), subscription.Dropped())
+}
+
+func TestSubscriptionBlocksWritersWhenTheBufferIsFull(t *testing.T) {
+	store, cleanup := newStoreForChanges(t)
+	defer cleanup()
+
+	subscription := store.Subscribe(nil, 1, BlockWriters)
+	defer subscription.Close()
+
+	store.Put("topic", []byte("databases"))
+	written := make(chan struct{})
+	go func() {
+		store.Put("topic", []byte("microservices"))
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("put completed while the subscription buffer was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.Equal(t, "databases", string((<-subscription.Events()).Value))
+	<-written
+	require.Equal(t, "microservices", string((<-subscription.Events()).Value))
+	require.Equal(t, uint64(0), subscription.Dropped())
+}
+
+func TestShutdownClosesSubscriptionsAndReleasesBlockedWriters(t *testing.T) {
+	store, cleanup := newStoreForChanges(t)
+	defer cleanup()
+
+	subscription := store.Subscribe(nil, 0, BlockWriters)
+	written := make(chan struct{})
+	go func() {
+		store.Put("topic", []byte("databases"))
+		close(written)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	store.Shutdown()
+	<-written
+	_, open := <-subscription.Events()
+	require.False(t, open)
+	subscription.Close()
+}
@@ -0,0 +1,126 @@
+package kv
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Version identifies a write of a key. Versions are only meaningful to the store which returned them: reloaded entries are at the version
+// the store was opened at, taken from the clock, and merge keeps the version of the entries it moves.
+type Version uint64
+
+// CompareAndSwap puts value as the value of the key if its current value is equal to expected, and returns true if it did. A missing key never matches.
+func (store *KVStore[Key]) CompareAndSwap(key Key, expected []byte, value []byte) (bool, error) {
+	return store.putIf(key, value, func(current *Entry) (bool, error) {
+		return store.valueEquals(current, expected)
+	})
+}
+
+// PutIfAbsent puts the key value pair if the key is missing, and returns true if it did
+func (store *KVStore[Key]) PutIfAbsent(key Key, value []byte) (bool, error) {
+	return store.putIf(key, value, func(current *Entry) (bool, error) {
+		return current == nil, nil
+	})
+}
+
+// DeleteIfEquals deletes the key if its current value is equal to expected, and returns true if it did. A missing key never matches.
+func (store *KVStore[Key]) DeleteIfEquals(key Key, expected []byte) (bool, error) {
+	return store.deleteIf(key, func(current *Entry) (bool, error) {
+		return store.valueEquals(current, expected)
+	})
+}
+
+// GetVersioned gets the value of the key along with its version. A missing key is reported as ErrKeyNotFound.
+func (store *KVStore[Key]) GetVersioned(key Key) ([]byte, Version, error) {
+	defer store.readLatency.since(time.Now())
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return nil, 0, ErrClosed
+	}
+	entry, ok := store.keyDirectory.Get(key)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %v", ErrKeyNotFound, key)
+	}
+	value, err := store.read(entry)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, store.versionOf(entry), nil
+}
+
+// CompareVersionAndSwap puts value as the value of the key if the key is still at version, as returned by GetVersioned, and returns true if it did
+func (store *KVStore[Key]) CompareVersionAndSwap(key Key, version Version, value []byte) (bool, error) {
+	return store.putIf(key, value, func(current *Entry) (bool, error) {
+		return current != nil && store.versionOf(current) == version, nil
+	})
+}
+
+// DeleteIfVersion deletes the key if it is still at version, as returned by GetVersioned, and returns true if it did
+func (store *KVStore[Key]) DeleteIfVersion(key Key, version Version) (bool, error) {
+	return store.deleteIf(key, func(current *Entry) (bool, error) {
+		return current != nil && store.versionOf(current) == version, nil
+	})
+}
+
+// putIf puts the key value pair if matches returns true for the current entry of the key, which is nil for a missing key
+func (store *KVStore[Key]) putIf(key Key, value []byte, matches func(current *Entry) (bool, error)) (bool, error) {
+	defer store.writeLatency.since(time.Now())
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return false, ErrClosed
+	}
+	current, _ := store.keyDirectory.Get(key)
+	if ok, err := matches(current); !ok || err != nil {
+		return false, err
+	}
+	if err := store.put(key, value); err != nil {
+		return false, err
+	}
+	store.signalAppended()
+	return true, nil
+}
+
+// deleteIf deletes the key if matches returns true for the current entry of the key, which is nil for a missing key
+func (store *KVStore[Key]) deleteIf(key Key, matches func(current *Entry) (bool, error)) (bool, error) {
+	defer store.writeLatency.since(time.Now())
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return false, ErrClosed
+	}
+	current, _ := store.keyDirectory.Get(key)
+	if ok, err := matches(current); !ok || err != nil {
+		return false, err
+	}
+	if err := store.delete(key); err != nil {
+		return false, err
+	}
+	store.signalAppended()
+	return true, nil
+}
+
+// valueEquals reads the value of the entry, which is nil for a missing key, and compares it with expected. The lock must be held.
+func (store *KVStore[Key]) valueEquals(entry *Entry, expected []byte) (bool, error) {
+	if entry == nil {
+		return false, nil
+	}
+	value, err := store.read(entry)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(value, expected), nil
+}
+
+// versionOf returns the version of the entry
+func (store *KVStore[Key]) versionOf(entry *Entry) Version {
+	if entry.Version == 0 {
+		return store.epoch
+	}
+	return entry.Version
+}
@@ -6,6 +6,7 @@ type Entry struct {
 	FileId      uint64
 	Offset      int64
 	EntryLength uint32
+	Version     Version // the write of the key this entry holds, 0 for an entry reloaded from the segments
 }
 
 func NewEntryFrom(appendResponse *kv.AppendEntryResponse) *Entry {
@@ -0,0 +1,18 @@
+package kv
+
+import "errors"
+
+var (
+	// ErrKeyNotFound is returned by Get when the key is not present in the store
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrClosed is returned by every operation performed after the store has been shutdown
+	ErrClosed = errors.New("store is closed")
+	// ErrPositionCompacted is returned by a LogReader when the segment of its position has been removed by merge
+	ErrPositionCompacted = errors.New("position compacted")
+	// ErrConflict is returned by the commit of a transaction when a key it read was written since the transaction began
+	ErrConflict = errors.New("transaction conflict")
+	// ErrTxnDone is returned by every operation of a transaction performed after it is committed or discarded
+	ErrTxnDone = errors.New("transaction is done")
+	// ErrDefaultKeyspace is returned when dropping the default keyspace, which can not be dropped
+	ErrDefaultKeyspace = errors.New("default keyspace can not be dropped")
+)
@@ -3,17 +3,22 @@ package kv
 import (
 	"ashishkujoy/bitcask/config"
 	log "ashishkujoy/bitcask/kv/log"
+	"slices"
+
 	iradix "github.com/hashicorp/go-immutable-radix/v2"
 )
 
 type KeyDirectory[Key config.BitcaskKey] struct {
 	entryByKey *iradix.Tree[*Entry]
+	keyCodec   config.KeyCodec[Key]
+	liveBytes  int64 // sum of the lengths of the entries the keys point to
 }
 
-// NewKeyDirectory Creates a new instance of KeyDirectory
-func NewKeyDirectory[Key config.BitcaskKey]() *KeyDirectory[Key] {
+// NewKeyDirectory Creates a new instance of KeyDirectory which indexes the keys by the bytes keyCodec encodes them to
+func NewKeyDirectory[Key config.BitcaskKey](keyCodec config.KeyCodec[Key]) *KeyDirectory[Key] {
 	return &KeyDirectory[Key]{
 		entryByKey: iradix.New[*Entry](),
+		keyCodec:   keyCodec,
 	}
 }
 
@@ -21,33 +26,92 @@ func NewKeyDirectory[Key config.BitcaskKey]() *KeyDirectory[Key] {
 // and the keys from all the inactive segments are stored in the KeyDirectory.
 // Riak's paper optimizes reloading by creating small sized hint files during merge and compaction.
 // Hint files contain the keys and the metadata fields like fileId, fileOffset and entryLength, these hint files are referred during reload. This implementation does not create Hint file
+// Segments must be reloaded in the order they were written, so that a later entry of a key overrides an earlier one.
 func (keyDirectory *KeyDirectory[Key]) Reload(fileId uint64, entries []*log.MappedStoredEntry[Key]) {
 	for _, entry := range entries {
-		keyDirectory.Put(entry.Key, NewEntry(fileId, int64(entry.KeyOffset), entry.EntryLength))
+		keyDirectory.reloadEntry(fileId, entry)
 	}
 }
 
+// reloadEntry reloads a single entry of the segment identified by fileId. A delete entry removes the key put by an earlier entry.
+func (keyDirectory *KeyDirectory[Key]) reloadEntry(fileId uint64, entry *log.MappedStoredEntry[Key]) {
+	if entry.Deleted {
+		keyDirectory.Delete(entry.Key)
+		return
+	}
+	keyDirectory.Put(entry.Key, NewEntry(fileId, int64(entry.KeyOffset), entry.EntryLength))
+}
+
 // Put puts a key and its entry as the value in the KeyDirectory
 func (keyDirectory *KeyDirectory[Key]) Put(key Key, value *Entry) {
-	keyDirectory.entryByKey, _, _ = keyDirectory.entryByKey.Insert(key.Serialize(), value)
+	var previous *Entry
+	var updated bool
+	keyDirectory.entryByKey, previous, updated = keyDirectory.entryByKey.Insert(keyDirectory.keyCodec.Encode(key), value)
+	if updated {
+		keyDirectory.liveBytes -= int64(previous.EntryLength)
+	}
+	keyDirectory.liveBytes += int64(value.EntryLength)
 }
 
 // BulkUpdate performs bulk changes to the KeyDirectory state. This method is called during merge and compaction from KeyStore.
-func (keyDirectory *KeyDirectory[Key]) BulkUpdate(changes []*log.WriteBackResponse[Key]) {
+// Keys written again or deleted since the segments identified by mergedFileIds were read are left as they are.
+func (keyDirectory *KeyDirectory[Key]) BulkUpdate(mergedFileIds []uint64, changes []*log.WriteBackResponse[Key]) {
 	for _, change := range changes {
-		keyDirectory.Put(change.Key, NewEntryFrom(change.AppendEntryResponse))
+		existing, ok := keyDirectory.Get(change.Key)
+		if !ok || !slices.Contains(mergedFileIds, existing.FileId) {
+			continue
+		}
+		entry := NewEntryFrom(change.AppendEntryResponse)
+		entry.Version = existing.Version
+		keyDirectory.Put(change.Key, entry)
 	}
 }
 
 // Delete removes the key from the KeyDirectory
 func (keyDirectory *KeyDirectory[Key]) Delete(key Key) {
-	keyDirectory.entryByKey, _, _ = keyDirectory.entryByKey.Delete(key.Serialize())
+	var previous *Entry
+	var deleted bool
+	keyDirectory.entryByKey, previous, deleted = keyDirectory.entryByKey.Delete(keyDirectory.keyCodec.Encode(key))
+	if deleted {
+		keyDirectory.liveBytes -= int64(previous.EntryLength)
+	}
 }
 
 // Get returns the Entry and a boolean to indicate if the value corresponding to the key is present in the KeyDirectory.
 // Get returns nil, false if the value corresponding to the key is not present
 // Get returns a pointer to an Entry, true if the value corresponding to the key is present
 func (keyDirectory *KeyDirectory[Key]) Get(key Key) (*Entry, bool) {
-	value, ok := keyDirectory.entryByKey.Get(key.Serialize())
+	value, ok := keyDirectory.entryByKey.Get(keyDirectory.keyCodec.Encode(key))
 	return value, ok
 }
+
+// GetEncoded is Get for a key which is already encoded by the key codec
+func (keyDirectory *KeyDirectory[Key]) GetEncoded(encodedKey []byte) (*Entry, bool) {
+	return keyDirectory.entryByKey.Get(encodedKey)
+}
+
+// Len returns the number of keys present in the KeyDirectory
+func (keyDirectory *KeyDirectory[Key]) Len() int {
+	return keyDirectory.entryByKey.Len()
+}
+
+// LiveBytes returns the number of bytes of the entries the keys point to, which merge would keep
+func (keyDirectory *KeyDirectory[Key]) LiveBytes() int64 {
+	return keyDirectory.liveBytes
+}
+
+// Snapshot returns a KeyDirectory which is not affected by the changes made to this KeyDirectory from now on
+func (keyDirectory *KeyDirectory[Key]) Snapshot() *KeyDirectory[Key] {
+	return &KeyDirectory[Key]{
+		entryByKey: keyDirectory.entryByKey,
+		keyCodec:   keyDirectory.keyCodec,
+		liveBytes:  keyDirectory.liveBytes,
+	}
+}
+
+// WalkPrefix calls fn for every key whose encoded bytes start with prefix, in the order of the encoded bytes. fn returns false to stop the walk.
+func (keyDirectory *KeyDirectory[Key]) WalkPrefix(prefix []byte, fn func(encodedKey []byte, entry *Entry) bool) {
+	keyDirectory.entryByKey.Root().WalkPrefix(prefix, func(encodedKey []byte, entry *Entry) bool {
+		return !fn(encodedKey, entry)
+	})
+}
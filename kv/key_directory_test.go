@@ -1,6 +1,7 @@
 package kv
 
 import (
+	"ashishkujoy/bitcask/config"
 	log "ashishkujoy/bitcask/kv/log"
 	"testing"
 
@@ -13,8 +14,10 @@ func (key serializableKey) Serialize() []byte {
 	return []byte(key)
 }
 
+var keyCodec = config.StringKeyCodec[serializableKey]{}
+
 func TestPutsAKeyInKeyDirectory(t *testing.T) {
-	keyDirectory := NewKeyDirectory[serializableKey]()
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
 	keyDirectory.Put("topic", NewEntry(1, 10, 20))
 
 	entry, _ := keyDirectory.Get("topic")
@@ -22,7 +25,7 @@ func TestPutsAKeyInKeyDirectory(t *testing.T) {
 }
 
 func TestDeletesAKeyInKeyDirectory(t *testing.T) {
-	keyDirectory := NewKeyDirectory[serializableKey]()
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
 	keyDirectory.Put("topic", NewEntry(1, 10, 20))
 
 	entry, _ := keyDirectory.Get("topic")
@@ -33,15 +36,31 @@ func TestDeletesAKeyInKeyDirectory(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestReloadRemovesAKeyWhoseLatestEntryIsADelete(t *testing.T) {
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
+	keyDirectory.Reload(1, []*log.MappedStoredEntry[serializableKey]{
+		{Key: "topic", KeyOffset: 10, EntryLength: 20},
+		{Key: "disk", KeyOffset: 30, EntryLength: 20},
+	})
+	keyDirectory.Reload(2, []*log.MappedStoredEntry[serializableKey]{
+		{Key: "topic", Deleted: true, KeyOffset: 10, EntryLength: 18},
+	})
+
+	_, ok := keyDirectory.Get("topic")
+	require.False(t, ok)
+	entry, _ := keyDirectory.Get("disk")
+	require.Equal(t, NewEntry(1, 30, 20), entry)
+}
+
 func TestGetANonExistentKeyInKeyDirectory(t *testing.T) {
-	keyDirectory := NewKeyDirectory[serializableKey]()
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
 
 	_, ok := keyDirectory.Get("non-existing")
 	require.False(t, ok)
 }
 
 func TestBulkUpdatesKeys(t *testing.T) {
-	keyDirectory := NewKeyDirectory[serializableKey]()
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
 	response := &log.WriteBackResponse[serializableKey]{
 		Key: "topic",
 		AppendEntryResponse: &log.AppendEntryResponse{
@@ -59,7 +78,10 @@ func TestBulkUpdatesKeys(t *testing.T) {
 		},
 	}
 
-	keyDirectory.BulkUpdate([]*log.WriteBackResponse[serializableKey]{response, otherResponse})
+	keyDirectory.Put("topic", NewEntry(1, 0, 36))
+	keyDirectory.Put("disk", NewEntry(2, 0, 46))
+
+	keyDirectory.BulkUpdate([]uint64{1, 2}, []*log.WriteBackResponse[serializableKey]{response, otherResponse})
 
 	entry, _ := keyDirectory.Get("topic")
 	require.Equal(t, entry, NewEntry(10, 30, 36))
@@ -67,3 +89,64 @@ func TestBulkUpdatesKeys(t *testing.T) {
 	entry, _ = keyDirectory.Get("disk")
 	require.Equal(t, entry, NewEntry(20, 40, 46))
 }
+
+func TestBulkUpdateKeepsKeysWrittenAfterTheMergedSegments(t *testing.T) {
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
+	keyDirectory.Put("topic", NewEntry(30, 0, 36))
+	keyDirectory.Put("disk", NewEntry(10, 36, 46))
+
+	keyDirectory.BulkUpdate([]uint64{10, 20}, []*log.WriteBackResponse[serializableKey]{
+		{Key: "topic", AppendEntryResponse: &log.AppendEntryResponse{FileId: 21, Offset: 0, EntryLength: 36}},
+		{Key: "disk", AppendEntryResponse: &log.AppendEntryResponse{FileId: 21, Offset: 36, EntryLength: 46}},
+	})
+
+	entry, _ := keyDirectory.Get("topic")
+	require.Equal(t, NewEntry(30, 0, 36), entry)
+
+	entry, _ = keyDirectory.Get("disk")
+	require.Equal(t, NewEntry(21, 36, 46), entry)
+}
+
+func TestLiveBytesFollowTheEntriesOfTheKeys(t *testing.T) {
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
+	keyDirectory.Put("topic", NewEntry(1, 0, 20))
+	keyDirectory.Put("disk", NewEntry(1, 20, 15))
+	require.Equal(t, int64(35), keyDirectory.LiveBytes())
+
+	keyDirectory.Put("topic", NewEntry(1, 35, 30))
+	require.Equal(t, int64(45), keyDirectory.LiveBytes())
+
+	keyDirectory.Delete("disk")
+	keyDirectory.Delete("missing")
+	require.Equal(t, int64(30), keyDirectory.LiveBytes())
+}
+
+func TestBulkUpdateKeepsTheVersionOfTheMovedKeys(t *testing.T) {
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
+	entry := NewEntry(10, 0, 36)
+	entry.Version = 7
+	keyDirectory.Put("topic", entry)
+
+	keyDirectory.BulkUpdate([]uint64{10}, []*log.WriteBackResponse[serializableKey]{
+		{Key: "topic", AppendEntryResponse: &log.AppendEntryResponse{FileId: 11, Offset: 0, EntryLength: 36}},
+	})
+
+	moved, _ := keyDirectory.Get("topic")
+	require.Equal(t, &Entry{FileId: 11, Offset: 0, EntryLength: 36, Version: 7}, moved)
+}
+
+func TestBulkUpdateSkipsKeysDeletedAfterTheMergedSegmentsWereRead(t *testing.T) {
+	keyDirectory := NewKeyDirectory[serializableKey](keyCodec)
+	keyDirectory.Put("disk", NewEntry(10, 36, 46))
+
+	keyDirectory.BulkUpdate([]uint64{10, 20}, []*log.WriteBackResponse[serializableKey]{
+		{Key: "topic", AppendEntryResponse: &log.AppendEntryResponse{FileId: 21, Offset: 0, EntryLength: 36}},
+		{Key: "disk", AppendEntryResponse: &log.AppendEntryResponse{FileId: 21, Offset: 36, EntryLength: 46}},
+	})
+
+	_, ok := keyDirectory.Get("topic")
+	require.False(t, ok)
+
+	entry, _ := keyDirectory.Get("disk")
+	require.Equal(t, NewEntry(21, 36, 46), entry)
+}
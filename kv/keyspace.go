@@ -0,0 +1,346 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// defaultKeyspaceName names the default keyspace, whose keys are the ones read and written by the methods of KVStore
+const defaultKeyspaceName = ""
+
+// keyspace is a named keyspace, whose keys are indexed by a KeyDirectory of their own
+type keyspace[Key config.BitcaskKey] struct {
+	id           uint32
+	keyDirectory *KeyDirectory[Key]
+}
+
+// Keyspace is a named set of keys sharing the segments of the store, created by its first Put. The keyspace named "" is the default keyspace.
+type Keyspace[Key config.BitcaskKey] struct {
+	store *KVStore[Key]
+	name  string
+}
+
+// Keyspace returns the keyspace named name, which is created by its first Put
+func (store *KVStore[Key]) Keyspace(name string) *Keyspace[Key] {
+	return &Keyspace[Key]{store: store, name: name}
+}
+
+// Keyspaces returns the names of the keyspaces, other than the default keyspace, in order
+func (store *KVStore[Key]) Keyspaces() []string {
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	return slices.Sorted(maps.Keys(store.keyspaces))
+}
+
+// Name returns the name of the keyspace
+func (keyspace *Keyspace[Key]) Name() string {
+	return keyspace.name
+}
+
+// Put puts the key value pair in the keyspace, creating the keyspace if it does not exist
+func (keyspace *Keyspace[Key]) Put(key Key, value []byte) error {
+	store := keyspace.store
+	defer store.writeLatency.since(time.Now())
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return ErrClosed
+	}
+	if err := store.putIn(keyspace.name, key, value); err != nil {
+		return err
+	}
+	store.signalAppended()
+	return nil
+}
+
+// PutAll is KVStore.PutAll in the keyspace, creating the keyspace if it does not exist
+func (keyspace *Keyspace[Key]) PutAll(pairs []KeyValue[Key]) error {
+	store := keyspace.store
+	defer store.writeLatency.since(time.Now())
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return ErrClosed
+	}
+	defer store.signalAppended()
+	for _, pair := range pairs {
+		if err := store.putIn(keyspace.name, pair.Key, pair.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get gets the value of the key in the keyspace. A missing key, or a keyspace which does not exist, is reported as ErrKeyNotFound.
+func (keyspace *Keyspace[Key]) Get(key Key) ([]byte, error) {
+	value, ok, err := keyspace.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, key)
+	}
+	return value, nil
+}
+
+// Lookup gets the value of the key in the keyspace. Returns value, true and nil if the value is found, else returns nil, false and nil.
+func (keyspace *Keyspace[Key]) Lookup(key Key) ([]byte, bool, error) {
+	return keyspace.store.getEncoded(context.Background(), keyspace.name, keyspace.store.segments.KeyCodec().Encode(key))
+}
+
+// Delete deletes the key from the keyspace. Deleting from a keyspace which does not exist does nothing.
+func (keyspace *Keyspace[Key]) Delete(key Key) error {
+	store := keyspace.store
+	defer store.writeLatency.since(time.Now())
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return ErrClosed
+	}
+	if err := store.deleteIn(keyspace.name, key); err != nil {
+		return err
+	}
+	store.signalAppended()
+	return nil
+}
+
+// Scan is KVStore.Scan over the keys of the keyspace
+func (keyspace *Keyspace[Key]) Scan(prefix []byte, fn func(key Key, value []byte) bool) error {
+	return keyspace.store.scan(keyspace.name, prefix, fn)
+}
+
+// ScanKeys is KVStore.ScanKeys over the keys of the keyspace
+func (keyspace *Keyspace[Key]) ScanKeys(prefix []byte, fn func(key Key) bool) error {
+	return keyspace.store.scanKeys(keyspace.name, prefix, fn)
+}
+
+// Subscribe is KVStore.Subscribe to the keys of the keyspace, Drop being published as OpDropKeyspace
+func (keyspace *Keyspace[Key]) Subscribe(prefix []byte, bufferSize int, policy OverflowPolicy) *Subscription[Key] {
+	return keyspace.store.publisher.subscribe(keyspace.name, prefix, bufferSize, policy)
+}
+
+// Len returns the number of live keys of the keyspace
+func (keyspace *Keyspace[Key]) Len() int {
+	store := keyspace.store
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if keyDirectory := store.keyDirectoryOf(keyspace.name); keyDirectory != nil {
+		return keyDirectory.Len()
+	}
+	return 0
+}
+
+// Drop drops the keyspace along with all its keys, which merge then removes from the segments. The default keyspace can not be dropped.
+func (keyspace *Keyspace[Key]) Drop() error {
+	store := keyspace.store
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return ErrClosed
+	}
+	if keyspace.name == defaultKeyspaceName {
+		return ErrDefaultKeyspace
+	}
+	if _, ok := store.keyspaces[keyspace.name]; !ok {
+		return nil
+	}
+	if _, err := store.segments.AppendDeleteEncoded(kvlog.CatalogKeyspace, []byte(keyspace.name)); err != nil {
+		return err
+	}
+	delete(store.keyspaces, keyspace.name)
+	var zero Key
+	store.publisher.publish(keyspace.name, zero, nil, OpDropKeyspace, nil)
+	store.signalAppended()
+	return nil
+}
+
+// putIn is put for the keyspace named keyspaceName, creating the keyspace if it does not exist. The lock must be held.
+func (store *KVStore[Key]) putIn(keyspaceName string, key Key, value []byte) error {
+	if keyspaceName == defaultKeyspaceName {
+		return store.put(key, value)
+	}
+	if err := store.segments.Validate(key, value); err != nil {
+		return err
+	}
+	keyspace, err := store.createKeyspace(keyspaceName)
+	if err != nil {
+		return err
+	}
+	appendResponse, err := store.segments.AppendIn(keyspace.id, key, value)
+	if err != nil {
+		return err
+	}
+
+	entry := NewEntryFrom(appendResponse)
+	store.lastVersion++
+	entry.Version = store.lastVersion
+	keyspace.keyDirectory.Put(key, entry)
+	store.publisher.publish(keyspaceName, key, store.segments.KeyCodec().Encode(key), OpPut, value)
+	return nil
+}
+
+// deleteIn is delete for the keyspace named keyspaceName. The lock must be held.
+func (store *KVStore[Key]) deleteIn(keyspaceName string, key Key) error {
+	if keyspaceName == defaultKeyspaceName {
+		return store.delete(key)
+	}
+	keyspace, ok := store.keyspaces[keyspaceName]
+	if !ok {
+		return nil
+	}
+	if _, err := store.segments.AppendDeleteIn(keyspace.id, key); err != nil {
+		return err
+	}
+	keyspace.keyDirectory.Delete(key)
+	store.publisher.publish(keyspaceName, key, store.segments.KeyCodec().Encode(key), OpDelete, nil)
+	return nil
+}
+
+// createKeyspace returns the keyspace named name, after appending it to the catalog under a new ID if it does not exist. The lock must be held.
+func (store *KVStore[Key]) createKeyspace(name string) (*keyspace[Key], error) {
+	if keyspace, ok := store.keyspaces[name]; ok {
+		return keyspace, nil
+	}
+	if store.lastKeyspace+1 == kvlog.CatalogKeyspace {
+		return nil, fmt.Errorf("no ID is left for the keyspace %v", name)
+	}
+	id := store.lastKeyspace + 1
+	if _, err := store.segments.AppendEncoded(kvlog.CatalogKeyspace, []byte(name), binary.AppendUvarint(nil, uint64(id))); err != nil {
+		return nil, err
+	}
+	return store.registerKeyspace(name, id), nil
+}
+
+// registerKeyspace adds an empty keyspace named name, identified by id
+func (store *KVStore[Key]) registerKeyspace(name string, id uint32) *keyspace[Key] {
+	keyspace := &keyspace[Key]{id: id, keyDirectory: NewKeyDirectory(store.segments.KeyCodec())}
+	store.keyspaces[name] = keyspace
+	store.lastKeyspace = max(store.lastKeyspace, id)
+	return keyspace
+}
+
+// keyDirectoryOf returns the KeyDirectory of the keyspace named keyspaceName, or nil if the keyspace does not exist. The lock must be held.
+func (store *KVStore[Key]) keyDirectoryOf(keyspaceName string) *KeyDirectory[Key] {
+	if keyspaceName == defaultKeyspaceName {
+		return store.keyDirectory
+	}
+	if keyspace, ok := store.keyspaces[keyspaceName]; ok {
+		return keyspace.keyDirectory
+	}
+	return nil
+}
+
+// keyDirectoryById returns the KeyDirectory of the keyspace identified by id, or nil if the keyspace does not exist. The lock must be held.
+func (store *KVStore[Key]) keyDirectoryById(id uint32) *KeyDirectory[Key] {
+	if id == kvlog.DefaultKeyspace {
+		return store.keyDirectory
+	}
+	for _, keyspace := range store.keyspaces {
+		if keyspace.id == id {
+			return keyspace.keyDirectory
+		}
+	}
+	return nil
+}
+
+// keyspaceName returns the name of the keyspace identified by id, and false if the keyspace does not exist. The lock must be held.
+func (store *KVStore[Key]) keyspaceName(id uint32) (string, bool) {
+	if id == kvlog.DefaultKeyspace {
+		return defaultKeyspaceName, true
+	}
+	for name, keyspace := range store.keyspaces {
+		if keyspace.id == id {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// keyspacesLen returns the number of live keys of the keyspaces other than the default keyspace. The lock must be held.
+func (store *KVStore[Key]) keyspacesLen() int {
+	total := 0
+	for _, keyspace := range store.keyspaces {
+		total += keyspace.keyDirectory.Len()
+	}
+	return total
+}
+
+// reloadEntries reloads the entries of the segment identified by fileId into the KeyDirectory of their keyspace, creating and dropping keyspaces along the catalog
+func (store *KVStore[Key]) reloadEntries(fileId uint64, entries []*kvlog.MappedStoredEntry[Key]) error {
+	for _, entry := range entries {
+		switch entry.Keyspace {
+		case kvlog.DefaultKeyspace:
+			store.keyDirectory.reloadEntry(fileId, entry)
+		case kvlog.CatalogKeyspace:
+			name := string(entry.EncodedKey)
+			if entry.Deleted {
+				delete(store.keyspaces, name)
+				continue
+			}
+			id, err := decodeKeyspaceId(entry.Value)
+			if err != nil {
+				return fmt.Errorf("%w: entry of the catalog at offset %v of segment %v: %v", kvlog.ErrCorrupted, entry.KeyOffset, fileId, err)
+			}
+			store.registerKeyspace(name, id)
+		default:
+			store.lastKeyspace = max(store.lastKeyspace, entry.Keyspace)
+			if keyDirectory := store.keyDirectoryById(entry.Keyspace); keyDirectory != nil {
+				keyDirectory.reloadEntry(fileId, entry)
+			}
+		}
+	}
+	return nil
+}
+
+// liveEntries returns the merged entries of the catalog and of the keyspaces which still exist, the catalog first so that reload creates the keyspaces first. The lock must be held.
+func (store *KVStore[Key]) liveEntries(changes []*kvlog.MappedStoredEntry[Key]) []*kvlog.MappedStoredEntry[Key] {
+	var catalog, entries []*kvlog.MappedStoredEntry[Key]
+	for _, change := range changes {
+		switch change.Keyspace {
+		case kvlog.CatalogKeyspace:
+			keyspace, ok := store.keyspaces[string(change.EncodedKey)]
+			if id, err := decodeKeyspaceId(change.Value); !change.Deleted && ok && err == nil && keyspace.id == id {
+				catalog = append(catalog, change)
+			}
+		default:
+			if store.keyDirectoryById(change.Keyspace) != nil {
+				entries = append(entries, change)
+			}
+		}
+	}
+	return append(catalog, entries...)
+}
+
+// groupByKeyspace groups the responses of a write back by the ID of their keyspace
+func groupByKeyspace[Key config.BitcaskKey](responses []*kvlog.WriteBackResponse[Key]) map[uint32][]*kvlog.WriteBackResponse[Key] {
+	responsesByKeyspace := map[uint32][]*kvlog.WriteBackResponse[Key]{}
+	for _, response := range responses {
+		if response.Keyspace != kvlog.CatalogKeyspace {
+			responsesByKeyspace[response.Keyspace] = append(responsesByKeyspace[response.Keyspace], response)
+		}
+	}
+	return responsesByKeyspace
+}
+
+// decodeKeyspaceId decodes the ID of a keyspace from the value of an entry of the catalog
+func decodeKeyspaceId(value []byte) (uint32, error) {
+	id, size := binary.Uvarint(value)
+	if size <= 0 || id == uint64(kvlog.DefaultKeyspace) || id >= math.MaxUint32 {
+		return 0, errors.New("invalid keyspace ID")
+	}
+	return uint32(id), nil
+}
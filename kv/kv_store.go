@@ -3,27 +3,42 @@ package kv
 import (
 	"ashishkujoy/bitcask/config"
 	kvlog "ashishkujoy/bitcask/kv/log"
+	"context"
 	"fmt"
-	"sync"
+	"time"
 )
 
 // KVStore encapsulates append-only log segments and KeyDirectory which is an in-memory hashmap
 // Segments is an abstraction that manages the active and K inactive segments.
-// KVStore also maintains a RWLock that allows an exclusive writer and N readers
+// KVStore also maintains a lock, held by every operation on the segments and the KeyDirectories, so writes are versioned and published in commit order.
+// Unexported methods marked "The lock must be held" do not take the lock themselves.
 type KVStore[Key config.BitcaskKey] struct {
 	segments     *kvlog.Segments[Key]
 	keyDirectory *KeyDirectory[Key]
-	rwlock       sync.RWMutex
+	rwlock       storeLock
+	closed       bool
+	publisher    *publisher[Key]
+	appended     chan struct{} // closed, and replaced, every time entries are appended, to wake up the log readers
+	readLatency  *latencyHistogram
+	writeLatency *latencyHistogram
+	epoch        Version // the version of the entries reloaded from the segments
+	lastVersion  Version
+	keyspaces    map[string]*keyspace[Key] // the named keyspaces, the default keyspace being keyDirectory
+	lastKeyspace uint32                    // the largest ID of a keyspace found in the segments or created since, which is never reused
+}
+
+// StoreStats describes the keys and the segments of a store, along with the operations it served
+type StoreStats struct {
+	Keys      int   // keys of all the keyspaces
+	LiveBytes int64 // bytes of the entries the keys of all the keyspaces point to
+	kvlog.SegmentStats
+	OperationStats
 }
 
 // NewKVStore creates a new instance of KVStore
 // It also performs a reload operation `store.reload(config)` that is responsible for reloading the state of KeyDirectory from inactive segments
 func NewKVStore[Key config.BitcaskKey](config *config.Config[Key]) (*KVStore[Key], error) {
-	segments, err := kvlog.NewSegments[Key](
-		config.Directory(),
-		config.MaxSegmentSizeInBytes(),
-		config.Clock(),
-	)
+	segments, err := kvlog.NewSegmentsFromConfig(config)
 
 	if err != nil {
 		return nil, err
@@ -31,10 +46,18 @@ func NewKVStore[Key config.BitcaskKey](config *config.Config[Key]) (*KVStore[Key
 
 	store := &KVStore[Key]{
 		segments:     segments,
-		keyDirectory: NewKeyDirectory[Key](),
+		keyDirectory: NewKeyDirectory(config.KeyCodec()),
+		rwlock:       newStoreLock(),
+		publisher:    newPublisher[Key](),
+		appended:     make(chan struct{}),
+		readLatency:  newLatencyHistogram(),
+		writeLatency: newLatencyHistogram(),
+		epoch:        Version(time.Now().UnixNano()),
+		keyspaces:    map[string]*keyspace[Key]{},
 	}
+	store.lastVersion = store.epoch
 
-	if err := store.reload(config); err != nil {
+	if err := store.reload(); err != nil {
 		return nil, err
 	}
 	return store, nil
@@ -45,15 +68,67 @@ func NewKVStore[Key config.BitcaskKey](config *config.Config[Key]) (*KVStore[Key
 // - Segments abstraction will append the key and the value to the active segment if the size of the active segment is less than the threshold, else it will perform a rollover of the active segment
 // 2.Once the append operation is successful, it will write the key and the Entry to the KeyDirectory, which is an in-memory representation of the key and its position in an append-only segment
 func (store *KVStore[Key]) Put(key Key, value []byte) error {
-	store.rwlock.Lock()
+	return store.PutContext(context.Background(), key, value)
+}
+
+// PutContext is Put giving up with ctx.Err() if ctx is done before the lock is acquired
+func (store *KVStore[Key]) PutContext(ctx context.Context, key Key, value []byte) error {
+	defer store.writeLatency.since(time.Now())
+	if err := store.lockContext(ctx); err != nil {
+		return err
+	}
 	defer store.rwlock.Unlock()
 
+	if store.closed {
+		return ErrClosed
+	}
+	if err := store.put(key, value); err != nil {
+		return err
+	}
+	store.signalAppended()
+	return nil
+}
+
+// put appends the key value pair, points the key to its new entry and publishes the change. The lock must be held.
+func (store *KVStore[Key]) put(key Key, value []byte) error {
 	appendResponse, err := store.segments.Append(key, value)
 	if err != nil {
 		return err
 	}
+	store.applyPut(key, value, appendResponse)
+	return nil
+}
+
+// applyPut points the key to its appended entry, with a new version, and publishes the put
+func (store *KVStore[Key]) applyPut(key Key, value []byte, appendResponse *kvlog.AppendEntryResponse) {
+	entry := NewEntryFrom(appendResponse)
+	store.lastVersion++
+	entry.Version = store.lastVersion
+	store.keyDirectory.Put(key, entry)
+	store.publisher.publish(defaultKeyspaceName, key, store.segments.KeyCodec().Encode(key), OpPut, value)
+}
+
+// KeyValue is a key along with its value
+type KeyValue[Key config.BitcaskKey] struct {
+	Key   Key
+	Value []byte
+}
 
-	store.keyDirectory.Put(key, NewEntryFrom(appendResponse))
+// PutAll puts all the key value pairs in order, holding the lock once. It is not atomic: the pairs before a failed one stay put.
+func (store *KVStore[Key]) PutAll(pairs []KeyValue[Key]) error {
+	defer store.writeLatency.since(time.Now())
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return ErrClosed
+	}
+	defer store.signalAppended()
+	for _, pair := range pairs {
+		if err := store.put(pair.Key, pair.Value); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -63,101 +138,326 @@ func (store *KVStore[Key]) Update(key Key, value []byte) error {
 }
 
 func (store *KVStore[Key]) Delete(key Key) error {
-	store.rwlock.Lock()
+	return store.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is Delete giving up with ctx.Err() if ctx is done before the lock is acquired
+func (store *KVStore[Key]) DeleteContext(ctx context.Context, key Key) error {
+	defer store.writeLatency.since(time.Now())
+	if err := store.lockContext(ctx); err != nil {
+		return err
+	}
 	defer store.rwlock.Unlock()
-	_, err := store.segments.AppendDelete(key)
-	if err != nil {
+
+	if store.closed {
+		return ErrClosed
+	}
+	if err := store.delete(key); err != nil {
 		return err
 	}
-	store.keyDirectory.Delete(key)
+	store.signalAppended()
 	return nil
 }
 
-// SilentGet Gets the value corresponding to the key. Returns value and true if the value is found, else returns nil and false
-// In order to perform SilentGet, a Get operation is performed in the KeyDirectory which returns an Entry indicating the fileId containing the key, offset of the key and the entry length
-// If an Entry corresponding to the key is found, a Read operation is performed in the Segments abstraction, which performs an in-memory lookup to identify the segment based on the fileId, and then a Read operation is performed in that Segment
+// delete appends a delete entry of the key, removes the key and publishes the change. The lock must be held.
+func (store *KVStore[Key]) delete(key Key) error {
+	if _, err := store.segments.AppendDelete(key); err != nil {
+		return err
+	}
+	store.applyDelete(key)
+	return nil
+}
+
+// applyDelete removes the key whose delete entry is appended, and publishes the delete
+func (store *KVStore[Key]) applyDelete(key Key) {
+	store.keyDirectory.Delete(key)
+	store.publisher.publish(defaultKeyspaceName, key, store.segments.KeyCodec().Encode(key), OpDelete, nil)
+}
+
+// Subscribe returns a subscription to the committed writes of the keys whose encoded bytes start with prefix, buffering up to bufferSize events
+func (store *KVStore[Key]) Subscribe(prefix []byte, bufferSize int, policy OverflowPolicy) *Subscription[Key] {
+	return store.publisher.subscribe(defaultKeyspaceName, prefix, bufferSize, policy)
+}
+
+// SilentGet Gets the value corresponding to the key. Returns value and true if the value is found, else returns nil and false. Read failures read as missing.
 func (store *KVStore[Key]) SilentGet(key Key) ([]byte, bool) {
+	value, ok, err := store.Lookup(key)
+	return value, ok && err == nil
+}
+
+// Lookup gets the value corresponding to the key. Returns value, true and nil if the value is found, else returns nil, false and nil.
+func (store *KVStore[Key]) Lookup(key Key) ([]byte, bool, error) {
+	return store.getEncoded(context.Background(), defaultKeyspaceName, store.segments.KeyCodec().Encode(key))
+}
+
+// Get gets the value corresponding to the key. Returns value and nil if the value is found, else returns nil and error (ErrKeyNotFound if missing)
+// In order to perform Get, a Get operation is performed in the KeyDirectory which returns an Entry indicating the fileId, offset of the key and the entry length
+// If an Entry corresponding to the key is found, a Read operation is performed in the Segments abstraction, which performs an in-memory lookup to identify the segment based on the fileId, and then a Read operation is performed in that Segment
+func (store *KVStore[Key]) Get(key Key) ([]byte, error) {
+	return store.GetContext(context.Background(), key)
+}
+
+// GetContext is Get giving up with ctx.Err() if ctx is done before the lock is acquired
+func (store *KVStore[Key]) GetContext(ctx context.Context, key Key) ([]byte, error) {
+	value, ok, err := store.getEncoded(ctx, defaultKeyspaceName, store.segments.KeyCodec().Encode(key))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, key)
+	}
+	return value, nil
+}
+
+// Scan calls fn with every key starting with prefix, in encoded order, and its value, until fn returns false. The keys are those present when the scan begins.
+func (store *KVStore[Key]) Scan(prefix []byte, fn func(key Key, value []byte) bool) error {
+	return store.scan(defaultKeyspaceName, prefix, fn)
+}
+
+// ScanKeys is Scan without reading the values
+func (store *KVStore[Key]) ScanKeys(prefix []byte, fn func(key Key) bool) error {
+	return store.scanKeys(defaultKeyspaceName, prefix, fn)
+}
+
+// scan is Scan over the keys of the keyspace named keyspaceName
+func (store *KVStore[Key]) scan(keyspaceName string, prefix []byte, fn func(key Key, value []byte) bool) error {
+	snapshot, err := store.snapshotOf(keyspaceName)
+	if err != nil || snapshot == nil {
+		return err
+	}
+
+	snapshot.WalkPrefix(prefix, func(encodedKey []byte, _ *Entry) bool {
+		key, decodeErr := store.segments.KeyCodec().Decode(encodedKey)
+		if decodeErr != nil {
+			err = decodeErr
+			return false
+		}
+		value, ok, readErr := store.getEncoded(context.Background(), keyspaceName, encodedKey)
+		if readErr != nil {
+			err = readErr
+			return false
+		}
+		if !ok {
+			return true
+		}
+		return fn(key, value)
+	})
+	return err
+}
+
+// scanKeys is ScanKeys over the keys of the keyspace named keyspaceName
+func (store *KVStore[Key]) scanKeys(keyspaceName string, prefix []byte, fn func(key Key) bool) error {
+	snapshot, err := store.snapshotOf(keyspaceName)
+	if err != nil || snapshot == nil {
+		return err
+	}
+
+	snapshot.WalkPrefix(prefix, func(encodedKey []byte, _ *Entry) bool {
+		key, decodeErr := store.segments.KeyCodec().Decode(encodedKey)
+		if decodeErr != nil {
+			err = decodeErr
+			return false
+		}
+		return fn(key)
+	})
+	return err
+}
+
+// snapshotOf takes a snapshot of the KeyDirectory of the keyspace named keyspaceName, which is nil for a keyspace that does not exist
+func (store *KVStore[Key]) snapshotOf(keyspaceName string) (*KeyDirectory[Key], error) {
 	store.rwlock.Lock()
 	defer store.rwlock.Unlock()
 
-	entry, ok := store.keyDirectory.Get(key)
-	if !ok {
-		return nil, false
+	if store.closed {
+		return nil, ErrClosed
 	}
+	keyDirectory := store.keyDirectoryOf(keyspaceName)
+	if keyDirectory == nil {
+		return nil, nil
+	}
+	return keyDirectory.Snapshot(), nil
+}
 
-	storedEntry, err := store.segments.Read(entry.FileId, entry.Offset, entry.EntryLength)
+// Len returns the number of live keys
+func (store *KVStore[Key]) Len() int {
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	return store.keyDirectory.Len()
+}
+
+// Stats returns the number of keys, the live bytes, the sizes of the segments and the latencies of the reads and writes served so far
+func (store *KVStore[Key]) Stats() (StoreStats, error) {
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return StoreStats{}, ErrClosed
+	}
+	segmentStats, err := store.segments.Stats()
 	if err != nil {
-		return nil, false
+		return StoreStats{}, err
+	}
+	stats := StoreStats{
+		Keys:         store.keyDirectory.Len(),
+		LiveBytes:    store.keyDirectory.LiveBytes(),
+		SegmentStats: segmentStats,
+		OperationStats: OperationStats{
+			ReadLatency:  store.readLatency.snapshot(),
+			WriteLatency: store.writeLatency.snapshot(),
+		},
+	}
+	for _, keyspace := range store.keyspaces {
+		stats.Keys += keyspace.keyDirectory.Len()
+		stats.LiveBytes += keyspace.keyDirectory.LiveBytes()
 	}
+	return stats, nil
+}
 
-	return storedEntry.Value, true
+// KeyCodec returns the codec used to convert keys to and from the bytes stored in the segments
+func (store *KVStore[Key]) KeyCodec() config.KeyCodec[Key] {
+	return store.segments.KeyCodec()
 }
 
-// Get gets the value corresponding to the key. Returns value and nil if the value is found, else returns nil and error
-// In order to perform Get, a Get operation is performed in the KeyDirectory which returns an Entry indicating the fileId, offset of the key and the entry length
-// If an Entry corresponding to the key is found, a Read operation is performed in the Segments abstraction, which performs an in-memory lookup to identify the segment based on the fileId, and then a Read operation is performed in that Segment
-func (store *KVStore[Key]) Get(key Key) ([]byte, error) {
-	store.rwlock.Lock()
+// getEncoded is Lookup of the encoded key in the keyspace named keyspaceName
+func (store *KVStore[Key]) getEncoded(ctx context.Context, keyspaceName string, encodedKey []byte) ([]byte, bool, error) {
+	defer store.readLatency.since(time.Now())
+	if err := store.lockContext(ctx); err != nil {
+		return nil, false, err
+	}
 	defer store.rwlock.Unlock()
 
-	entry, ok := store.keyDirectory.Get(key)
+	if store.closed {
+		return nil, false, ErrClosed
+	}
+	keyDirectory := store.keyDirectoryOf(keyspaceName)
+	if keyDirectory == nil {
+		return nil, false, nil
+	}
+	entry, ok := keyDirectory.GetEncoded(encodedKey)
 	if !ok {
-		return nil, fmt.Errorf("key %v not present in store", key)
+		return nil, false, nil
 	}
 
-	storedEntry, err := store.segments.Read(entry.FileId, entry.Offset, entry.EntryLength)
+	value, err := store.read(entry)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
 
+// read reads the value of the entry from its segment. The lock must be held.
+func (store *KVStore[Key]) read(entry *Entry) ([]byte, error) {
+	storedEntry, err := store.segments.Read(entry.FileId, entry.Offset, entry.EntryLength)
 	if err != nil {
 		return nil, err
 	}
-
 	return storedEntry.Value, nil
 }
 
 // ReadInactiveSegments reads inactive segments identified by `totalSegments`. This operation is performed during merge.
-// keyMapper is used to map a byte slice Key to a generically typed Key. keyMapper is basically a means to perform deserialization of keys which is necessary to update the state in KeyDirectory after the merge operation is done, more on this is mentioned in KeyDirectory.go
-func (store *KVStore[Key]) ReadInactiveSegments(
-	totalSegments int,
-	keyMapper func([]byte) Key,
-) ([]uint64, [][]*kvlog.MappedStoredEntry[Key], error) {
-	store.rwlock.Lock()
+// Keys are decoded using the configured key codec
+func (store *KVStore[Key]) ReadInactiveSegments(totalSegments int) ([]uint64, [][]*kvlog.MappedStoredEntry[Key], error) {
+	return store.ReadInactiveSegmentsContext(context.Background(), totalSegments)
+}
+
+// ReadInactiveSegmentsContext is ReadInactiveSegments giving up with ctx.Err() once ctx is done
+func (store *KVStore[Key]) ReadInactiveSegmentsContext(ctx context.Context, totalSegments int) ([]uint64, [][]*kvlog.MappedStoredEntry[Key], error) {
+	if err := store.lockContext(ctx); err != nil {
+		return nil, nil, err
+	}
 	defer store.rwlock.Unlock()
 
-	return store.segments.ReadInactiveSegments(totalSegments, keyMapper)
+	if store.closed {
+		return nil, nil, ErrClosed
+	}
+	return store.segments.ReadInactiveSegmentsContext(ctx, totalSegments)
 }
 
 // ReadAllInactiveSegments reads all the inactive segments. This operation is performed during merge.
-// keyMapper is used to map a byte slice Key to a generically typed Key. keyMapper is basically a means to perform deserialization of keys which is necessary to update the state in KeyDirectory after the merge operation is done, more on this is mentioned in KeyDirectory.go and Worker.go inside merge/ package.
-func (store *KVStore[Key]) ReadAllInactiveSegments(
-	keyMapper func([]byte) Key,
-) ([]uint64, [][]*kvlog.MappedStoredEntry[Key], error) {
-	store.rwlock.Lock()
+// Keys are decoded using the configured key codec, more on this is mentioned in KeyDirectory.go and Worker.go inside merge/ package.
+func (store *KVStore[Key]) ReadAllInactiveSegments() ([]uint64, [][]*kvlog.MappedStoredEntry[Key], error) {
+	return store.ReadAllInactiveSegmentsContext(context.Background())
+}
+
+// ReadAllInactiveSegmentsContext is ReadAllInactiveSegments giving up with ctx.Err() once ctx is done
+func (store *KVStore[Key]) ReadAllInactiveSegmentsContext(ctx context.Context) ([]uint64, [][]*kvlog.MappedStoredEntry[Key], error) {
+	if err := store.lockContext(ctx); err != nil {
+		return nil, nil, err
+	}
 	defer store.rwlock.Unlock()
 
-	return store.segments.ReadAllInactiveSegments(keyMapper)
+	if store.closed {
+		return nil, nil, ErrClosed
+	}
+	return store.segments.ReadAllInactiveSegmentsContext(ctx)
 }
 
 // WriteBack writes back the changes (merged changes) to new inactive segments. This operation is performed during merge.
 // It writes all the changes into M new inactive segments and once those changes are written to the new inactive segment(s), the state of the keys present in the `changes` parameter is updated in the KeyDirectory. More on this is mentioned in Worker.go inside merge/ package.
 // Once the state is updated in the KeyDirectory, the old segments identified by `fileIds` are removed from disk.
 func (store *KVStore[Key]) WriteBack(fileIds []uint64, changes map[Key]*kvlog.MappedStoredEntry[Key]) error {
-	store.rwlock.Lock()
+	return store.WriteBackContext(context.Background(), fileIds, changes)
+}
+
+// WriteBackContext is WriteBack giving up with ctx.Err() if ctx is done before the changes start being written
+func (store *KVStore[Key]) WriteBackContext(ctx context.Context, fileIds []uint64, changes map[Key]*kvlog.MappedStoredEntry[Key]) error {
+	return store.WriteBackEntriesContext(ctx, fileIds, kvlog.EntriesOf(changes))
+}
+
+// WriteBackEntriesContext is WriteBackContext for the merged entries of every keyspace and of the catalog, leaving out the dropped keyspaces
+func (store *KVStore[Key]) WriteBackEntriesContext(ctx context.Context, fileIds []uint64, changes []*kvlog.MappedStoredEntry[Key]) error {
+	if err := store.lockContext(ctx); err != nil {
+		return err
+	}
 	defer store.rwlock.Unlock()
 
-	writeBackResponse, err := store.segments.WriteBack(changes)
+	if store.closed {
+		return ErrClosed
+	}
+	writeBackResponse, err := store.segments.WriteBackEntries(fileIds, store.liveEntries(changes))
 	if err != nil {
 		return err
 	}
-	store.keyDirectory.BulkUpdate(writeBackResponse)
+	for keyspaceId, responses := range groupByKeyspace(writeBackResponse) {
+		if keyDirectory := store.keyDirectoryById(keyspaceId); keyDirectory != nil {
+			keyDirectory.BulkUpdate(fileIds, responses)
+		}
+	}
 	store.segments.Remove(fileIds)
 	return nil
 }
 
+// PinSegments rolls over the active segment and pins all the inactive segments, whose files stay on disk until UnpinSegments. This operation is performed during backup.
+func (store *KVStore[Key]) PinSegments() ([]*kvlog.SegmentFile, error) {
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return nil, ErrClosed
+	}
+	if err := store.segments.RolloverActive(); err != nil {
+		return nil, err
+	}
+	return store.segments.Pin()
+}
+
+// UnpinSegments releases the pins taken by PinSegments on the segments identified by fileIds
+func (store *KVStore[Key]) UnpinSegments(fileIds []uint64) {
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	store.segments.Unpin(fileIds)
+}
+
 // ClearLog removes all the log files
 func (store *KVStore[Key]) Clear() {
 	store.rwlock.Lock()
 	defer store.rwlock.Unlock()
 
+	if store.closed {
+		return
+	}
 	store.segments.RemoveAllInactive()
 	store.segments.RemoveActive()
 }
@@ -167,29 +467,60 @@ func (store *KVStore[Key]) Sync() error {
 	store.rwlock.Lock()
 	defer store.rwlock.Unlock()
 
+	if store.closed {
+		return ErrClosed
+	}
 	return store.segments.Sync()
 }
 
 // Shutdown performs a shutdown of the segments which involves setting the active segment to nil and removing the entire in-memory representation of the inactive segments
+// Every operation performed after Shutdown returns ErrClosed, and all the subscriptions are closed.
 func (store *KVStore[Key]) Shutdown() {
+	store.publisher.close()
 	store.rwlock.Lock()
 	defer store.rwlock.Unlock()
 
+	if store.closed {
+		return
+	}
 	store.segments.Shutdown()
+	store.closed = true
+	store.signalAppended()
+}
+
+// lockContext acquires the lock, or returns ctx.Err() if ctx is done first
+func (store *KVStore[Key]) lockContext(ctx context.Context) error {
+	return store.rwlock.LockContext(ctx)
+}
+
+// signalAppended wakes up the log readers waiting for new entries. The lock must be held.
+func (store *KVStore[Key]) signalAppended() {
+	close(store.appended)
+	store.appended = make(chan struct{})
 }
 
 // reload the entire state during start-up.
-func (store *KVStore[Key]) reload(config *config.Config[Key]) error {
+func (store *KVStore[Key]) reload() error {
 	store.rwlock.Lock()
 	defer store.rwlock.Unlock()
 
-	for fileId, segment := range store.segments.AllInactiveSegments() {
-		entries, err := segment.ReadFull(config.MergeConfig().KeyMapper())
+	start := time.Now()
+	inactiveSegments := store.segments.AllInactiveSegments()
+	for _, fileId := range store.segments.InactiveFileIds() {
+		entries, err := inactiveSegments[fileId].ReadFull(store.segments.KeyCodec())
 		if err != nil {
+			store.segments.DetectCorruption(fileId, err)
+			return err
+		}
+		if err := store.reloadEntries(fileId, entries); err != nil {
 			return err
 		}
-		store.keyDirectory.Reload(fileId, entries)
 	}
 
+	store.segments.Listener().OnReloadFinished(config.ReloadFinished{
+		Segments: len(inactiveSegments),
+		Keys:     store.keyDirectory.Len() + store.keyspacesLen(),
+		Duration: time.Since(start),
+	})
 	return nil
 }
@@ -3,10 +3,15 @@ package kv
 import (
 	"ashishkujoy/bitcask/config"
 	kv "ashishkujoy/bitcask/kv/log"
+	"context"
+	"fmt"
 	"os"
+	"path"
 	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -134,7 +139,7 @@ func TestReadAPairOfInactiveSegments(t *testing.T) {
 	store.Put("Editor", []byte("Visual Studio Code, dark mode theme"))
 	store.Sync()
 
-	_, entries, _ := store.ReadInactiveSegments(2, config.MergeConfig().KeyMapper())
+	_, entries, _ := store.ReadInactiveSegments(2)
 	keys := toSortedKeys(entries)
 
 	require.Equal(t, 2, len(keys))
@@ -155,7 +160,7 @@ func TestReadAllInactiveSegments(t *testing.T) {
 	store.Put("Engine", []byte("Turbo Bitcask Engine"))
 	store.Put("Editor", []byte("Visual Studio Code, dark mode theme"))
 
-	_, entries, _ := store.ReadAllInactiveSegments(config.MergeConfig().KeyMapper())
+	_, entries, _ := store.ReadAllInactiveSegments()
 	keys := toSortedKeys(entries)
 	require.Equal(t, 3, len(keys))
 
@@ -171,12 +176,18 @@ func TestWriteBacks(t *testing.T) {
 	store, _ := NewKVStore(config)
 	defer store.Clear()
 
+	store.Put("disk", []byte("HDD"))
+	store.Put("engine", []byte("bitcask"))
+	store.Put("topic", []byte("Databases"))
+	store.Put("language", []byte("go"))
+	fileIds, _, _ := store.ReadAllInactiveSegments()
+
 	changes := make(map[serializableKey]*kv.MappedStoredEntry[serializableKey])
 	changes["disk"] = &kv.MappedStoredEntry[serializableKey]{Value: []byte("Solid State Disk")}
 	changes["engine"] = &kv.MappedStoredEntry[serializableKey]{Value: []byte("bitcask")}
 	changes["topic"] = &kv.MappedStoredEntry[serializableKey]{Value: []byte("Microservices")}
 
-	err := store.WriteBack([]uint64{1}, changes)
+	err := store.WriteBack(fileIds, changes)
 	require.NoError(t, err)
 
 	diskValue, _ := store.Get("disk")
@@ -189,6 +200,40 @@ func TestWriteBacks(t *testing.T) {
 	require.Equal(t, topicValue, []byte("Microservices"))
 }
 
+func TestWriteBackKeepsAKeyDeletedWhileMergeRanDeleted(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testWriteBackAfterDelete")
+	defer os.RemoveAll(tempDir)
+	config := config.NewConfig(tempDir, 8, config.NewMergeConfig(2, keyMapper))
+	store, _ := NewKVStore(config)
+
+	store.Put("Topic", []byte("Databases"))
+	store.Put("Disk", []byte("SSD"))
+	fileIds, entries, _ := store.ReadAllInactiveSegments()
+	changes := make(map[serializableKey]*kv.MappedStoredEntry[serializableKey])
+	for _, segmentEntries := range entries {
+		for _, entry := range segmentEntries {
+			changes[entry.Key] = entry
+		}
+	}
+
+	require.NoError(t, store.Delete("Topic"))
+	require.NoError(t, store.WriteBack(fileIds, changes))
+
+	_, ok := store.SilentGet("Topic")
+	require.False(t, ok)
+
+	store.Sync()
+	store.Shutdown()
+	reloadedStore, err := NewKVStore(config)
+	require.NoError(t, err)
+	defer reloadedStore.Clear()
+
+	_, ok = reloadedStore.SilentGet("Topic")
+	require.False(t, ok)
+	value, _ := reloadedStore.Get("Disk")
+	require.Equal(t, "SSD", string(value))
+}
+
 func TestReload(t *testing.T) {
 	tempDir, _ := os.MkdirTemp(os.TempDir(), "test")
 	defer os.RemoveAll(tempDir)
@@ -213,6 +258,49 @@ func TestReload(t *testing.T) {
 	require.Equal(t, diskTypeValue, []byte("solid state drive"))
 }
 
+func TestReloadKeepsTheLatestValueOfAKeyWrittenAcrossSegments(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testReloadOrder")
+	defer os.RemoveAll(tempDir)
+	config := config.NewConfig(tempDir, 8, config.NewMergeConfig(2, keyMapper))
+	store, _ := NewKVStore(config)
+
+	for version := 0; version < 20; version++ {
+		store.Put("topic", []byte(fmt.Sprintf("microservices-%v", version)))
+	}
+	store.Sync()
+	store.Shutdown()
+
+	newStore, err := NewKVStore(config)
+	require.NoError(t, err)
+	defer newStore.Clear()
+
+	value, err := newStore.Get("topic")
+	require.NoError(t, err)
+	require.Equal(t, "microservices-19", string(value))
+}
+
+func TestReloadKeepsADeletedKeyDeleted(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testReloadDelete")
+	defer os.RemoveAll(tempDir)
+	config := config.NewConfig(tempDir, 8, config.NewMergeConfig(2, keyMapper))
+	store, _ := NewKVStore(config)
+
+	store.Put("topic", []byte("microservices"))
+	store.Put("diskType", []byte("solid state drive"))
+	store.Delete("topic")
+	store.Sync()
+	store.Shutdown()
+
+	newStore, err := NewKVStore(config)
+	require.NoError(t, err)
+	defer newStore.Clear()
+
+	_, err = newStore.Get("topic")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	diskTypeValue, _ := newStore.Get("diskType")
+	require.Equal(t, []byte("solid state drive"), diskTypeValue)
+}
+
 func toSortedKeys(entries [][]*kv.MappedStoredEntry[serializableKey]) []string {
 	var keys []string
 
@@ -228,3 +316,316 @@ func toSortedKeys(entries [][]*kv.MappedStoredEntry[serializableKey]) []string {
 
 	return keys
 }
+
+func TestPutAndGetWithCompressionAcrossReload(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testCompression")
+	defer os.RemoveAll(tempDir)
+	config := config.NewConfigWithCompression(tempDir, 8, config.NewMergeConfig(2, keyMapper), config.GzipCompression)
+	store, _ := NewKVStore(config)
+
+	value := []byte(strings.Repeat(`{"engine":"bitcask"}`, 10))
+	store.Put("engine", value)
+	store.Put("disk", []byte("ssd"))
+	store.Sync()
+	store.Shutdown()
+
+	newStore, err := NewKVStore(config)
+	require.NoError(t, err)
+	defer newStore.Clear()
+
+	engineValue, _ := newStore.Get("engine")
+	require.Equal(t, value, engineValue)
+
+	diskValue, _ := newStore.Get("disk")
+	require.Equal(t, []byte("ssd"), diskValue)
+}
+
+func TestGetANonExistentKeyIsErrKeyNotFound(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testKeyNotFound")
+	defer os.RemoveAll(tempDir)
+	config := config.NewConfig(tempDir, 80, config.NewMergeConfig(2, keyMapper))
+	store, _ := NewKVStore(config)
+	defer store.Clear()
+
+	_, err := store.Get("Topic")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestLookupReportsAFailedRead(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testLookupFailedRead")
+	defer os.RemoveAll(tempDir)
+	config := config.NewConfig(tempDir, 80, config.NewMergeConfig(2, keyMapper))
+	store, _ := NewKVStore(config)
+	defer store.Clear()
+
+	store.Put("Topic", []byte("Database Systems"))
+	store.Sync()
+	files, _ := os.ReadDir(tempDir)
+	for _, file := range files {
+		os.Truncate(path.Join(tempDir, file.Name()), 4)
+	}
+
+	value, ok, err := store.Lookup("Topic")
+	require.Error(t, err)
+	require.False(t, ok)
+	require.Nil(t, value)
+
+	_, ok = store.SilentGet("Topic")
+	require.False(t, ok, "SilentGet reports a failed read as a missing key")
+}
+
+func TestOperationsAfterShutdown(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testOperationsAfterShutdown")
+	defer os.RemoveAll(tempDir)
+	config := config.NewConfig(tempDir, 80, config.NewMergeConfig(2, keyMapper))
+	store, _ := NewKVStore(config)
+	store.Put("Topic", []byte("Database Systems"))
+	store.Shutdown()
+
+	_, err := store.Get("Topic")
+	require.ErrorIs(t, err, ErrClosed)
+	require.ErrorIs(t, store.Put("Topic", []byte("Databases")), ErrClosed)
+	require.ErrorIs(t, store.Delete("Topic"), ErrClosed)
+	require.ErrorIs(t, store.Sync(), ErrClosed)
+}
+
+func TestSnapshotReadsThroughWritesAndMerge(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testSnapshot")
+	defer os.RemoveAll(tempDir)
+	config := config.NewConfig(tempDir, 8, config.NewMergeConfig(2, keyMapper))
+	store, _ := NewKVStore(config)
+	defer store.Clear()
+
+	store.Put("Topic", []byte("Databases"))
+	store.Put("Disk", []byte("SSD"))
+	snapshot, err := store.Snapshot()
+	require.NoError(t, err)
+
+	store.Put("Topic", []byte("Microservices"))
+	store.Delete("Disk")
+	fileIds, entries, _ := store.ReadAllInactiveSegments()
+	changes := make(map[serializableKey]*kv.MappedStoredEntry[serializableKey])
+	for _, segmentEntries := range entries {
+		for _, entry := range segmentEntries {
+			changes[entry.Key] = entry
+		}
+	}
+	require.NoError(t, store.WriteBack(fileIds, changes))
+
+	value, ok, err := snapshot.Get("Topic")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "Databases", string(value))
+
+	var keys []string
+	require.NoError(t, snapshot.Scan(nil, func(key serializableKey, value []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	}))
+	require.Equal(t, []string{"Disk", "Topic"}, keys)
+	snapshot.Release()
+
+	value, _ = store.Get("Topic")
+	require.Equal(t, "Microservices", string(value))
+}
+
+func TestSnapshotOfTheKeyspaces(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testSnapshotKeyspaces")
+	defer os.RemoveAll(tempDir)
+	store, _ := NewKVStore(config.NewConfig(tempDir, 1024, config.NewMergeConfig(2, keyMapper)))
+	defer store.Clear()
+
+	store.Keyspace("users").Put("Topic", []byte("Alice"))
+	store.Keyspace("users").Put("Disk", []byte("SSD"))
+	snapshot, err := store.Snapshot()
+	require.NoError(t, err)
+	defer snapshot.Release()
+
+	store.Keyspace("users").Delete("Disk")
+	store.Keyspace("orders").Put("Topic", []byte("Book"))
+
+	require.Equal(t, []string{"users"}, snapshot.Keyspaces())
+	require.Equal(t, 2, snapshot.KeyspaceLen("users"))
+	require.Equal(t, 0, snapshot.KeyspaceLen("orders"))
+	var keys []string
+	require.NoError(t, snapshot.ScanKeyspace("users", nil, func(key serializableKey, value []byte) bool {
+		keys = append(keys, string(key)+"="+string(value))
+		return true
+	}))
+	require.Equal(t, []string{"Disk=SSD", "Topic=Alice"}, keys)
+}
+
+func TestContextOperationsGiveUpWaitingForTheLock(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testContextOperations")
+	defer os.RemoveAll(tempDir)
+	store, _ := NewKVStore(config.NewConfig(tempDir, 8, config.NewMergeConfig(2, keyMapper)))
+	defer store.Clear()
+	require.NoError(t, store.Put("Topic", []byte("Databases")))
+
+	store.rwlock.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, store.PutContext(ctx, "Disk", []byte("SSD")), context.DeadlineExceeded)
+	_, err := store.GetContext(ctx, "Topic")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, store.DeleteContext(ctx, "Topic"), context.DeadlineExceeded)
+	store.rwlock.Unlock()
+
+	released := make(chan error, 1)
+	store.rwlock.Lock()
+	go func() { released <- store.PutContext(context.Background(), "Disk", []byte("SSD")) }()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := store.GetContext(ctx, "Topic")
+		released <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	store.rwlock.Unlock()
+	require.NoError(t, <-released)
+	require.NoError(t, <-released)
+
+	value, err := store.Get("Disk")
+	require.NoError(t, err)
+	require.Equal(t, "SSD", string(value))
+}
+
+func TestAContextOperationIsNotStarvedByConstantContention(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testContextContention")
+	defer os.RemoveAll(tempDir)
+	store, _ := NewKVStore(config.NewConfig(tempDir, 1024, config.NewMergeConfig(2, keyMapper)))
+	defer store.Clear()
+
+	stop := make(chan struct{})
+	var writers, started sync.WaitGroup
+	for range 8 {
+		writers.Add(1)
+		started.Add(1)
+		go func() {
+			defer writers.Done()
+			started.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					store.rwlock.Lock()
+					time.Sleep(time.Millisecond)
+					store.rwlock.Unlock()
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		writers.Wait()
+	}()
+	started.Wait()
+
+	for range 20 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		require.NoError(t, store.PutContext(ctx, "Topic", []byte("Databases")))
+		cancel()
+	}
+}
+
+func TestReadInactiveSegmentsWithACancelledContext(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testReadInactiveSegmentsContext")
+	defer os.RemoveAll(tempDir)
+	store, _ := NewKVStore(config.NewConfig(tempDir, 8, config.NewMergeConfig(2, keyMapper)))
+	defer store.Clear()
+	store.Put("Topic", []byte("Databases"))
+	store.Put("Disk", []byte("SSD"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := store.ReadAllInactiveSegmentsContext(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, entries, err := store.ReadAllInactiveSegmentsContext(context.Background())
+	require.NoError(t, err)
+	require.Len(t, toSortedKeys(entries), 1)
+}
+
+// recordingListener records the lifecycle events
+type recordingListener struct {
+	config.NoopListener
+	rolledOver []config.SegmentRolledOver
+	created    []uint64
+	removed    []uint64
+	reloads    []config.ReloadFinished
+	corruption []config.CorruptionDetected
+}
+
+func (listener *recordingListener) OnSegmentRolledOver(event config.SegmentRolledOver) {
+	listener.rolledOver = append(listener.rolledOver, event)
+}
+
+func (listener *recordingListener) OnSegmentCreated(event config.SegmentCreated) {
+	listener.created = append(listener.created, event.FileId)
+}
+
+func (listener *recordingListener) OnSegmentRemoved(event config.SegmentRemoved) {
+	listener.removed = append(listener.removed, event.FileId)
+}
+
+func (listener *recordingListener) OnReloadFinished(event config.ReloadFinished) {
+	listener.reloads = append(listener.reloads, event)
+}
+
+func (listener *recordingListener) OnCorruptionDetected(event config.CorruptionDetected) {
+	listener.corruption = append(listener.corruption, event)
+}
+
+func TestListenerIsToldAboutTheLifecycleEvents(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testListener")
+	defer os.RemoveAll(tempDir)
+	listener := &recordingListener{}
+	storeConfig, _ := config.New[serializableKey](
+		tempDir,
+		config.WithMaxSegmentSize(8),
+		config.WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}),
+		config.WithListener(listener),
+	)
+	store, err := NewKVStore(storeConfig)
+	require.NoError(t, err)
+	require.Len(t, listener.created, 1)
+	require.Equal(t, []config.ReloadFinished{{Duration: listener.reloads[0].Duration}}, listener.reloads)
+
+	store.Put("topic", []byte("microservices"))
+	store.Put("disk", []byte("ssd"))
+	store.Put("engine", []byte("bitcask"))
+	require.Len(t, listener.created, 3)
+	require.Len(t, listener.rolledOver, 2)
+	require.Equal(t, listener.created[0], listener.rolledOver[0].FileId)
+	require.Equal(t, listener.created[1], listener.rolledOver[0].NextFileId)
+	require.Positive(t, listener.rolledOver[0].Bytes)
+
+	fileIds, entries, _ := store.ReadAllInactiveSegments()
+	changes := make(map[serializableKey]*kv.MappedStoredEntry[serializableKey])
+	for _, segmentEntries := range entries {
+		for _, entry := range segmentEntries {
+			changes[entry.Key] = entry
+		}
+	}
+	require.NoError(t, store.WriteBack(fileIds, changes))
+	require.ElementsMatch(t, fileIds, listener.removed)
+	store.Sync()
+	store.Shutdown()
+
+	_, err = NewKVStore(storeConfig)
+	require.NoError(t, err)
+	require.Len(t, listener.reloads, 2)
+	require.Equal(t, 3, listener.reloads[1].Keys)
+	require.Empty(t, listener.corruption)
+
+	files, _ := os.ReadDir(tempDir)
+	for _, file := range files {
+		os.Truncate(path.Join(tempDir, file.Name()), 4)
+	}
+	_, err = NewKVStore(storeConfig)
+	require.ErrorIs(t, err, kv.ErrCorrupted)
+	require.Len(t, listener.corruption, 1)
+	require.ErrorIs(t, listener.corruption[0].Err, kv.ErrCorrupted)
+	require.Len(t, listener.reloads, 2)
+}
@@ -0,0 +1,35 @@
+package kv
+
+import "context"
+
+// storeLock is a mutex made of a single slot channel, so that it can be waited on along with a context
+type storeLock struct {
+	slot chan struct{}
+}
+
+func newStoreLock() storeLock {
+	return storeLock{slot: make(chan struct{}, 1)}
+}
+
+// Lock acquires the lock, waiting until it is released
+func (lock storeLock) Lock() {
+	lock.slot <- struct{}{}
+}
+
+// LockContext acquires the lock, or returns ctx.Err() if ctx is done first
+func (lock storeLock) LockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case lock.slot <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Unlock releases the lock
+func (lock storeLock) Unlock() {
+	<-lock.slot
+}
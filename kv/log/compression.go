@@ -0,0 +1,49 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compress compresses the value using the given codec, and returns it as is with NoCompression if compression does not make it smaller
+func compress(compression config.Compression, value []byte) ([]byte, config.Compression, error) {
+	switch compression {
+	case config.NoCompression:
+		return value, config.NoCompression, nil
+	case config.GzipCompression:
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write(value); err != nil {
+			return nil, config.NoCompression, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, config.NoCompression, err
+		}
+		if buffer.Len() >= len(value) {
+			return value, config.NoCompression, nil
+		}
+		return buffer.Bytes(), config.GzipCompression, nil
+	default:
+		return nil, config.NoCompression, fmt.Errorf("unknown compression codec %v", compression)
+	}
+}
+
+// decompress reverses compress for a value that was stored using the given codec
+func decompress(compression config.Compression, value []byte) ([]byte, error) {
+	switch compression {
+	case config.NoCompression:
+		return value, nil
+	case config.GzipCompression:
+		reader, err := gzip.NewReader(bytes.NewReader(value))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	default:
+		return nil, fmt.Errorf("unknown compression codec %v", compression)
+	}
+}
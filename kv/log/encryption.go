@@ -0,0 +1,87 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"unsafe"
+)
+
+// An encrypted segment starts with a header that records the id of the key its values are encrypted with
+//
+//	┌───────┬────────┐
+//	│ magic │ key_id │
+//	└───────┴────────┘
+//
+// Unencrypted segments carry no header.
+var (
+	encryptionHeaderMagic = []byte("BCSKAES1")
+	reservedKeyIdSize     = uint32(unsafe.Sizeof(uint32(0)))
+	encryptionHeaderSize  = uint32(len(encryptionHeaderMagic)) + reservedKeyIdSize
+)
+
+// gcmNonceSize and gcmTagSize are the sizes of the nonce and the authentication tag which AES-GCM adds to every value
+const (
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+)
+
+// segmentCipher encrypts and decrypts the values of a single segment using AES-GCM
+type segmentCipher struct {
+	keyId uint32
+	aead  cipher.AEAD
+}
+
+// newSegmentCipher creates a segmentCipher using the key identified by keyId
+func newSegmentCipher(keyProvider config.KeyProvider, keyId uint32) (*segmentCipher, error) {
+	key, err := keyProvider.Key(keyId)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &segmentCipher{keyId: keyId, aead: aead}, nil
+}
+
+// seal encrypts the value, with the key of the entry as additional data, and prefixes it with a random nonce
+func (segmentCipher *segmentCipher) seal(value []byte, key []byte) ([]byte, error) {
+	nonce := make([]byte, segmentCipher.aead.NonceSize(), segmentCipher.aead.NonceSize()+len(value)+segmentCipher.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return segmentCipher.aead.Seal(nonce, nonce, value, key), nil
+}
+
+// open reverses seal
+func (segmentCipher *segmentCipher) open(sealed []byte, key []byte) ([]byte, error) {
+	nonceSize := segmentCipher.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("encrypted value of %v bytes is shorter than the nonce", len(sealed))
+	}
+	return segmentCipher.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], key)
+}
+
+// header returns the header to be written at the start of a segment encrypted with this cipher
+func (segmentCipher *segmentCipher) header() []byte {
+	header := make([]byte, encryptionHeaderSize)
+	copy(header, encryptionHeaderMagic)
+	littleEndian.PutUint32(header[len(encryptionHeaderMagic):], segmentCipher.keyId)
+	return header
+}
+
+// decodeEncryptionHeader returns the key id recorded in the header and true if content starts with an encryption header
+func decodeEncryptionHeader(content []byte) (uint32, bool) {
+	if uint32(len(content)) < encryptionHeaderSize || !bytes.Equal(content[:len(encryptionHeaderMagic)], encryptionHeaderMagic) {
+		return 0, false
+	}
+	return littleEndian.Uint32(content[len(encryptionHeaderMagic):]), true
+}
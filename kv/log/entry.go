@@ -4,6 +4,8 @@ import (
 	"ashishkujoy/bitcask/clock"
 	"ashishkujoy/bitcask/config"
 	"encoding/binary"
+	"fmt"
+	"math"
 	"unsafe"
 )
 
@@ -13,6 +15,24 @@ var (
 	reservedTimestampSize = uint32(unsafe.Sizeof(uint32(0)))
 	tombstoneMarkerSize   = uint32(unsafe.Sizeof(byte(0)))
 	littleEndian          = binary.LittleEndian
+	entryOverhead         = reservedTimestampSize + reservedKeySize + reservedValueSize + tombstoneMarkerSize
+)
+
+// The tombstone marker byte doubles up as a flags byte
+const (
+	tombstoneFlag    byte = 0x01
+	compressionMask  byte = 0x06 // the codec (config.Compression) of the value
+	compressionShift      = 1
+	encryptionFlag   byte = 0x08
+	keyspaceFlag     byte = 0x10 // the key starts with the uvarint ID of its keyspace, unset for the default keyspace
+	batchFlag        byte = 0x20 // more entries of the batch follow, unset on the last entry, which commits the batch
+)
+
+const (
+	// DefaultKeyspace is the ID of the keyspace of the entries written without naming a keyspace
+	DefaultKeyspace uint32 = 0
+	// CatalogKeyspace is the ID of the reserved keyspace mapping the name of every keyspace to its uvarint ID, a delete dropping the keyspace
+	CatalogKeyspace uint32 = math.MaxUint32
 )
 
 type valueReference struct {
@@ -20,16 +40,19 @@ type valueReference struct {
 	tombstone byte
 }
 
-type Entry[Key config.Serializable] struct {
-	key       Key            // Key of the entry
-	value     valueReference // Value of the entry
-	timestamp uint32         // timestamp
-	clock     clock.Clock    // clock
+type Entry struct {
+	key         []byte             // Encoded key of the entry
+	value       valueReference     // Value of the entry
+	timestamp   uint32             // timestamp
+	clock       clock.Clock        // clock
+	compression config.Compression // codec used to compress the value
+	keyspace    uint32             // ID of the keyspace of the entry
+	batched     bool               // true for an entry of a batch followed by more entries of the batch
 }
 
 // NewEntry creates a instance of Entry with given key and value, setting tombstone to 0
-func NewEntry[Key config.Serializable](key Key, value []byte, clock clock.Clock) *Entry[Key] {
-	return &Entry[Key]{
+func NewEntry(key []byte, value []byte, clock clock.Clock) *Entry {
+	return &Entry{
 		key:       key,
 		value:     valueReference{value: value, tombstone: 0},
 		timestamp: 0,
@@ -38,8 +61,8 @@ func NewEntry[Key config.Serializable](key Key, value []byte, clock clock.Clock)
 }
 
 // NewEntryPreservingTimestamp creates a new instance of Entry with tombstone byte set to 0 and keeping the provided timestamp
-func NewEntryPreservingTimestamp[Key config.Serializable](key Key, value []byte, ts uint32, clock clock.Clock) *Entry[Key] {
-	return &Entry[Key]{
+func NewEntryPreservingTimestamp(key []byte, value []byte, ts uint32, clock clock.Clock) *Entry {
+	return &Entry{
 		key:       key,
 		value:     valueReference{value: value, tombstone: 0},
 		timestamp: ts,
@@ -48,8 +71,8 @@ func NewEntryPreservingTimestamp[Key config.Serializable](key Key, value []byte,
 }
 
 // NewDeleteEntry creates a instance of Entry with tombstone set to 1
-func NewDeleteEntry[Key config.Serializable](key Key, clock clock.Clock) *Entry[Key] {
-	return &Entry[Key]{
+func NewDeleteEntry(key []byte, clock clock.Clock) *Entry {
+	return &Entry{
 		key: key,
 		value: valueReference{
 			value:     []byte{},
@@ -60,16 +83,64 @@ func NewDeleteEntry[Key config.Serializable](key Key, clock clock.Clock) *Entry[
 	}
 }
 
+// compressedWith sets the codec used to compress the value when the entry is encoded
+func (entry *Entry) compressedWith(compression config.Compression) *Entry {
+	entry.compression = compression
+	return entry
+}
+
+// inKeyspace sets the keyspace of the entry, whose ID prefixes the key when the entry is encoded
+func (entry *Entry) inKeyspace(keyspace uint32) *Entry {
+	entry.keyspace = keyspace
+	return entry
+}
+
+// inBatch marks the entry as an entry of a batch which is followed by more entries of the batch
+func (entry *Entry) inBatch() *Entry {
+	entry.batched = true
+	return entry
+}
+
 // encode convert entry to byte slice which can be written to the disk
 // Encoding scheme
 //
-//	┌───────────┬──────────┬────────────┬─────┬───────┐
-//	│ timestamp │ key_size │ value_size │ key │ value │
-//	└───────────┴──────────┴────────────┴─────┴───────┘
-func (entry *Entry[Key]) encode() []byte {
-	serializedKey := entry.key.Serialize()
+//	┌───────────┬──────────┬────────────┬─────┬───────┬───────┐
+//	│ timestamp │ key_size │ value_size │ key │ value │ flags │
+//	└───────────┴──────────┴────────────┴─────┴───────┴───────┘
+//
+// value_size includes the flags byte. If a cipher is given, the (compressed) value is encrypted with it.
+func (entry *Entry) encode(cipher *segmentCipher) ([]byte, error) {
+	serializedKey := entry.key
+	value, compression, err := compress(entry.compression, entry.value.value)
+	if err != nil {
+		return nil, err
+	}
+	flags := entry.value.tombstone | byte(compression)<<compressionShift
+	if entry.keyspace != DefaultKeyspace {
+		serializedKey = append(binary.AppendUvarint(nil, uint64(entry.keyspace)), entry.key...)
+		flags |= keyspaceFlag
+	}
+	if entry.batched {
+		flags |= batchFlag
+	}
+
+	if cipher != nil {
+		value, err = cipher.seal(value, serializedKey)
+		if err != nil {
+			return nil, err
+		}
+		flags |= encryptionFlag
+	}
+
+	if uint64(len(serializedKey)) > math.MaxUint32-uint64(entryOverhead) {
+		return nil, ErrKeyTooLarge
+	}
+	if uint64(len(serializedKey))+uint64(len(value)) > math.MaxUint32-uint64(entryOverhead) {
+		return nil, ErrValueTooLarge
+	}
+
 	keySize := uint32(len(serializedKey))
-	valueSize := uint32(len(entry.value.value)) + tombstoneMarkerSize
+	valueSize := uint32(len(value)) + tombstoneMarkerSize
 	totalEntrySize := reservedTimestampSize + reservedKeySize + reservedValueSize + keySize + valueSize
 	encoded := make([]byte, totalEntrySize)
 
@@ -90,46 +161,88 @@ func (entry *Entry[Key]) encode() []byte {
 	copy(encoded[offset:], serializedKey)
 	offset += keySize
 
-	copy(encoded[offset:], append(entry.value.value, entry.value.tombstone))
+	copy(encoded[offset:], value)
+	offset += valueSize - tombstoneMarkerSize
+
+	encoded[offset] = flags
 
-	return encoded
+	return encoded, nil
+}
+
+// maxEntrySize returns the largest size an encoded entry can take for a key of maxKeySize bytes and a value of maxValueSize bytes
+func maxEntrySize(maxKeySize uint32, maxValueSize uint32, encrypted bool) uint64 {
+	size := uint64(entryOverhead) + binary.MaxVarintLen32 + uint64(maxKeySize) + uint64(maxValueSize)
+	if encrypted {
+		size += gcmNonceSize + gcmTagSize
+	}
+	return size
 }
 
 type StoredEntry struct {
-	Key       []byte
+	Keyspace  uint32
+	Key       []byte // encoded key, without the ID of the keyspace
 	Value     []byte
 	Deleted   bool
 	Timestamp uint32
+	batched   bool // true for an entry of a batch followed by more entries of the batch
 }
 
-func decode(content []byte) *StoredEntry {
-	storedEntry, _ := decodeFrom(content, 0)
-	return storedEntry
+func decode(content []byte, cipher *segmentCipher) (*StoredEntry, error) {
+	storedEntry, _, err := decodeFrom(content, 0, cipher)
+	return storedEntry, err
 }
 
-// decodeMulti performs multiple decode operations and returns an array of MappedStoredEntry
+// decodeMulti performs multiple decode operations starting at offset and returns an array of MappedStoredEntry
 // This method is invoked when a segment file needs to be read completely. This happens during reload and merge operations.
-func decodeMulti[Key config.BitcaskKey](content []byte, keyMapper func([]byte) Key) []*MappedStoredEntry[Key] {
+// Keys are decoded using keyCodec, except the keys of the catalog, and the entries of a batch are returned only once its last entry is decoded.
+func decodeMulti[Key config.BitcaskKey](content []byte, offset uint32, cipher *segmentCipher, keyCodec config.KeyCodec[Key]) ([]*MappedStoredEntry[Key], error) {
 	contentLength := uint32(len(content))
-	var offset uint32 = 0
 	var entries []*MappedStoredEntry[Key]
+	var batch []*MappedStoredEntry[Key] // entries of a batch waiting for its last entry
 
 	for offset < contentLength {
-		entry, traversedOffset := decodeFrom(content, offset)
-		entries = append(entries, &MappedStoredEntry[Key]{
-			Key:         keyMapper(entry.Key),
+		entry, traversedOffset, err := decodeFrom(content, offset, cipher)
+		if err != nil {
+			return nil, err
+		}
+		var key Key
+		if entry.Keyspace != CatalogKeyspace {
+			key, err = keyCodec.Decode(entry.Key)
+			if err != nil {
+				return nil, fmt.Errorf("%w: key of the entry at offset %v can not be decoded: %v", ErrCorrupted, offset, err)
+			}
+		}
+		mappedEntry := &MappedStoredEntry[Key]{
+			Keyspace:    entry.Keyspace,
+			Key:         key,
+			EncodedKey:  entry.Key,
 			Value:       entry.Value,
 			Deleted:     entry.Deleted,
+			Timestamp:   entry.Timestamp,
 			KeyOffset:   offset,
 			EntryLength: traversedOffset - offset,
-		})
+		}
 		offset = traversedOffset
+		if entry.batched {
+			batch = append(batch, mappedEntry)
+			continue
+		}
+		entries = append(append(entries, batch...), mappedEntry)
+		batch = nil
 	}
 
-	return entries
+	// the entries left in batch belong to a batch whose last entry was never written
+	return entries, nil
 }
 
-func decodeFrom(content []byte, offset uint32) (*StoredEntry, uint32) {
+// decodeFrom decodes the entry starting at offset, decrypting and decompressing its value, and returns it along with the offset of the next entry
+func decodeFrom(content []byte, offset uint32, cipher *segmentCipher) (*StoredEntry, uint32, error) {
+	entryOffset := offset
+	contentLength := uint64(len(content))
+	if uint64(offset)+uint64(reservedTimestampSize+reservedKeySize+reservedValueSize) > contentLength {
+		return nil, 0, fmt.Errorf("%w: entry header at offset %v is %w", ErrCorrupted, entryOffset, errTruncated)
+	}
+
 	timestamp := littleEndian.Uint32(content[offset:])
 	offset += reservedTimestampSize
 
@@ -139,16 +252,52 @@ func decodeFrom(content []byte, offset uint32) (*StoredEntry, uint32) {
 	valueSize := littleEndian.Uint32(content[offset:])
 	offset += reservedValueSize
 
+	if valueSize < tombstoneMarkerSize {
+		return nil, 0, fmt.Errorf("%w: entry at offset %v has a value size of %v", ErrCorrupted, entryOffset, valueSize)
+	}
+	if uint64(offset)+uint64(keySize)+uint64(valueSize) > contentLength {
+		return nil, 0, fmt.Errorf("%w: entry at offset %v is %w", ErrCorrupted, entryOffset, errTruncated)
+	}
+
 	key := content[offset : offset+keySize]
 	offset += keySize
 
 	value := content[offset : offset+valueSize]
 	offset += valueSize
 
+	flags := value[valueSize-1]
+	value = value[:valueSize-1]
+	if flags&encryptionFlag == encryptionFlag {
+		if cipher == nil {
+			return nil, 0, fmt.Errorf("entry at offset %v is encrypted but no key provider is configured", entryOffset)
+		}
+		decrypted, err := cipher.open(value, key)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: entry at offset %v can not be decrypted: %v", ErrCorrupted, entryOffset, err)
+		}
+		value = decrypted
+	}
+
+	decompressed, err := decompress(config.Compression((flags&compressionMask)>>compressionShift), value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: entry at offset %v can not be decompressed: %v", ErrCorrupted, entryOffset, err)
+	}
+
+	keyspace := DefaultKeyspace
+	if flags&keyspaceFlag == keyspaceFlag {
+		id, size := binary.Uvarint(key)
+		if size <= 0 || id > math.MaxUint32 {
+			return nil, 0, fmt.Errorf("%w: entry at offset %v has an invalid keyspace", ErrCorrupted, entryOffset)
+		}
+		keyspace, key = uint32(id), key[size:]
+	}
+
 	return &StoredEntry{
+		Keyspace:  keyspace,
 		Key:       key,
-		Value:     value[:valueSize-1],
-		Deleted:   value[valueSize-1]&0x01 == 0x01,
+		Value:     decompressed,
+		Deleted:   flags&tombstoneFlag == tombstoneFlag,
 		Timestamp: timestamp,
-	}, offset
+		batched:   flags&batchFlag == batchFlag,
+	}, offset, nil
 }
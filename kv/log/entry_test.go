@@ -2,6 +2,8 @@ package kv
 
 import (
 	"ashishkujoy/bitcask/clock"
+	"ashishkujoy/bitcask/config"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -13,6 +15,8 @@ func (key serializableKey) Serialize() []byte {
 	return []byte(key)
 }
 
+var keyCodec = config.StringKeyCodec[serializableKey]{}
+
 type fixedClock struct{}
 
 func (clock *fixedClock) Now() int64 {
@@ -20,27 +24,104 @@ func (clock *fixedClock) Now() int64 {
 }
 
 func TestEncodeAKeyValuePair(t *testing.T) {
-	entry := NewEntry[serializableKey]("topic", []byte("microservices"), clock.NewSystemClock())
-	encoded := entry.encode()
+	entry := NewEntry([]byte("topic"), []byte("microservices"), clock.NewSystemClock())
+	encoded, err := entry.encode(nil)
+	require.NoError(t, err)
 
-	storedEntry := decode(encoded)
+	storedEntry, err := decode(encoded, nil)
+	require.NoError(t, err)
 
 	require.False(t, storedEntry.Deleted)
 	require.Equal(t, []byte("topic"), storedEntry.Key)
 }
 
 func TestEncodesAKeyValuePairAndValidatesTimestamp(t *testing.T) {
-	entry := NewEntry[serializableKey]("topic", []byte("microservices"), &fixedClock{})
-	encoded := entry.encode()
-	storedEntry := decode(encoded)
+	entry := NewEntry([]byte("topic"), []byte("microservices"), &fixedClock{})
+	encoded, _ := entry.encode(nil)
+	storedEntry, _ := decode(encoded, nil)
 
 	require.Equal(t, uint32(100), storedEntry.Timestamp)
 }
 
 func TestEncodeADeleteKeyValuePair(t *testing.T) {
-	entry := NewDeleteEntry[serializableKey]("topic", clock.NewSystemClock())
-	encoded := entry.encode()
-	storedEntry := decode(encoded)
+	entry := NewDeleteEntry([]byte("topic"), clock.NewSystemClock())
+	encoded, _ := entry.encode(nil)
+	storedEntry, _ := decode(encoded, nil)
 
 	require.True(t, storedEntry.Deleted)
 }
+
+func TestEncodeAKeyValuePairOfAKeyspace(t *testing.T) {
+	entry := NewEntry([]byte("topic"), []byte("microservices"), clock.NewSystemClock()).inKeyspace(300)
+	encoded, err := entry.encode(nil)
+	require.NoError(t, err)
+
+	storedEntry, err := decode(encoded, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(300), storedEntry.Keyspace)
+	require.Equal(t, []byte("topic"), storedEntry.Key)
+	require.Equal(t, []byte("microservices"), storedEntry.Value)
+
+	defaultEntry, _ := NewEntry([]byte("topic"), []byte("microservices"), clock.NewSystemClock()).encode(nil)
+	storedEntry, _ = decode(defaultEntry, nil)
+	require.Equal(t, DefaultKeyspace, storedEntry.Keyspace)
+	require.Equal(t, len(defaultEntry)+2, len(encoded), "only the entries of a keyspace other than the default one carry its ID")
+}
+
+func TestEncodeAndDecodeACompressedKeyValuePair(t *testing.T) {
+	value := []byte(strings.Repeat(`{"topic":"microservices"}`, 20))
+	entry := NewEntry([]byte("topic"), value, clock.NewSystemClock()).compressedWith(config.GzipCompression)
+	encoded, err := entry.encode(nil)
+	require.NoError(t, err)
+	require.Less(t, len(encoded), len(value))
+
+	storedEntry, err := decode(encoded, nil)
+	require.NoError(t, err)
+	require.False(t, storedEntry.Deleted)
+	require.Equal(t, value, storedEntry.Value)
+}
+
+func TestEncodeAnIncompressibleValueWithoutCompression(t *testing.T) {
+	entry := NewEntry([]byte("topic"), []byte("ssd"), clock.NewSystemClock()).compressedWith(config.GzipCompression)
+	encoded, _ := entry.encode(nil)
+
+	require.Equal(t, byte(0), encoded[len(encoded)-1]&compressionMask)
+	storedEntry, _ := decode(encoded, nil)
+	require.Equal(t, []byte("ssd"), storedEntry.Value)
+}
+
+func TestDecodeCompressedAndUncompressedEntriesTogether(t *testing.T) {
+	value := []byte(strings.Repeat("bitcask", 30))
+	compressed, _ := NewEntry([]byte("compressed"), value, clock.NewSystemClock()).compressedWith(config.GzipCompression).encode(nil)
+	uncompressed, _ := NewEntry([]byte("uncompressed"), value, clock.NewSystemClock()).encode(nil)
+	deleted, _ := NewDeleteEntry([]byte("deleted"), clock.NewSystemClock()).compressedWith(config.GzipCompression).encode(nil)
+
+	content := append(append(compressed, uncompressed...), deleted...)
+	entries, err := decodeMulti(content, 0, nil, keyCodec)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(entries))
+
+	require.Equal(t, value, entries[0].Value)
+	require.Equal(t, value, entries[1].Value)
+	require.True(t, entries[2].Deleted)
+	require.Equal(t, serializableKey("deleted"), entries[2].Key)
+}
+
+func TestDecodeATruncatedEntry(t *testing.T) {
+	encoded, _ := NewEntry([]byte("topic"), []byte("microservices"), clock.NewSystemClock()).encode(nil)
+
+	_, err := decode(encoded[:len(encoded)-4], nil)
+	require.ErrorIs(t, err, ErrCorrupted)
+
+	_, err = decode(encoded[:6], nil)
+	require.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestDecodeAnEntryWithATamperedCompressedValue(t *testing.T) {
+	value := []byte(strings.Repeat("bitcask", 30))
+	encoded, _ := NewEntry([]byte("topic"), value, clock.NewSystemClock()).compressedWith(config.GzipCompression).encode(nil)
+	encoded[len(encoded)-3] ^= 0xff
+
+	_, err := decode(encoded, nil)
+	require.ErrorIs(t, err, ErrCorrupted)
+}
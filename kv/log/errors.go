@@ -0,0 +1,27 @@
+package kv
+
+import "errors"
+
+var (
+	// ErrEmptyKey is returned when an entry is appended with a key that serializes to zero bytes
+	ErrEmptyKey = errors.New("key is empty")
+	// ErrKeyTooLarge is returned when the serialized key is longer than the configured maximum key size, or can not be encoded in an entry
+	ErrKeyTooLarge = errors.New("key is too large")
+	// ErrValueTooLarge is returned when the value is longer than the configured maximum value size, or can not be encoded in an entry
+	ErrValueTooLarge = errors.New("value is too large")
+	// ErrEntryTooLarge is returned when the encoded entry, compressed and encrypted as configured, takes more bytes than a segment file can address
+	ErrEntryTooLarge = errors.New("entry is larger than a segment file can address")
+	// ErrBatchTooLarge is returned when the entries of a batch, encoded together, take more bytes than a segment file can address
+	ErrBatchTooLarge = errors.New("batch is larger than a segment file can address")
+	// ErrSegmentNotFound is returned when a read refers to a segment which is not (or no longer) present
+	ErrSegmentNotFound = errors.New("segment not found")
+	// ErrCorrupted is returned when an entry read from a segment is truncated, has inconsistent sizes or fails to decrypt or decompress
+	ErrCorrupted = errors.New("segment is corrupted")
+	// ErrReadOnly is returned by every write to segments opened read-only
+	ErrReadOnly = errors.New("segments are read-only")
+
+	// errTruncated marks an ErrCorrupted entry which runs past the end of the segment, as left behind by an interrupted write
+	errTruncated = errors.New("truncated")
+	// errMisnamed marks a segment file whose name differs from the one derived from its fileId, so it is never reloaded
+	errMisnamed = errors.New("misnamed segment file")
+)
@@ -2,7 +2,11 @@ package kv
 
 import (
 	"ashishkujoy/bitcask/config"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path"
 )
@@ -14,7 +18,9 @@ type AppendEntryResponse struct {
 }
 
 type MappedStoredEntry[Key config.BitcaskKey] struct {
-	Key         Key
+	Keyspace    uint32 // ID of the keyspace of the entry
+	Key         Key    // decoded key, left zero for an entry of the catalog
+	EncodedKey  []byte
 	Value       []byte
 	Deleted     bool
 	Timestamp   uint32
@@ -26,6 +32,7 @@ type Segment[Key config.BitcaskKey] struct {
 	fileId   uint64
 	filePath string
 	store    *Store
+	cipher   *segmentCipher
 }
 
 const segmentFilePrefix = "bitcask"
@@ -48,22 +55,73 @@ func NewSegment[Key config.BitcaskKey](fileId uint64, directory string) (*Segmen
 	}, nil
 }
 
+// NewEncryptedSegment represents an append-only log whose values are encrypted with the current key of keyProvider, whose id is written to its header
+func NewEncryptedSegment[Key config.BitcaskKey](fileId uint64, directory string, keyProvider config.KeyProvider) (*Segment[Key], error) {
+	cipher, err := newSegmentCipher(keyProvider, keyProvider.CurrentKeyId())
+	if err != nil {
+		return nil, err
+	}
+	segment, err := NewSegment[Key](fileId, directory)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := segment.store.append(cipher.header()); err != nil {
+		return nil, err
+	}
+	segment.cipher = cipher
+	return segment, nil
+}
+
 // ReloadInactiveSegment reloads the inactive segment during start-up. As a part of ReloadInactiveSegment, we just create the in-memory representation of inactive segment and its store
-func ReloadInactiveSegment[Key config.BitcaskKey](fileId uint64, directory string) (*Segment[Key], error) {
+// If the segment is encrypted, the key recorded in its header is fetched from keyProvider, which may be nil if encryption has never been enabled.
+func ReloadInactiveSegment[Key config.BitcaskKey](fileId uint64, directory string, keyProvider config.KeyProvider) (*Segment[Key], error) {
 	filePath := segmentName(fileId, directory)
 	store, err := ReloadStore(filePath)
 	if err != nil {
 		return nil, err
 	}
+	cipher, err := reloadSegmentCipher(filePath, store, keyProvider)
+	if err != nil {
+		return nil, err
+	}
 	return &Segment[Key]{
 		fileId:   fileId,
 		filePath: filePath,
 		store:    store,
+		cipher:   cipher,
 	}, nil
 }
 
-func (segment *Segment[Key]) append(entry *Entry[Key]) (*AppendEntryResponse, error) {
-	encoded := entry.encode()
+func (segment *Segment[Key]) append(entry *Entry) (*AppendEntryResponse, error) {
+	encoded, err := segment.encode(entry)
+	if err != nil {
+		return nil, err
+	}
+	return segment.appendEncoded(encoded)
+}
+
+// encode encodes the entry as it is appended to the segment, encrypting its value with the cipher of the segment if any
+func (segment *Segment[Key]) encode(entry *Entry) ([]byte, error) {
+	return entry.encode(segment.cipher)
+}
+
+// encodeAll encodes the entries for the segment, and returns them along with their total length
+func (segment *Segment[Key]) encodeAll(entries []*Entry) ([][]byte, int, error) {
+	encoded := make([][]byte, 0, len(entries))
+	length := 0
+	for _, entry := range entries {
+		encodedEntry, err := segment.encode(entry)
+		if err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, encodedEntry)
+		length += len(encodedEntry)
+	}
+	return encoded, length, nil
+}
+
+// appendEncoded appends an entry encoded for the segment by encode
+func (segment *Segment[Key]) appendEncoded(encoded []byte) (*AppendEntryResponse, error) {
 	offset, err := segment.store.append(encoded)
 
 	if err != nil {
@@ -77,21 +135,89 @@ func (segment *Segment[Key]) append(entry *Entry[Key]) (*AppendEntryResponse, er
 	}, nil
 }
 
+// maxSegmentFileSize is the size no segment file grows beyond, whatever the maximum segment size, as the offsets of its entries are decoded as uint32
+var maxSegmentFileSize uint64 = math.MaxUint32
+
+// fits returns true if an encoded entry of entryLength bytes can be appended to the segment without exceeding its size limits
+func (segment *Segment[Key]) fits(entryLength int, maxSegmentByteSize uint64) bool {
+	size := uint64(segment.sizeInBytes())
+	if size <= uint64(segment.dataOffset()) {
+		return segment.holds(entryLength)
+	}
+	return size < maxSegmentByteSize && size+uint64(entryLength) <= maxSegmentFileSize
+}
+
+// holds returns true if an encoded entry of entryLength bytes fits in an empty segment file
+func (segment *Segment[Key]) holds(entryLength int) bool {
+	return uint64(segment.dataOffset())+uint64(entryLength) <= maxSegmentFileSize
+}
+
 // read performs a read operation from the offset in the segment file. This method is invoked in the Get operation
 func (segment *Segment[Key]) read(offset int64, size uint32) (*StoredEntry, error) {
 	bytes, err := segment.store.read(offset, size)
 	if err != nil {
 		return nil, err
 	}
-	return decode(bytes), nil
+	return decode(bytes, segment.cipher)
+}
+
+// ReadFull reads and decodes all the entries of the segment, decoding their keys using keyCodec
+func (segment *Segment[Key]) ReadFull(keyCodec config.KeyCodec[Key]) ([]*MappedStoredEntry[Key], error) {
+	return segment.ReadFullContext(context.Background(), keyCodec)
+}
+
+// ReadFullContext is ReadFull giving up with ctx.Err() as soon as ctx is done
+func (segment *Segment[Key]) ReadFullContext(ctx context.Context, keyCodec config.KeyCodec[Key]) ([]*MappedStoredEntry[Key], error) {
+	bytes, err := segment.store.readFullContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMulti(bytes, segment.dataOffset(), segment.cipher, keyCodec)
 }
 
-func (segment *Segment[Key]) ReadFull(keyMapper func([]byte) Key) ([]*MappedStoredEntry[Key], error) {
-	bytes, err := segment.store.readFull()
+// readRecordAt decodes the entry starting at offset, and returns io.EOF if offset is at or past the end of the segment
+func (segment *Segment[Key]) readRecordAt(offset int64) (*Record, error) {
+	offset = max(offset, int64(segment.dataOffset()))
+	size, err := segment.store.fileSize()
+	if err != nil {
+		return nil, err
+	}
+	if offset >= size {
+		return nil, io.EOF
+	}
+	header, err := segment.store.read(offset, reservedTimestampSize+reservedKeySize+reservedValueSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: entry header at offset %v of segment %v can not be read: %v", ErrCorrupted, offset, segment.fileId, err)
+	}
+	length := uint64(len(header)) + uint64(littleEndian.Uint32(header[reservedTimestampSize:])) + uint64(littleEndian.Uint32(header[reservedTimestampSize+reservedKeySize:]))
+	if uint64(offset)+length > uint64(size) {
+		return nil, fmt.Errorf("%w: entry at offset %v of segment %v is %w", ErrCorrupted, offset, segment.fileId, errTruncated)
+	}
+	content, err := segment.store.read(offset, uint32(length))
+	if err != nil {
+		return nil, err
+	}
+	entry, _, err := decodeFrom(content, 0, segment.cipher)
 	if err != nil {
 		return nil, err
 	}
-	return decodeMulti(bytes, keyMapper), nil
+	return &Record{
+		Offset:    uint32(offset),
+		Length:    uint32(length),
+		Timestamp: entry.Timestamp,
+		Keyspace:  entry.Keyspace,
+		Key:       entry.Key,
+		Value:     entry.Value,
+		Deleted:   entry.Deleted,
+	}, nil
+}
+
+// dataOffset returns the offset of the first entry, which follows the header in an encrypted segment
+func (segment *Segment[Key]) dataOffset() uint32 {
+	if segment.cipher != nil {
+		return encryptionHeaderSize
+	}
+	return 0
 }
 
 // sizeInBytes returns the segment file size in bytes
@@ -105,13 +231,36 @@ func (segment *Segment[Key]) sync() error {
 }
 
 // stopWrites Closes the write file pointer. This operation is called when the active segment has reached its size threshold.
-func (segment *Segment[Key]) stopWrites() {
-	segment.store.stopWrites()
+func (segment *Segment[Key]) stopWrites() error {
+	return segment.store.stopWrites()
 }
 
 // remove Removes the file
-func (segment *Segment[Key]) remove() {
-	segment.store.remove()
+func (segment *Segment[Key]) remove() error {
+	return segment.store.remove()
+}
+
+// reloadSegmentCipher returns the cipher for the segment if it starts with an encryption header, else nil
+func reloadSegmentCipher(filePath string, store *Store, keyProvider config.KeyProvider) (*segmentCipher, error) {
+	header, err := store.read(0, encryptionHeaderSize)
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyId, ok := decodeEncryptionHeader(header)
+	if !ok {
+		return nil, nil
+	}
+	if keyProvider == nil {
+		return nil, errEncryptedSegmentWithoutKeyProvider(filePath, keyId)
+	}
+	return newSegmentCipher(keyProvider, keyId)
+}
+
+func errEncryptedSegmentWithoutKeyProvider(filePath string, keyId uint32) error {
+	return fmt.Errorf("segment %v is encrypted with key %v but no key provider is configured", filePath, keyId)
 }
 
 func createSegment(fileId uint64, directory string) (string, error) {
@@ -124,5 +273,5 @@ func createSegment(fileId uint64, directory string) (string, error) {
 }
 
 func segmentName(fileId uint64, directory string) string {
-	return path.Join(directory, fmt.Sprintf("%v_%v.%v", fileId, segmentFilePrefix, segmentFileSuffix))
+	return path.Join(directory, SegmentFileName(fileId))
 }
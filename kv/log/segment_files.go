@@ -0,0 +1,165 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SegmentFile describes a segment file present in a directory
+type SegmentFile struct {
+	FileId uint64
+	Path   string
+	Size   int64
+}
+
+// Record is an entry decoded from a segment file, along with its position in the file
+type Record struct {
+	Offset    uint32
+	Length    uint32
+	Timestamp uint32
+	Keyspace  uint32 // ID of the keyspace of the record
+	Key       []byte // encoded key, without the ID of the keyspace
+	Value     []byte
+	Deleted   bool
+}
+
+// SegmentFileName returns the name of the file of the segment identified by fileId
+func SegmentFileName(fileId uint64) string {
+	return fmt.Sprintf("%v_%v.%v", fileId, segmentFilePrefix, segmentFileSuffix)
+}
+
+// ListSegmentFiles returns the segment files present in the directory, ordered by fileId
+func ListSegmentFiles(directory string) ([]*SegmentFile, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := segmentFilePrefix + "." + segmentFileSuffix
+	var segmentFiles []*SegmentFile
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+		fileId, err := strconv.ParseUint(strings.Split(entry.Name(), "_")[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		segmentFiles = append(segmentFiles, &SegmentFile{
+			FileId: fileId,
+			Path:   path.Join(directory, entry.Name()),
+			Size:   info.Size(),
+		})
+	}
+
+	slices.SortFunc(segmentFiles, func(a, b *SegmentFile) int {
+		if a.FileId < b.FileId {
+			return -1
+		}
+		if a.FileId > b.FileId {
+			return 1
+		}
+		return 0
+	})
+	return segmentFiles, nil
+}
+
+// ReadRecords decodes all the entries of the segment file, returning the ones before an entry which can not be decoded along with the error
+func ReadRecords(filePath string, keyProvider config.KeyProvider) ([]*Record, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	records, _, err := decodeRecords(filePath, content, keyProvider)
+	return records, err
+}
+
+// decodeRecords decodes the entries of the segment content, and returns them along with the size of content they span
+func decodeRecords(filePath string, content []byte, keyProvider config.KeyProvider) ([]*Record, uint32, error) {
+	var offset uint32 = 0
+	var cipher *segmentCipher
+	if keyId, ok := decodeEncryptionHeader(content); ok {
+		if keyProvider == nil {
+			return nil, 0, errEncryptedSegmentWithoutKeyProvider(filePath, keyId)
+		}
+		var err error
+		cipher, err = newSegmentCipher(keyProvider, keyId)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset = encryptionHeaderSize
+	}
+
+	var records []*Record
+	for offset < uint32(len(content)) {
+		entry, traversedOffset, err := decodeFrom(content, offset, cipher)
+		if errors.Is(err, errTruncated) && hasCorruptedSize(content, offset, cipher) {
+			return records, offset, fmt.Errorf("%w: entry at offset %v runs past the end of the segment because of a corrupted size", ErrCorrupted, offset)
+		}
+		if err != nil {
+			return records, offset, err
+		}
+		records = append(records, &Record{
+			Offset:    offset,
+			Length:    traversedOffset - offset,
+			Timestamp: entry.Timestamp,
+			Keyspace:  entry.Keyspace,
+			Key:       entry.Key,
+			Value:     entry.Value,
+			Deleted:   entry.Deleted,
+		})
+		offset = traversedOffset
+	}
+	return records, offset, nil
+}
+
+// hasCorruptedSize returns true if the entry at offset, which runs past the end of content, ends right before entries decoding cleanly to the end once one of its sizes is corrected
+func hasCorruptedSize(content []byte, offset uint32, cipher *segmentCipher) bool {
+	headerSize := uint32(reservedTimestampSize + reservedKeySize + reservedValueSize)
+	if offset+headerSize > uint32(len(content)) {
+		return false
+	}
+	keySizeOffset, valueSizeOffset := offset+reservedTimestampSize, offset+reservedTimestampSize+reservedKeySize
+	keySize, valueSize := littleEndian.Uint32(content[keySizeOffset:]), littleEndian.Uint32(content[valueSizeOffset:])
+
+	// decodesToEnd[start-offset] is true if the entries from start decode cleanly to the end of content
+	decodesToEnd := make([]bool, uint32(len(content))-offset+1)
+	decodesToEnd[len(decodesToEnd)-1] = true
+	for start := uint32(len(content)) - 1; start > offset+headerSize; start-- {
+		_, next, err := decodeFrom(content, start, cipher)
+		decodesToEnd[start-offset] = err == nil && decodesToEnd[next-offset]
+	}
+
+	for start := offset + headerSize + 1; start < uint32(len(content)); start++ {
+		if !decodesToEnd[start-offset] {
+			continue
+		}
+		length := start - offset - headerSize
+		if length >= keySize && decodesWithSize(content[offset:start], valueSizeOffset-offset, length-keySize, cipher) {
+			return true
+		}
+		if length >= valueSize && decodesWithSize(content[offset:start], keySizeOffset-offset, length-valueSize, cipher) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodesWithSize returns true if entry, with the size at sizeOffset replaced by size, decodes cleanly as a single entry
+func decodesWithSize(entry []byte, sizeOffset uint32, size uint32, cipher *segmentCipher) bool {
+	corrected := slices.Clone(entry)
+	littleEndian.PutUint32(corrected[sizeOffset:], size)
+	_, next, err := decodeFrom(corrected, 0, cipher)
+	return err == nil && next == uint32(len(corrected))
+}
@@ -2,6 +2,8 @@ package kv
 
 import (
 	"ashishkujoy/bitcask/clock"
+	"ashishkujoy/bitcask/config"
+	"bytes"
 	"os"
 	"testing"
 
@@ -16,7 +18,7 @@ func TestNewSegmentWithOneEntry(t *testing.T) {
 		segment.remove()
 	}()
 
-	entry := NewEntry[serializableKey]("Topic", []byte("Bitcask DB"), clock.NewSystemClock())
+	entry := NewEntry([]byte("Topic"), []byte("Bitcask DB"), clock.NewSystemClock())
 	appendEntryResponse, err := segment.append(entry)
 	require.NoError(t, err)
 
@@ -33,7 +35,7 @@ func TestNewSegmentWithOneEntryAndPerformSync(t *testing.T) {
 		segment.remove()
 	}()
 
-	entry := NewEntry[serializableKey]("Topic", []byte("Bitcask DB"), clock.NewSystemClock())
+	entry := NewEntry([]byte("Topic"), []byte("Bitcask DB"), clock.NewSystemClock())
 	appendEntryResponse, _ := segment.append(entry)
 	segment.sync()
 
@@ -51,8 +53,8 @@ func TestNewSegmentWithTwoEntries(t *testing.T) {
 	defer func() {
 		segment.remove()
 	}()
-	_, _ = segment.append(NewEntry[serializableKey]("Key1", []byte("Value1"), clock.NewSystemClock()))
-	appendResponse, _ := segment.append(NewEntry[serializableKey]("Key2", []byte("Value2"), clock.NewSystemClock()))
+	_, _ = segment.append(NewEntry([]byte("Key1"), []byte("Value1"), clock.NewSystemClock()))
+	appendResponse, _ := segment.append(NewEntry([]byte("Key2"), []byte("Value2"), clock.NewSystemClock()))
 
 	storedEntry, err := segment.read(appendResponse.Offset, appendResponse.EntryLength)
 	require.NoError(t, err)
@@ -68,8 +70,8 @@ func TestNewSegmentWithTwoEntriesAndValidateOffsets(t *testing.T) {
 	defer func() {
 		segment.remove()
 	}()
-	appendResponse1, _ := segment.append(NewEntry[serializableKey]("Key1", []byte("Value1"), clock.NewSystemClock()))
-	appendResponse2, _ := segment.append(NewEntry[serializableKey]("Key2", []byte("Value2"), clock.NewSystemClock()))
+	appendResponse1, _ := segment.append(NewEntry([]byte("Key1"), []byte("Value1"), clock.NewSystemClock()))
+	appendResponse2, _ := segment.append(NewEntry([]byte("Key2"), []byte("Value2"), clock.NewSystemClock()))
 
 	require.Equal(t, appendResponse1.Offset, int64(0))
 	require.Equal(t, appendResponse2.Offset, int64(appendResponse1.EntryLength))
@@ -80,7 +82,7 @@ func TestNewSegmentWithDeleteEntry(t *testing.T) {
 	defer func() {
 		segment.remove()
 	}()
-	appendResponse, _ := segment.append(NewDeleteEntry[serializableKey]("Key", clock.NewSystemClock()))
+	appendResponse, _ := segment.append(NewDeleteEntry([]byte("Key"), clock.NewSystemClock()))
 
 	storedEntry, _ := segment.read(appendResponse.Offset, appendResponse.EntryLength)
 
@@ -94,12 +96,10 @@ func TestNewSegmentByReadingAllEntries(t *testing.T) {
 		segment.remove()
 	}()
 
-	_, _ = segment.append(NewEntry[serializableKey]("Key1", []byte("Value1"), clock.NewSystemClock()))
-	_, _ = segment.append(NewEntry[serializableKey]("Key2", []byte("Value2"), clock.NewSystemClock()))
+	_, _ = segment.append(NewEntry([]byte("Key1"), []byte("Value1"), clock.NewSystemClock()))
+	_, _ = segment.append(NewEntry([]byte("Key2"), []byte("Value2"), clock.NewSystemClock()))
 
-	entries, _ := segment.ReadFull(func(b []byte) serializableKey {
-		return serializableKey(string(b))
-	})
+	entries, _ := segment.ReadFull(keyCodec)
 
 	require.Equal(t, string(entries[0].Key), "Key1")
 	require.Equal(t, string(entries[1].Key), "Key2")
@@ -111,12 +111,53 @@ func TestNewSegmentAfterStoppingWrites(t *testing.T) {
 		segment.remove()
 	}()
 
-	appendResponse, _ := segment.append(NewEntry[serializableKey]("Key1", []byte("Value1"), clock.NewSystemClock()))
+	appendResponse, _ := segment.append(NewEntry([]byte("Key1"), []byte("Value1"), clock.NewSystemClock()))
 	segment.stopWrites()
-	_, err := segment.append(NewEntry[serializableKey]("Key2", []byte("Value2"), clock.NewSystemClock()))
+	_, err := segment.append(NewEntry([]byte("Key2"), []byte("Value2"), clock.NewSystemClock()))
 	require.Error(t, err)
 
 	storedEntry, err := segment.read(appendResponse.Offset, appendResponse.EntryLength)
 	require.NoError(t, err)
 	require.Equal(t, string(storedEntry.Key), "Key1")
 }
+
+func TestEncryptedSegmentByReloadingAllEntries(t *testing.T) {
+	keyProvider := config.NewStaticKeyProvider(map[uint32][]byte{7: bytes.Repeat([]byte{1}, 32)}, 7)
+	segment, err := NewEncryptedSegment[serializableKey](6, os.TempDir(), keyProvider)
+	require.NoError(t, err)
+	defer func() {
+		segment.remove()
+	}()
+
+	appendResponse, _ := segment.append(NewEntry([]byte("Key1"), []byte("Sensitive Value"), clock.NewSystemClock()))
+	_, _ = segment.append(NewDeleteEntry([]byte("Key2"), clock.NewSystemClock()))
+	segment.stopWrites()
+
+	content, _ := os.ReadFile(segment.filePath)
+	require.False(t, bytes.Contains(content, []byte("Sensitive Value")))
+
+	reloaded, err := ReloadInactiveSegment[serializableKey](6, os.TempDir(), keyProvider)
+	require.NoError(t, err)
+
+	storedEntry, err := reloaded.read(appendResponse.Offset, appendResponse.EntryLength)
+	require.NoError(t, err)
+	require.Equal(t, "Sensitive Value", string(storedEntry.Value))
+
+	entries, err := reloaded.ReadFull(keyCodec)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	require.Equal(t, uint32(appendResponse.Offset), entries[0].KeyOffset)
+	require.True(t, entries[1].Deleted)
+}
+
+func TestReloadEncryptedSegmentWithoutKeyProvider(t *testing.T) {
+	keyProvider := config.NewStaticKeyProvider(map[uint32][]byte{1: bytes.Repeat([]byte{1}, 16)}, 1)
+	segment, _ := NewEncryptedSegment[serializableKey](7, os.TempDir(), keyProvider)
+	defer func() {
+		segment.remove()
+	}()
+	_, _ = segment.append(NewEntry([]byte("Key1"), []byte("Value1"), clock.NewSystemClock()))
+
+	_, err := ReloadInactiveSegment[serializableKey](7, os.TempDir(), nil)
+	require.Error(t, err)
+}
@@ -4,10 +4,14 @@ import (
 	"ashishkujoy/bitcask/clock"
 	"ashishkujoy/bitcask/config"
 	"ashishkujoy/bitcask/kv/id"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
+	"math"
 	"os"
-	"strconv"
-	"strings"
+	"slices"
 )
 
 type Segments[Key config.BitcaskKey] struct {
@@ -17,33 +21,88 @@ type Segments[Key config.BitcaskKey] struct {
 	clock              clock.Clock
 	maxSegmentByteSize uint64
 	directory          string
+	compression        config.Compression
+	keyProvider        config.KeyProvider
+	maxKeySize         uint32
+	maxValueSize       uint32
+	syncEveryWrite     bool
+	keyCodec           config.KeyCodec[Key]
+	pins               map[uint64]int           // number of pins held on each inactive segment
+	removedWhilePinned map[uint64]*Segment[Key] // segments removed by merge whose files are kept until they are unpinned
+	reclaimedBytes     int64                    // bytes of the merged segments minus the bytes written back by merge, since the segments were created
+	logger             *slog.Logger
+	onError            func(error)
+	listener           config.Listener
+	readOnly           bool // all the segments are inactive, and none is ever created or removed
+}
+
+// SegmentStats describes the segments on disk
+type SegmentStats struct {
+	Segments       int   // number of segments, the active one included
+	TotalBytes     int64 // bytes of all the segments
+	ActiveBytes    int64 // bytes of the active segment
+	ReclaimedBytes int64 // bytes freed by merge since the segments were created
 }
 
 type WriteBackResponse[Key config.BitcaskKey] struct {
+	Keyspace            uint32
 	Key                 Key
+	EncodedKey          []byte
 	AppendEntryResponse *AppendEntryResponse
 }
 
+// NewSegments creates the active segment in the directory and reloads the inactive segments present in it, converting keys using keyCodec
 func NewSegments[Key config.BitcaskKey](
 	directory string,
 	maxSegmentByteSize uint64,
 	clock clock.Clock,
+	keyCodec config.KeyCodec[Key],
 ) (*Segments[Key], error) {
-	idGenerator := id.NewTimestampBasedFileIdGenerator(clock)
-	segmentId := idGenerator.Next()
-	segment, err := NewSegment[Key](segmentId, directory)
-
+	segmentsConfig, err := config.New[Key](
+		directory,
+		config.WithMaxSegmentSize(maxSegmentByteSize),
+		config.WithClock(clock),
+		config.WithKeyCodec(keyCodec),
+	)
 	if err != nil {
 		return nil, err
 	}
+	return NewSegmentsFromConfig(segmentsConfig)
+}
+
+// NewSegmentsFromConfig creates the active segment in the configured directory and reloads the inactive segments present in it
+func NewSegmentsFromConfig[Key config.BitcaskKey](config *config.Config[Key]) (*Segments[Key], error) {
+	if err := validateSizeLimits(config); err != nil {
+		return nil, err
+	}
 
+	idGenerator := id.NewTimestampBasedFileIdGenerator(config.Clock())
 	segments := Segments[Key]{
-		activeSegment:      segment,
-		clock:              clock,
-		directory:          directory,
-		maxSegmentByteSize: maxSegmentByteSize,
+		clock:              config.Clock(),
+		directory:          config.Directory(),
+		maxSegmentByteSize: config.MaxSegmentSizeInBytes(),
 		inactiveSegments:   map[uint64]*Segment[Key]{},
 		fileIdGenerator:    idGenerator,
+		compression:        config.Compression(),
+		keyProvider:        config.KeyProvider(),
+		maxKeySize:         config.MaxKeySize(),
+		maxValueSize:       config.MaxValueSize(),
+		syncEveryWrite:     config.SyncPolicy().SyncsEveryWrite(),
+		keyCodec:           config.KeyCodec(),
+		pins:               map[uint64]int{},
+		removedWhilePinned: map[uint64]*Segment[Key]{},
+		logger:             config.Logger(),
+		onError:            config.OnError(),
+		listener:           config.Listener(),
+		readOnly:           config.ReadOnly(),
+	}
+
+	if !segments.readOnly {
+		segment, err := segments.newSegment(idGenerator.Next())
+		if err != nil {
+			return nil, err
+		}
+		segments.activeSegment = segment
 	}
 
 	if err := segments.reload(); err != nil {
@@ -54,28 +113,19 @@ func NewSegments[Key config.BitcaskKey](
 }
 
 func (segments *Segments[Key]) reload() error {
-	entries, err := os.ReadDir(segments.directory)
+	segmentFiles, err := ListSegmentFiles(segments.directory)
 
 	if err != nil {
 		return err
 	}
 
-	suffix := segmentFilePrefix + "." + segmentFileSuffix
-
-	for _, entry := range entries {
-		filepath := entry.Name()
-		if strings.HasSuffix(filepath, suffix) {
-			fileId, err := strconv.ParseUint(strings.Split(entry.Name(), "_")[0], 10, 64)
+	for _, segmentFile := range segmentFiles {
+		if !segments.isActive(segmentFile.FileId) {
+			segment, err := ReloadInactiveSegment[Key](segmentFile.FileId, segments.directory, segments.keyProvider)
 			if err != nil {
 				return err
 			}
-			if fileId != segments.activeSegment.fileId {
-				segment, err := ReloadInactiveSegment[Key](fileId, segments.directory)
-				if err != nil {
-					return err
-				}
-				segments.inactiveSegments[fileId] = segment
-			}
+			segments.inactiveSegments[segmentFile.FileId] = segment
 		}
 	}
 
@@ -84,58 +134,228 @@ func (segments *Segments[Key]) reload() error {
 
 // Append performs an append operation in the active segment file.
 // Before the append operation can be done, the size of the active segment is checked.
-// If its size < the size of segment threshold, the key value pair is appended to the active segment, else the active segment is rolled-over
+// If the entry fits within the size of segment threshold, it is appended to the active segment, else the active segment is rolled-over first
 func (segments *Segments[Key]) Append(key Key, value []byte) (*AppendEntryResponse, error) {
-	if err := segments.maybeRolloverActiveSegment(); err != nil {
+	return segments.AppendIn(DefaultKeyspace, key, value)
+}
+
+// AppendIn is Append for a key of the keyspace identified by keyspace
+func (segments *Segments[Key]) AppendIn(keyspace uint32, key Key, value []byte) (*AppendEntryResponse, error) {
+	return segments.AppendEncoded(keyspace, segments.keyCodec.Encode(key), value)
+}
+
+// AppendEncoded is AppendIn for a key which is already encoded, such as the name of a keyspace appended to the catalog
+func (segments *Segments[Key]) AppendEncoded(keyspace uint32, encodedKey []byte, value []byte) (*AppendEntryResponse, error) {
+	if segments.readOnly {
+		return nil, ErrReadOnly
+	}
+	if err := segments.validate(encodedKey, value); err != nil {
 		return nil, err
 	}
-	return segments.activeSegment.append(NewEntry(key, value, segments.clock))
+	return segments.appendToActiveSegment(NewEntry(encodedKey, value, segments.clock).compressedWith(segments.compression).inKeyspace(keyspace))
 }
 
 // AppendDelete performs an append operation in the active segment file.
 // Before the append operation can be done, the size of the active segment is checked.
-// If its size < the size of segment threshold, the key value pair is appended to the active segment, else the active segment is rolled-over
+// If the entry fits within the size of segment threshold, it is appended to the active segment, else the active segment is rolled-over first
 func (segments *Segments[Key]) AppendDelete(key Key) (*AppendEntryResponse, error) {
-	if err := segments.maybeRolloverActiveSegment(); err != nil {
+	return segments.AppendDeleteIn(DefaultKeyspace, key)
+}
+
+// AppendDeleteIn is AppendDelete for a key of the keyspace identified by keyspace
+func (segments *Segments[Key]) AppendDeleteIn(keyspace uint32, key Key) (*AppendEntryResponse, error) {
+	return segments.AppendDeleteEncoded(keyspace, segments.keyCodec.Encode(key))
+}
+
+// AppendDeleteEncoded is AppendDeleteIn for a key which is already encoded, such as the name of a keyspace dropped from the catalog
+func (segments *Segments[Key]) AppendDeleteEncoded(keyspace uint32, encodedKey []byte) (*AppendEntryResponse, error) {
+	if segments.readOnly {
+		return nil, ErrReadOnly
+	}
+	if err := segments.validate(encodedKey, nil); err != nil {
 		return nil, err
 	}
+	return segments.appendToActiveSegment(NewDeleteEntry(encodedKey, segments.clock).inKeyspace(keyspace))
+}
 
-	return segments.activeSegment.append(NewDeleteEntry(key, segments.clock))
+// appendToActiveSegment appends the entry to the active segment, rolling it over first if the entry does not fit
+func (segments *Segments[Key]) appendToActiveSegment(entry *Entry) (*AppendEntryResponse, error) {
+	encoded, err := segments.activeSegment.encode(entry)
+	if err != nil {
+		return nil, err
+	}
+	if !segments.activeSegment.holds(len(encoded)) {
+		return nil, ErrEntryTooLarge
+	}
+	if !segments.activeSegment.fits(len(encoded), segments.maxSegmentByteSize) {
+		if err := segments.rolloverActiveSegment(); err != nil {
+			return nil, err
+		}
+		// the new segment may be encrypted with another key
+		if encoded, err = segments.activeSegment.encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	appendEntryResponse, err := segments.activeSegment.appendEncoded(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if segments.syncEveryWrite {
+		if err := segments.activeSegment.sync(); err != nil {
+			return nil, err
+		}
+	}
+	return appendEntryResponse, nil
+}
+
+// BatchEntry is a put of the key and the value, or a delete of the key if Deleted is set, appended by AppendBatch
+type BatchEntry[Key config.BitcaskKey] struct {
+	Key     Key
+	Value   []byte
+	Deleted bool
+}
+
+// AppendBatch appends the entries to the same segment as one batch, which a reload drops unless its last entry was written, and returns their responses in order
+func (segments *Segments[Key]) AppendBatch(batch []BatchEntry[Key]) ([]*AppendEntryResponse, error) {
+	if segments.readOnly {
+		return nil, ErrReadOnly
+	}
+	entries := make([]*Entry, 0, len(batch))
+	for index, batchEntry := range batch {
+		encodedKey := segments.keyCodec.Encode(batchEntry.Key)
+		entry := NewEntry(encodedKey, batchEntry.Value, segments.clock).compressedWith(segments.compression)
+		if batchEntry.Deleted {
+			entry = NewDeleteEntry(encodedKey, segments.clock)
+		}
+		if err := segments.validate(encodedKey, entry.value.value); err != nil {
+			return nil, err
+		}
+		if index < len(batch)-1 {
+			entry.inBatch()
+		}
+		entries = append(entries, entry)
+	}
+
+	encoded, length, err := segments.activeSegment.encodeAll(entries)
+	if err != nil {
+		return nil, err
+	}
+	if !segments.activeSegment.holds(length) {
+		return nil, ErrBatchTooLarge
+	}
+	if !segments.activeSegment.fits(length, segments.maxSegmentByteSize) {
+		if err := segments.rolloverActiveSegment(); err != nil {
+			return nil, err
+		}
+		// the new segment may be encrypted with another key
+		if encoded, _, err = segments.activeSegment.encodeAll(entries); err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make([]*AppendEntryResponse, 0, len(encoded))
+	for _, encodedEntry := range encoded {
+		response, err := segments.activeSegment.appendEncoded(encodedEntry)
+		if err != nil {
+			// the entries appended so far end their segment, where a reload drops them
+			if rolloverErr := segments.rolloverActiveSegment(); rolloverErr != nil {
+				segments.reportError("failed to roll over the segment of an interrupted batch", rolloverErr)
+			}
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+	if segments.syncEveryWrite {
+		if err := segments.activeSegment.sync(); err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
 }
 
 // Read performs a read operation from the offset in the segment file. This method is invoked in the Get operation
 func (segments *Segments[Key]) Read(fileId uint64, offset int64, size uint32) (*StoredEntry, error) {
-	if segments.activeSegment.fileId == fileId {
-		return segments.activeSegment.read(offset, size)
+	if segments.isActive(fileId) {
+		storedEntry, err := segments.activeSegment.read(offset, size)
+		segments.DetectCorruption(fileId, err)
+		return storedEntry, err
 	}
 
 	segment, ok := segments.inactiveSegments[fileId]
+	if !ok {
+		segment, ok = segments.removedWhilePinned[fileId]
+	}
 
 	if !ok {
-		return nil, fmt.Errorf("invalid fileId %v", fileId)
+		return nil, fmt.Errorf("%w: invalid fileId %v", ErrSegmentNotFound, fileId)
 	}
 
-	return segment.read(offset, size)
+	storedEntry, err := segment.read(offset, size)
+	segments.DetectCorruption(fileId, err)
+	return storedEntry, err
+}
+
+// ReadRecordAt decodes the entry starting at offset in the segment identified by fileId. This operation is performed while tailing the log.
+func (segments *Segments[Key]) ReadRecordAt(fileId uint64, offset int64) (*Record, error) {
+	segment, ok := segments.inactiveSegments[fileId]
+	if segments.isActive(fileId) {
+		segment, ok = segments.activeSegment, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid fileId %v", ErrSegmentNotFound, fileId)
+	}
+	record, err := segment.readRecordAt(offset)
+	segments.DetectCorruption(fileId, err)
+	return record, err
+}
+
+// Contains returns true if the segment identified by fileId is the active segment or an inactive segment
+func (segments *Segments[Key]) Contains(fileId uint64) bool {
+	_, ok := segments.inactiveSegments[fileId]
+	return ok || segments.isActive(fileId)
+}
+
+// NextFileId returns the fileId of the oldest segment written after the segment identified by fileId (0 for the oldest segment), and false if there is none
+func (segments *Segments[Key]) NextFileId(fileId uint64) (uint64, bool) {
+	if segments.isActive(fileId) {
+		return 0, false
+	}
+	next := uint64(math.MaxUint64)
+	if segments.activeSegment != nil {
+		next = segments.activeSegment.fileId
+	}
+	for inactiveFileId := range segments.inactiveSegments {
+		if inactiveFileId > fileId && inactiveFileId < next {
+			next = inactiveFileId
+		}
+	}
+	if next == math.MaxUint64 {
+		return 0, false
+	}
+	return next, true
+}
+
+// ReadInactiveSegments reads the oldest `totalSegments` inactive segments, in the order they were written. This operation is performed during merge.
+func (segments *Segments[Key]) ReadInactiveSegments(totalSegments int) ([]uint64, [][]*MappedStoredEntry[Key], error) {
+	return segments.ReadInactiveSegmentsContext(context.Background(), totalSegments)
 }
 
-// ReadInactiveSegments reads inactive segments identified by `totalSegments`. This operation is performed during merge.
-// keyMapper is used to map a byte slice Key to a generically typed Key. keyMapper is basically a means to perform deserialization of keys which is necessary to update the state in KeyDirectory after the merge operation is done, more on this is mentioned in KeyDirectory.go
-func (segments *Segments[Key]) ReadInactiveSegments(
-	totalSegments int,
-	keyMapper func([]byte) Key,
-) ([]uint64, [][]*MappedStoredEntry[Key], error) {
+// ReadInactiveSegmentsContext is ReadInactiveSegments giving up with ctx.Err() as soon as ctx is done, even in the middle of a segment
+func (segments *Segments[Key]) ReadInactiveSegmentsContext(ctx context.Context, totalSegments int) ([]uint64, [][]*MappedStoredEntry[Key], error) {
 	index := 0
 	contents := make([][]*MappedStoredEntry[Key], totalSegments)
 	fileIds := make([]uint64, totalSegments)
 
-	for _, segment := range segments.inactiveSegments {
+	for _, fileId := range segments.InactiveFileIds() {
 		if index >= totalSegments {
 			break
 		}
 
-		mappedStoredEntry, err := segment.ReadFull(keyMapper)
+		segment := segments.inactiveSegments[fileId]
+		mappedStoredEntry, err := segment.ReadFullContext(ctx, segments.keyCodec)
 
 		if err != nil {
+			segments.DetectCorruption(fileId, err)
 			return nil, nil, err
 		}
 
@@ -148,98 +368,268 @@ func (segments *Segments[Key]) ReadInactiveSegments(
 }
 
 // ReadAllInactiveSegments reads all the inactive segments. This operation is performed during merge.
-// keyMapper is used to map a byte slice Key to a generically typed Key. keyMapper is basically a means to perform deserialization of keys which is necessary to update the state in KeyDirectory after the merge operation is done, more on this is mentioned in KeyDirectory.go and Worker.go inside merge/ package.
-func (segments *Segments[Key]) ReadAllInactiveSegments(
-	keyMapper func([]byte) Key,
-) ([]uint64, [][]*MappedStoredEntry[Key], error) {
-	return segments.ReadInactiveSegments(len(segments.inactiveSegments), keyMapper)
+// Keys are decoded using the key codec, more on this is mentioned in KeyDirectory.go and Worker.go inside merge/ package.
+func (segments *Segments[Key]) ReadAllInactiveSegments() ([]uint64, [][]*MappedStoredEntry[Key], error) {
+	return segments.ReadInactiveSegments(len(segments.inactiveSegments))
+}
+
+// ReadAllInactiveSegmentsContext is ReadAllInactiveSegments giving up with ctx.Err() once ctx is done
+func (segments *Segments[Key]) ReadAllInactiveSegmentsContext(ctx context.Context) ([]uint64, [][]*MappedStoredEntry[Key], error) {
+	return segments.ReadInactiveSegmentsContext(ctx, len(segments.inactiveSegments))
 }
 
 // WriteBack writes back the changes (merged changes) to new inactive segments. This operation is performed during merge.
 // It writes all the changes into M new inactive segments and once those changes are written to the new inactive segment(s), the state of the keys present in the `changes` parameter is updated in the KeyDirectory. More on this is mentioned in Worker.go inside merge/ package.
-func (segments *Segments[Key]) WriteBack(changes map[Key]*MappedStoredEntry[Key]) ([]*WriteBackResponse[Key], error) {
-	segment, err := NewSegment[Key](segments.fileIdGenerator.Next(), segments.directory)
+// The new segments take the fileIds following the newest of the merged segments identified by mergedFileIds.
+func (segments *Segments[Key]) WriteBack(mergedFileIds []uint64, changes map[Key]*MappedStoredEntry[Key]) ([]*WriteBackResponse[Key], error) {
+	return segments.WriteBackEntries(mergedFileIds, EntriesOf(changes))
+}
+
+// EntriesOf returns the entries of changes, each one a copy of the entry keyed by the key of changes it is found at
+func EntriesOf[Key config.BitcaskKey](changes map[Key]*MappedStoredEntry[Key]) []*MappedStoredEntry[Key] {
+	entries := make([]*MappedStoredEntry[Key], 0, len(changes))
+	for key, value := range changes {
+		entry := *value
+		entry.Key = key
+		entries = append(entries, &entry)
+	}
+	return entries
+}
 
+// WriteBackEntries is WriteBack for the merged entries of any keyspace, removing the segments written so far if the changes can not all be written
+func (segments *Segments[Key]) WriteBackEntries(mergedFileIds []uint64, changes []*MappedStoredEntry[Key]) ([]*WriteBackResponse[Key], error) {
+	if segments.readOnly {
+		return nil, ErrReadOnly
+	}
+	writeBackResponses, writtenSegments, err := segments.writeBackEntries(segments.mergeFileIds(mergedFileIds), changes)
+	if len(writtenSegments) > 0 {
+		segments.stopWrites(writtenSegments[len(writtenSegments)-1])
+	}
 	if err != nil {
+		for _, segment := range writtenSegments {
+			segments.remove(segment)
+		}
 		return nil, err
 	}
-	segments.inactiveSegments[segment.fileId] = segment
-	index := 0
+	for _, segment := range writtenSegments {
+		segments.inactiveSegments[segment.fileId] = segment
+	}
+	segments.countReclaimedBytes(mergedFileIds, writtenSegments)
+	return writeBackResponses, nil
+}
+
+// writeBackEntries writes the changes to new segments whose fileIds are handed out by nextFileId, and returns the segments written along with any error
+func (segments *Segments[Key]) writeBackEntries(nextFileId func() (uint64, error), changes []*MappedStoredEntry[Key]) ([]*WriteBackResponse[Key], []*Segment[Key], error) {
+	fileId, err := nextFileId()
+	if err != nil {
+		return nil, nil, err
+	}
+	segment, err := segments.newSegment(fileId)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	writtenSegments := []*Segment[Key]{segment}
 	writeBackResponses := make([]*WriteBackResponse[Key], len(changes))
 
-	for key, value := range changes {
-		appendEntryResponse, err := segment.append(NewEntryPreservingTimestamp(
-			value.Key,
+	for index, value := range changes {
+		encodedKey := value.EncodedKey
+		if value.Keyspace != CatalogKeyspace {
+			encodedKey = segments.keyCodec.Encode(value.Key)
+		}
+		entry := NewEntryPreservingTimestamp(
+			encodedKey,
 			value.Value,
 			value.Timestamp,
 			segments.clock,
-		))
+		).compressedWith(segments.compression).inKeyspace(value.Keyspace)
+		encoded, err := segment.encode(entry)
 
 		if err != nil {
-			return nil, err
+			return nil, writtenSegments, err
 		}
 
-		writeBackResponses[index] = &WriteBackResponse[Key]{
-			Key:                 key,
-			AppendEntryResponse: appendEntryResponse,
+		if !segment.fits(len(encoded), segments.maxSegmentByteSize) {
+			segment, err = segments.rolloverSegment(segment, nextFileId)
+			if err != nil {
+				return nil, writtenSegments, err
+			}
+			writtenSegments = append(writtenSegments, segment)
+			if encoded, err = segment.encode(entry); err != nil {
+				return nil, writtenSegments, err
+			}
 		}
-		index = index + 1
-		newSegment, err := segments.maybeRolloverSegment(segment)
+		appendEntryResponse, err := segment.appendEncoded(encoded)
 
 		if err != nil {
-			return nil, err
+			return nil, writtenSegments, err
 		}
 
-		if newSegment != nil {
-			segments.inactiveSegments[newSegment.fileId] = newSegment
-			segment.stopWrites()
-			segment = newSegment
+		writeBackResponses[index] = &WriteBackResponse[Key]{
+			Keyspace:            value.Keyspace,
+			Key:                 value.Key,
+			EncodedKey:          encodedKey,
+			AppendEntryResponse: appendEntryResponse,
 		}
 	}
+	return writeBackResponses, writtenSegments, nil
+}
 
-	return writeBackResponses, nil
+// countReclaimedBytes adds the bytes of the merged segments, minus the bytes of the segments written back in their place, to the reclaimed bytes
+func (segments *Segments[Key]) countReclaimedBytes(mergedFileIds []uint64, writtenSegments []*Segment[Key]) {
+	for _, fileId := range mergedFileIds {
+		if segment, ok := segments.inactiveSegments[fileId]; ok {
+			if size, err := segment.store.fileSize(); err == nil {
+				segments.reclaimedBytes += size
+			}
+		}
+	}
+	for _, segment := range writtenSegments {
+		segments.reclaimedBytes -= segment.sizeInBytes()
+	}
 }
 
-// RemoveActive removes the active segment file from disk
+// Stats returns the number and the sizes of the active and inactive segments, along with the bytes reclaimed by merge
+func (segments *Segments[Key]) Stats() (SegmentStats, error) {
+	stats := SegmentStats{
+		Segments:       len(segments.inactiveSegments),
+		ReclaimedBytes: segments.reclaimedBytes,
+	}
+	if segments.activeSegment != nil {
+		stats.Segments++
+		stats.ActiveBytes = segments.activeSegment.sizeInBytes()
+	}
+	stats.TotalBytes = stats.ActiveBytes
+	for _, segment := range segments.inactiveSegments {
+		size, err := segment.store.fileSize()
+		if err != nil {
+			return SegmentStats{}, err
+		}
+		stats.TotalBytes += size
+	}
+	return stats, nil
+}
+
+// RemoveActive removes the active segment file from disk. Read-only segments are left as they are.
 func (segments *Segments[Key]) RemoveActive() {
-	segments.activeSegment.remove()
+	if segments.readOnly {
+		return
+	}
+	segments.remove(segments.activeSegment)
 }
 
-// RemoveAllInactive removes all the inactive segment files from disk
+// RemoveAllInactive removes all the inactive segment files from disk, including the files of pinned segments removed by merge
 func (segments *Segments[Key]) RemoveAllInactive() {
+	if segments.readOnly {
+		return
+	}
 	for _, segment := range segments.inactiveSegments {
-		segment.remove()
+		segments.remove(segment)
+	}
+	for _, segment := range segments.removedWhilePinned {
+		segments.remove(segment)
 	}
 }
 
 // Remove removes all the inactive files identified by fileIds. This operation is called from WriteBack of KVStore which is called during merge operation
+// A pinned segment is no longer listed as an inactive segment, but its file is removed, and reads from it fail, only once it is unpinned.
 func (segments *Segments[Key]) Remove(fileIds []uint64) {
 	for _, fileId := range fileIds {
 		segment, ok := segments.inactiveSegments[fileId]
 		if ok {
-			segment.remove()
+			if segments.pins[fileId] > 0 {
+				segments.removedWhilePinned[fileId] = segment
+			} else {
+				segments.remove(segment)
+			}
 			delete(segments.inactiveSegments, fileId)
 		}
 	}
 }
 
+// RolloverActive makes the active segment, unless it has no entries, inactive and creates a new active segment
+func (segments *Segments[Key]) RolloverActive() error {
+	if segments.readOnly {
+		return nil
+	}
+	if segments.activeSegment.sizeInBytes() <= int64(segments.activeSegment.dataOffset()) {
+		return nil
+	}
+	if err := segments.activeSegment.sync(); err != nil {
+		return err
+	}
+	segments.stopWrites(segments.activeSegment)
+	newSegment, err := segments.newSegment(segments.fileIdGenerator.Next())
+	if err != nil {
+		return err
+	}
+	segments.makeActive(newSegment)
+	return nil
+}
+
+// Pin pins all the inactive segments, whose files stay on disk until they are unpinned, and returns their files ordered by fileId
+func (segments *Segments[Key]) Pin() ([]*SegmentFile, error) {
+	var segmentFiles []*SegmentFile
+	for _, fileId := range slices.Sorted(maps.Keys(segments.inactiveSegments)) {
+		segment := segments.inactiveSegments[fileId]
+		info, err := os.Stat(segment.filePath)
+		if err != nil {
+			return nil, err
+		}
+		segmentFiles = append(segmentFiles, &SegmentFile{FileId: fileId, Path: segment.filePath, Size: info.Size()})
+	}
+	for _, segmentFile := range segmentFiles {
+		segments.pins[segmentFile.FileId]++
+	}
+	return segmentFiles, nil
+}
+
+// PinAll pins the active and all the inactive segments, and returns their fileIds. The pins are released with Unpin.
+func (segments *Segments[Key]) PinAll() []uint64 {
+	fileIds := slices.Sorted(maps.Keys(segments.inactiveSegments))
+	if segments.activeSegment != nil {
+		fileIds = append(fileIds, segments.activeSegment.fileId)
+	}
+	for _, fileId := range fileIds {
+		segments.pins[fileId]++
+	}
+	return fileIds
+}
+
+// Unpin releases the pins on the segments identified by fileIds, removing the files of the segments merge removed in the meantime
+func (segments *Segments[Key]) Unpin(fileIds []uint64) {
+	for _, fileId := range fileIds {
+		if segments.pins[fileId] == 0 {
+			continue
+		}
+		segments.pins[fileId]--
+		if segments.pins[fileId] > 0 {
+			continue
+		}
+		delete(segments.pins, fileId)
+		if segment, ok := segments.removedWhilePinned[fileId]; ok {
+			segments.remove(segment)
+			delete(segments.removedWhilePinned, fileId)
+		}
+	}
+}
+
+// InactiveFileIds returns the fileIds of all the inactive segments, in the order they were written
+func (segments *Segments[Key]) InactiveFileIds() []uint64 {
+	return slices.Sorted(maps.Keys(segments.inactiveSegments))
+}
+
 // AllInactiveSegments returns all the inactive segments
 func (segments *Segments[Key]) AllInactiveSegments() map[uint64]*Segment[Key] {
 	return segments.inactiveSegments
 }
 
-// Sync Performs a file sync, ensures all the disk blocks (or pages) at the Kernel page cache are flushed to the disk
+// Sync Performs a file sync of the active segment, the inactive segments being synced when their writes stop
 func (segments *Segments[Key]) Sync() error {
-	err := segments.activeSegment.sync()
-	if err != nil {
-		return err
-	}
-	for _, segment := range segments.inactiveSegments {
-		if err := segment.sync(); err != nil {
-			return err
-		}
+	if segments.readOnly {
+		return nil
 	}
-	return nil
+	return segments.activeSegment.sync()
 }
 
 // Shutdown sets the active segment to nil and deletes all the keys from the inactive segments
@@ -250,27 +640,170 @@ func (segments *Segments[Key]) Shutdown() {
 	}
 }
 
-func (segments *Segments[Key]) maybeRolloverActiveSegment() error {
-	newSegment, err := segments.maybeRolloverSegment(segments.activeSegment)
+// rolloverActiveSegment stops the writes to the active segment and makes a new segment active
+func (segments *Segments[Key]) rolloverActiveSegment() error {
+	newSegment, err := segments.rolloverSegment(segments.activeSegment, segments.nextFileId)
 	if err != nil {
 		return err
 	}
-	if newSegment != nil {
-		segments.inactiveSegments[segments.activeSegment.fileId] = segments.activeSegment
-		segments.activeSegment = newSegment
-	}
+	segments.makeActive(newSegment)
 	return nil
 }
 
-func (segments *Segments[Key]) maybeRolloverSegment(segment *Segment[Key]) (*Segment[Key], error) {
-	if segments.maxSegmentByteSize <= uint64(segment.sizeInBytes()) {
-		segment.stopWrites()
-		id := segments.fileIdGenerator.Next()
-		newSegment, err := NewSegment[Key](id, segments.directory)
-		if err != nil {
-			return nil, err
+// rolloverSegment stops the writes to the segment and creates the segment following it, whose fileId is handed out by nextFileId
+func (segments *Segments[Key]) rolloverSegment(segment *Segment[Key], nextFileId func() (uint64, error)) (*Segment[Key], error) {
+	segments.stopWrites(segment)
+	id, err := nextFileId()
+	if err != nil {
+		return nil, err
+	}
+	return segments.newSegment(id)
+}
+
+// nextFileId returns the fileId of a new active segment
+func (segments *Segments[Key]) nextFileId() (uint64, error) {
+	return segments.fileIdGenerator.Next(), nil
+}
+
+// mergeFileIds returns a function handing out the fileIds following the newest merged segment, for the segments written back by merge
+func (segments *Segments[Key]) mergeFileIds(mergedFileIds []uint64) func() (uint64, error) {
+	if len(mergedFileIds) == 0 {
+		return segments.nextFileId
+	}
+	newestMerged := slices.Max(mergedFileIds)
+	limit := segments.activeSegment.fileId
+	for fileId := range segments.inactiveSegments {
+		if fileId > newestMerged && fileId < limit {
+			limit = fileId
+		}
+	}
+	fileId := newestMerged
+	return func() (uint64, error) {
+		fileId++
+		if fileId >= limit {
+			return 0, fmt.Errorf("no fileId is left for the merged segments between segments %v and %v", newestMerged, limit)
 		}
-		return newSegment, nil
+		return fileId, nil
+	}
+}
+
+// newSegment creates a new segment, which is encrypted with the current key if a key provider is configured
+func (segments *Segments[Key]) newSegment(fileId uint64) (*Segment[Key], error) {
+	var segment *Segment[Key]
+	var err error
+	if segments.keyProvider == nil {
+		segment, err = NewSegment[Key](fileId, segments.directory)
+	} else {
+		segment, err = NewEncryptedSegment[Key](fileId, segments.directory, segments.keyProvider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	segments.listener.OnSegmentCreated(config.SegmentCreated{FileId: fileId})
+	return segment, nil
+}
+
+// isActive returns true if fileId identifies the active segment, which read-only segments do not have
+func (segments *Segments[Key]) isActive(fileId uint64) bool {
+	return segments.activeSegment != nil && segments.activeSegment.fileId == fileId
+}
+
+// ReadOnly returns true if the segments were opened read-only
+func (segments *Segments[Key]) ReadOnly() bool {
+	return segments.readOnly
+}
+
+// makeActive makes the active segment inactive, and newSegment the active segment
+func (segments *Segments[Key]) makeActive(newSegment *Segment[Key]) {
+	previous := segments.activeSegment
+	segments.inactiveSegments[previous.fileId] = previous
+	segments.activeSegment = newSegment
+	segments.listener.OnSegmentRolledOver(config.SegmentRolledOver{
+		FileId:     previous.fileId,
+		NextFileId: newSegment.fileId,
+		Bytes:      previous.sizeInBytes(),
+	})
+}
+
+// Validate checks the key and the value against the configured size limits, without appending them
+func (segments *Segments[Key]) Validate(key Key, value []byte) error {
+	return segments.validate(segments.keyCodec.Encode(key), value)
+}
+
+// validate checks the encoded key and the value against the configured size limits
+func (segments *Segments[Key]) validate(encodedKey []byte, value []byte) error {
+	keySize := len(encodedKey)
+	if keySize == 0 {
+		return ErrEmptyKey
+	}
+	if segments.maxKeySize > 0 && keySize > int(segments.maxKeySize) {
+		return ErrKeyTooLarge
+	}
+	if segments.maxValueSize > 0 && len(value) > int(segments.maxValueSize) {
+		return ErrValueTooLarge
+	}
+	return nil
+}
+
+// validateSizeLimits ensures that an entry within the configured size limits fits in a single segment, including the header of an encrypted segment
+func validateSizeLimits[Key config.BitcaskKey](config *config.Config[Key]) error {
+	if config.MaxKeySize() == 0 || config.MaxValueSize() == 0 {
+		return nil
+	}
+	encrypted := config.KeyProvider() != nil
+	entrySize := maxEntrySize(config.MaxKeySize(), config.MaxValueSize(), encrypted)
+	if encrypted {
+		entrySize += uint64(encryptionHeaderSize)
+	}
+	if entrySize > config.MaxSegmentSizeInBytes() {
+		return fmt.Errorf(
+			"an entry with a %v byte key and a %v byte value can take %v bytes, which exceeds the maximum segment size of %v bytes",
+			config.MaxKeySize(), config.MaxValueSize(), entrySize, config.MaxSegmentSizeInBytes(),
+		)
+	}
+	return nil
+}
+
+// KeyCodec returns the codec used to convert keys to and from the bytes stored in the segments
+func (segments *Segments[Key]) KeyCodec() config.KeyCodec[Key] {
+	return segments.keyCodec
+}
+
+// stopWrites syncs the segment and stops the writes to it, reporting rather than returning a failure
+func (segments *Segments[Key]) stopWrites(segment *Segment[Key]) {
+	if err := segment.sync(); err != nil {
+		segments.reportError("failed to sync a segment", fmt.Errorf("syncing segment %v: %w", segment.fileId, err))
+	}
+	if err := segment.stopWrites(); err != nil {
+		segments.reportError("failed to close a segment for writes", fmt.Errorf("closing segment %v: %w", segment.fileId, err))
+	}
+}
+
+// remove removes the file of the segment. A file which can not be removed only wastes space, so the failure is reported rather than returned.
+func (segments *Segments[Key]) remove(segment *Segment[Key]) {
+	if err := segment.remove(); err != nil {
+		segments.reportError("failed to remove a segment", fmt.Errorf("removing segment %v: %w", segment.fileId, err))
+		return
+	}
+	segments.listener.OnSegmentRemoved(config.SegmentRemoved{FileId: segment.fileId})
+}
+
+// DetectCorruption tells the listener about err if it reports a corrupted entry of the segment identified by fileId
+func (segments *Segments[Key]) DetectCorruption(fileId uint64, err error) {
+	if errors.Is(err, ErrCorrupted) {
+		segments.listener.OnCorruptionDetected(config.CorruptionDetected{FileId: fileId, Err: err})
+	}
+}
+
+// Listener returns the listener of the configuration
+func (segments *Segments[Key]) Listener() config.Listener {
+	return segments.listener
+}
+
+// reportError logs err and passes it to the OnError callback of the configuration
+func (segments *Segments[Key]) reportError(message string, err error) {
+	segments.logger.Error(message, "error", err)
+	if segments.onError != nil {
+		segments.onError(err)
 	}
-	return nil, nil
 }
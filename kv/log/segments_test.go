@@ -2,6 +2,9 @@ package kv
 
 import (
 	"ashishkujoy/bitcask/clock"
+	"ashishkujoy/bitcask/config"
+	"bytes"
+	"log/slog"
 	"os"
 	"sort"
 	"testing"
@@ -10,7 +13,7 @@ import (
 )
 
 func TestAppendAndReadOnActiveSegment(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 100, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 100, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -26,7 +29,7 @@ func TestAppendAndReadOnActiveSegment(t *testing.T) {
 }
 
 func TestAppendSegmentInvolvingRollover(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 30, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 30, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -49,7 +52,7 @@ func TestAppendSegmentInvolvingRollover(t *testing.T) {
 }
 
 func TestAttemptToReadNonExistingSegment(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 100, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 100, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -57,11 +60,12 @@ func TestAttemptToReadNonExistingSegment(t *testing.T) {
 
 	_, err := segments.Read(212, 0, 10)
 
-	require.EqualError(t, err, "invalid fileId 212")
+	require.ErrorIs(t, err, ErrSegmentNotFound)
+	require.EqualError(t, err, "segment not found: invalid fileId 212")
 }
 
 func TestReadASegmentWithDeleteEntry(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 100, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 100, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -75,7 +79,7 @@ func TestReadASegmentWithDeleteEntry(t *testing.T) {
 }
 
 func TestReadsAPairOfInactiveSegmentFull(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -85,16 +89,14 @@ func TestReadsAPairOfInactiveSegmentFull(t *testing.T) {
 	_, _ = segments.Append("diskType", []byte("solid state drive"))
 	_, _ = segments.Append("engine", []byte("bitcask"))
 
-	_, pair, _ := segments.ReadInactiveSegments(2, func(b []byte) serializableKey {
-		return serializableKey(string(b))
-	})
+	_, pair, _ := segments.ReadInactiveSegments(2)
 
 	require.Equal(t, string(pair[0][0].Key), "topic")
 	require.Equal(t, string(pair[1][0].Key), "diskType")
 }
 
 func TestReadAllInactiveSegmentsFull(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -105,15 +107,29 @@ func TestReadAllInactiveSegmentsFull(t *testing.T) {
 	_, _ = segments.Append("engine", []byte("bitcask"))
 	_, _ = segments.Append("language", []byte("go language"))
 
-	_, pairs, _ := segments.ReadAllInactiveSegments(func(b []byte) serializableKey {
-		return serializableKey(string(b))
-	})
+	_, pairs, _ := segments.ReadAllInactiveSegments()
 
 	require.Equal(t, 3, len(pairs))
 }
 
+func TestInactiveFileIdsAreInTheOrderTheSegmentsWereWritten(t *testing.T) {
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock(), keyCodec)
+	defer func() {
+		segments.RemoveActive()
+		segments.RemoveAllInactive()
+	}()
+
+	var fileIds []uint64
+	for _, key := range []serializableKey{"topic", "diskType", "engine", "language", "paper"} {
+		response, _ := segments.Append(key, []byte("value"))
+		fileIds = append(fileIds, response.FileId)
+	}
+
+	require.Equal(t, fileIds[:4], segments.InactiveFileIds())
+}
+
 func TestWriteBackInvolvingRollover(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -124,7 +140,7 @@ func TestWriteBackInvolvingRollover(t *testing.T) {
 	changes["engine"] = &MappedStoredEntry[serializableKey]{Key: "engine", Value: []byte("Bitcask Dummy Engine")}
 	changes["topic"] = &MappedStoredEntry[serializableKey]{Key: "topic", Value: []byte("Microservices")}
 
-	_, _ = segments.WriteBack(changes)
+	_, _ = segments.WriteBack(nil, changes)
 	allKeys := allInactiveSegmentsKeys(segments)
 	expectedKeys := []serializableKey{"disk", "engine", "topic"}
 
@@ -132,7 +148,7 @@ func TestWriteBackInvolvingRollover(t *testing.T) {
 }
 
 func TestWriteBackNotInvolvingRollover(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 400, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 400, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -143,7 +159,7 @@ func TestWriteBackNotInvolvingRollover(t *testing.T) {
 	changes["engine"] = &MappedStoredEntry[serializableKey]{Key: "engine", Value: []byte("Bitcask Dummy Engine")}
 	changes["topic"] = &MappedStoredEntry[serializableKey]{Key: "topic", Value: []byte("Microservices")}
 
-	_, _ = segments.WriteBack(changes)
+	_, _ = segments.WriteBack(nil, changes)
 	allKeys := allInactiveSegmentsKeys(segments)
 	expectedKeys := []serializableKey{"disk", "engine", "topic"}
 
@@ -151,7 +167,7 @@ func TestWriteBackNotInvolvingRollover(t *testing.T) {
 }
 
 func TestRemoveInactiveSegmentById(t *testing.T) {
-	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock())
+	segments, _ := NewSegments[serializableKey](os.TempDir(), 8, clock.NewSystemClock(), keyCodec)
 	defer func() {
 		segments.RemoveActive()
 		segments.RemoveAllInactive()
@@ -173,7 +189,7 @@ func TestRemoveInactiveSegmentById(t *testing.T) {
 func allInactiveSegmentsKeys(segments *Segments[serializableKey]) []serializableKey {
 	var allKeys []serializableKey
 	for _, segment := range segments.inactiveSegments {
-		entries, _ := segment.ReadFull(func(b []byte) serializableKey { return serializableKey(b) })
+		entries, _ := segment.ReadFull(keyCodec)
 		for _, entry := range entries {
 			allKeys = append(allKeys, entry.Key)
 		}
@@ -183,3 +199,306 @@ func allInactiveSegmentsKeys(segments *Segments[serializableKey]) []serializable
 	})
 	return allKeys
 }
+
+func TestWriteBackRotatesTheEncryptionKey(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testKeyRotation")
+	defer os.RemoveAll(tempDir)
+	keyProvider := config.NewStaticKeyProvider(map[uint32][]byte{
+		1: bytes.Repeat([]byte{1}, 32),
+		2: bytes.Repeat([]byte{2}, 32),
+	}, 1)
+	segmentsConfig, _ := config.New[serializableKey](
+		tempDir,
+		config.WithMaxSegmentSize(8),
+		config.WithKeyProvider(keyProvider),
+		config.WithKeyCodec[serializableKey](keyCodec),
+	)
+	segments, _ := NewSegmentsFromConfig(segmentsConfig)
+
+	_, _ = segments.Append("topic", []byte("microservices"))
+	_, _ = segments.Append("disk", []byte("ssd"))
+	fileIds, entries, _ := segments.ReadAllInactiveSegments()
+
+	keyProvider.Rotate(2)
+	changes := make(map[serializableKey]*MappedStoredEntry[serializableKey])
+	for _, segmentEntries := range entries {
+		for _, entry := range segmentEntries {
+			changes[entry.Key] = entry
+		}
+	}
+	responses, err := segments.WriteBack(fileIds, changes)
+	require.NoError(t, err)
+	segments.Remove(fileIds)
+
+	for _, response := range responses {
+		require.Equal(t, uint32(2), segments.inactiveSegments[response.AppendEntryResponse.FileId].cipher.keyId)
+		storedEntry, err := segments.Read(response.AppendEntryResponse.FileId, response.AppendEntryResponse.Offset, response.AppendEntryResponse.EntryLength)
+		require.NoError(t, err)
+		require.Equal(t, changes[response.Key].Value, storedEntry.Value)
+	}
+}
+
+func TestAppendWithinAndBeyondSizeLimits(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testSizeLimits")
+	defer os.RemoveAll(tempDir)
+	segments, err := NewSegmentsFromConfig(config.NewConfigWithSizeLimits[serializableKey](tempDir, 100, nil, 8, 16))
+	require.NoError(t, err)
+
+	_, err = segments.Append("topic", []byte("microservices"))
+	require.NoError(t, err)
+
+	_, err = segments.Append("", []byte("microservices"))
+	require.ErrorIs(t, err, ErrEmptyKey)
+
+	_, err = segments.Append("a very long key", []byte("microservices"))
+	require.ErrorIs(t, err, ErrKeyTooLarge)
+
+	_, err = segments.Append("topic", []byte("a value longer than the limit"))
+	require.ErrorIs(t, err, ErrValueTooLarge)
+
+	_, err = segments.AppendDelete("")
+	require.ErrorIs(t, err, ErrEmptyKey)
+}
+
+func TestSizeLimitsExceedingTheSegmentSize(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testSizeLimitsExceedingSegment")
+	defer os.RemoveAll(tempDir)
+
+	_, err := NewSegmentsFromConfig(config.NewConfigWithSizeLimits[serializableKey](tempDir, 64, nil, 32, 32))
+	require.Error(t, err)
+}
+
+func TestAppendAnEntryLargerThanASegment(t *testing.T) {
+	segments, _ := NewSegments[serializableKey](t.TempDir(), 32, clock.NewSystemClock(), keyCodec)
+	defer segments.Shutdown()
+
+	large, err := segments.Append("topic", []byte("a value which does not fit in a segment"))
+	require.NoError(t, err)
+	disk, err := segments.Append("disk", []byte("SSD"))
+	require.NoError(t, err)
+
+	require.NotEqual(t, large.FileId, disk.FileId, "a segment beyond the maximum segment size is rolled over")
+	value, err := segments.Read(large.FileId, large.Offset, large.EntryLength)
+	require.NoError(t, err)
+	require.Equal(t, "a value which does not fit in a segment", string(value.Value))
+}
+
+func TestAppendRollsOverBeforeAnEntryWhichASegmentFileCanNotAddress(t *testing.T) {
+	defer func(size uint64) { maxSegmentFileSize = size }(maxSegmentFileSize)
+	maxSegmentFileSize = 64
+	segments, _ := NewSegments[serializableKey](t.TempDir(), 1024, clock.NewSystemClock(), keyCodec)
+	defer segments.Shutdown()
+
+	topic, err := segments.Append("topic", []byte("Databases"))
+	require.NoError(t, err)
+	disk, err := segments.Append("disk", []byte("SSD"))
+	require.NoError(t, err)
+	engine, err := segments.Append("engine", []byte("bitcask"))
+	require.NoError(t, err)
+
+	require.Equal(t, topic.FileId, disk.FileId)
+	require.NotEqual(t, disk.FileId, engine.FileId, "engine would take the segment beyond 64 bytes")
+}
+
+func TestAppendAnEntryLargerThanASegmentFileCanAddress(t *testing.T) {
+	defer func(size uint64) { maxSegmentFileSize = size }(maxSegmentFileSize)
+	maxSegmentFileSize = 56
+	segments, _ := NewSegments[serializableKey](t.TempDir(), 32, clock.NewSystemClock(), keyCodec)
+	defer segments.Shutdown()
+
+	_, err := segments.Append("topic", []byte("a value which does not fit in a segment"))
+	require.ErrorIs(t, err, ErrEntryTooLarge)
+	_, err = segments.Append("topic", []byte("Databases"))
+	require.NoError(t, err)
+}
+
+func TestFailuresToCloseASegmentAreReported(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testReportedErrors")
+	defer os.RemoveAll(tempDir)
+	var logs bytes.Buffer
+	var reported []error
+	segmentsConfig, _ := config.New[serializableKey](
+		tempDir,
+		config.WithMaxSegmentSize(8),
+		config.WithKeyCodec[serializableKey](keyCodec),
+		config.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		config.WithOnError(func(err error) { reported = append(reported, err) }),
+	)
+	segments, _ := NewSegmentsFromConfig(segmentsConfig)
+	defer segments.RemoveAllInactive()
+	defer segments.RemoveActive()
+
+	_, err := segments.Append("topic", []byte("microservices"))
+	require.NoError(t, err)
+	activeSegment := segments.activeSegment
+	require.NoError(t, activeSegment.stopWrites())
+
+	_, err = segments.Append("disk", []byte("ssd"))
+	require.NoError(t, err)
+	require.Len(t, reported, 1)
+	require.ErrorIs(t, reported[0], os.ErrClosed)
+	require.Contains(t, logs.String(), "failed to close a segment for writes")
+}
+
+func TestWriteBackInvolvingRolloverReportsNoError(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testWriteBackErrors")
+	defer os.RemoveAll(tempDir)
+	var reported []error
+	segmentsConfig, _ := config.New[serializableKey](
+		tempDir,
+		config.WithMaxSegmentSize(8),
+		config.WithKeyCodec[serializableKey](keyCodec),
+		config.WithOnError(func(err error) { reported = append(reported, err) }),
+	)
+	segments, _ := NewSegmentsFromConfig(segmentsConfig)
+
+	_, _ = segments.Append("topic", []byte("microservices"))
+	_, _ = segments.Append("disk", []byte("ssd"))
+	_, _ = segments.Append("engine", []byte("bitcask"))
+	fileIds, entries, _ := segments.ReadAllInactiveSegments()
+	changes := make(map[serializableKey]*MappedStoredEntry[serializableKey])
+	for _, segmentEntries := range entries {
+		for _, entry := range segmentEntries {
+			changes[entry.Key] = entry
+		}
+	}
+	_, err := segments.WriteBack(fileIds, changes)
+	require.NoError(t, err)
+	segments.Remove(fileIds)
+	require.Empty(t, reported)
+}
+
+// countingClock counts the calls made to it, so that segments created one after the other get consecutive fileIds, apart from the timestamps of the entries in between
+type countingClock struct {
+	now int64
+}
+
+func (clock *countingClock) Now() int64 {
+	clock.now++
+	return clock.now
+}
+
+func TestWriteBackPlacesTheMergedSegmentsBetweenTheMergedAndTheNewerSegments(t *testing.T) {
+	directory := t.TempDir()
+	segments, _ := NewSegments[serializableKey](directory, 8, clock.NewSystemClock(), keyCodec)
+	_, _ = segments.Append("topic", []byte("Databases"))
+	_, _ = segments.Append("disk", []byte("SSD"))
+	_, _ = segments.Append("topic", []byte("Microservices"))
+
+	fileIds, _, err := segments.ReadInactiveSegments(2)
+	require.NoError(t, err)
+	newerFileId, ok := segments.NextFileId(fileIds[1])
+	require.True(t, ok)
+
+	changes := make(map[serializableKey]*MappedStoredEntry[serializableKey])
+	changes["topic"] = &MappedStoredEntry[serializableKey]{Value: []byte("Databases")}
+	changes["disk"] = &MappedStoredEntry[serializableKey]{Value: []byte("SSD")}
+	responses, err := segments.WriteBack(fileIds, changes)
+	require.NoError(t, err)
+	segments.Remove(fileIds)
+
+	for _, response := range responses {
+		require.Greater(t, response.AppendEntryResponse.FileId, fileIds[1])
+		require.Less(t, response.AppendEntryResponse.FileId, newerFileId)
+	}
+
+	segments.Shutdown()
+	reloaded, err := NewSegments[serializableKey](directory, 8, clock.NewSystemClock(), keyCodec)
+	require.NoError(t, err)
+	_, entries, err := reloaded.ReadAllInactiveSegments()
+	require.NoError(t, err)
+	var topics []string
+	for _, segmentEntries := range entries {
+		for _, entry := range segmentEntries {
+			if entry.Key == "topic" {
+				topics = append(topics, string(entry.Value))
+			}
+		}
+	}
+	require.Equal(t, []string{"Databases", "Microservices"}, topics, "the merged entry is read before the newer one")
+}
+
+func TestWriteBackLeavesTheMergedSegmentsInPlaceWhenNoFileIdIsLeft(t *testing.T) {
+	directory := t.TempDir()
+	segments, _ := NewSegments[serializableKey](directory, 8, &countingClock{}, keyCodec)
+	_, _ = segments.Append("topic", []byte("Databases"))
+	_, _ = segments.Append("disk", []byte("SSD"))
+	_, _ = segments.Append("engine", []byte("bitcask"))
+	_, _ = segments.Append("language", []byte("go"))
+
+	fileIds, entries, err := segments.ReadInactiveSegments(3)
+	require.NoError(t, err)
+	filesBefore, _ := os.ReadDir(directory)
+	inactiveBefore := len(segments.AllInactiveSegments())
+
+	changes := make(map[serializableKey]*MappedStoredEntry[serializableKey])
+	for _, segmentEntries := range entries {
+		for _, entry := range segmentEntries {
+			changes[entry.Key] = entry
+		}
+	}
+	_, err = segments.WriteBack(fileIds, changes)
+	require.ErrorContains(t, err, "no fileId is left")
+
+	filesAfter, _ := os.ReadDir(directory)
+	require.Equal(t, filesBefore, filesAfter)
+	require.Len(t, segments.AllInactiveSegments(), inactiveBefore)
+	for _, fileId := range fileIds {
+		require.True(t, segments.Contains(fileId))
+	}
+}
+
+func TestAppendABatchInASingleSegment(t *testing.T) {
+	segments, _ := NewSegments[serializableKey](t.TempDir(), 20, clock.NewSystemClock(), keyCodec)
+	defer segments.Shutdown()
+	topic, err := segments.Append("topic", []byte("Databases"))
+	require.NoError(t, err)
+
+	batch := []BatchEntry[serializableKey]{
+		{Key: "disk", Value: []byte("SSD")},
+		{Key: "engine", Value: []byte("bitcask")},
+		{Key: "topic", Deleted: true},
+	}
+	responses, err := segments.AppendBatch(batch)
+	require.NoError(t, err)
+	require.NotEqual(t, topic.FileId, responses[0].FileId, "the active segment reached its maximum size, so the batch starts a new segment")
+	require.Equal(t, responses[0].FileId, responses[1].FileId)
+	require.Equal(t, responses[0].FileId, responses[2].FileId)
+
+	defer func(size uint64) { maxSegmentFileSize = size }(maxSegmentFileSize)
+	maxSegmentFileSize = 63
+	_, err = segments.AppendBatch(batch)
+	require.ErrorIs(t, err, ErrBatchTooLarge)
+}
+
+func TestReloadDropsABatchWhoseLastEntryIsMissing(t *testing.T) {
+	directory := t.TempDir()
+	segments, _ := NewSegments[serializableKey](directory, 1024, clock.NewSystemClock(), keyCodec)
+	_, _ = segments.Append("topic", []byte("Databases"))
+	responses, err := segments.AppendBatch([]BatchEntry[serializableKey]{
+		{Key: "disk", Value: []byte("SSD")},
+		{Key: "engine", Value: []byte("bitcask")},
+		{Key: "topic", Deleted: true},
+	})
+	require.NoError(t, err)
+	segments.Shutdown()
+
+	readKeys := func() []serializableKey {
+		reloaded, err := NewSegments[serializableKey](directory, 1024, clock.NewSystemClock(), keyCodec)
+		require.NoError(t, err)
+		defer reloaded.Shutdown()
+		_, entries, err := reloaded.ReadAllInactiveSegments()
+		require.NoError(t, err)
+		var keys []serializableKey
+		for _, segmentEntries := range entries {
+			for _, entry := range segmentEntries {
+				keys = append(keys, entry.Key)
+			}
+		}
+		return keys
+	}
+	require.Equal(t, []serializableKey{"topic", "disk", "engine", "topic"}, readKeys())
+
+	require.NoError(t, os.Truncate(segmentName(responses[2].FileId, directory), responses[2].Offset))
+	require.Equal(t, []serializableKey{"topic"}, readKeys(), "a batch interrupted before its last entry is dropped")
+}
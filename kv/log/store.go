@@ -1,15 +1,22 @@
 package kv
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 )
 
+// readFullChunkSize is the size of the chunks readFullContext reads the file in, checking the context between two chunks
+const readFullChunkSize = 1024 * 1024
+
 // Store is an abstraction that encapsulate read, write, remove and sync operation on a file
 type Store struct {
 	writer             *os.File
 	reader             *os.File
 	currentWriteOffset int64
+	writesStopped      bool // set once the write file pointer is closed
 }
 
 // NewStore creates an instance of Store from the filepath. It creates 2 file pointers:
@@ -75,7 +82,39 @@ func (store *Store) read(offset int64, size uint32) ([]byte, error) {
 }
 
 func (store *Store) readFull() ([]byte, error) {
-	return os.ReadFile(store.reader.Name())
+	return store.readFullContext(context.Background())
+}
+
+// readFullContext reads the whole file in chunks, and gives up with ctx.Err() as soon as ctx is done
+func (store *Store) readFullContext(ctx context.Context) ([]byte, error) {
+	size, err := store.fileSize()
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, 0, size)
+	chunk := make([]byte, readFullChunkSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := store.reader.ReadAt(chunk, int64(len(content)))
+		content = append(content, chunk[:n]...)
+		if errors.Is(err, io.EOF) {
+			return content, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fileSize returns the size of the file on disk, which unlike sizeInBytes is also known for a reloaded store
+func (store *Store) fileSize() (int64, error) {
+	info, err := store.reader.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
 }
 
 // sizeInBytes Returns the file size in bytes.
@@ -83,17 +122,21 @@ func (store *Store) sizeInBytes() int64 {
 	return store.currentWriteOffset
 }
 
-// sync Performs a file sync, ensures all the disk blocks (or pages) at the Kernel page cache are flushed to the disk
+// sync Performs a file sync, ensures all the disk blocks (or pages) at the Kernel page cache are flushed to the disk.
 func (store *Store) sync() error {
+	if store.writer == nil || store.writesStopped {
+		return nil
+	}
 	return store.writer.Sync()
 }
 
 // stopWrites Closes the write file pointer. This operation is called when the active segment has reached its size threshold.
-func (store *Store) stopWrites() {
-	store.writer.Close()
+func (store *Store) stopWrites() error {
+	store.writesStopped = true
+	return store.writer.Close()
 }
 
 // remove Removes the file
-func (store *Store) remove() {
-	_ = os.RemoveAll(store.reader.Name())
+func (store *Store) remove() error {
+	return os.RemoveAll(store.reader.Name())
 }
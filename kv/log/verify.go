@@ -0,0 +1,141 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/internal/dirlock"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+// quarantineDirectory is the subdirectory of a bitcask directory where Repair moves the segments it can not recover
+const quarantineDirectory = "quarantine"
+
+// SegmentReport is the outcome of verifying one segment file
+type SegmentReport struct {
+	*SegmentFile
+	Entries   int   // number of entries which decode cleanly
+	ValidSize int64 // size of the prefix of the file spanned by the entries which decode cleanly
+	Err       error // first problem found in the segment, nil if the segment is healthy
+}
+
+// Healthy returns true if the segment has no problem
+func (report *SegmentReport) Healthy() bool {
+	return report.Err == nil
+}
+
+// TornTail returns true if the last entry of the segment runs past the end of the file, as left behind by an interrupted write, so truncating it to ValidSize recovers it
+func (report *SegmentReport) TornTail() bool {
+	return errors.Is(report.Err, errTruncated)
+}
+
+// VerifyReport is the outcome of verifying all the segment files of a directory
+type VerifyReport struct {
+	Segments         []*SegmentReport
+	DuplicateFileIds []uint64 // fileIds claimed by more than one segment file
+}
+
+// Healthy returns true if no segment has a problem and no two segment files claim the same fileId
+func (report *VerifyReport) Healthy() bool {
+	for _, segment := range report.Segments {
+		if !segment.Healthy() {
+			return false
+		}
+	}
+	return len(report.DuplicateFileIds) == 0
+}
+
+// Verify checks that every entry of every segment file of the directory decodes cleanly, and that every file is named after a fileId of its own
+func Verify(directory string, keyProvider config.KeyProvider) (*VerifyReport, error) {
+	unlockDirectory, err := dirlock.Shared(directory)
+	if err != nil {
+		return nil, fmt.Errorf("locking directory %v: %w", directory, err)
+	}
+	defer unlockDirectory()
+	return verify(directory, keyProvider)
+}
+
+func verify(directory string, keyProvider config.KeyProvider) (*VerifyReport, error) {
+	segmentFiles, err := ListSegmentFiles(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{}
+	filesByFileId := make(map[uint64]int)
+	for _, segmentFile := range segmentFiles {
+		filesByFileId[segmentFile.FileId]++
+		if filesByFileId[segmentFile.FileId] == 2 {
+			report.DuplicateFileIds = append(report.DuplicateFileIds, segmentFile.FileId)
+		}
+		report.Segments = append(report.Segments, verifySegment(directory, segmentFile, keyProvider))
+	}
+	return report, nil
+}
+
+func verifySegment(directory string, segmentFile *SegmentFile, keyProvider config.KeyProvider) *SegmentReport {
+	report := &SegmentReport{SegmentFile: segmentFile}
+	if segmentName(segmentFile.FileId, directory) != segmentFile.Path {
+		report.Err = fmt.Errorf("%w: %v is not named after its fileId %v", errMisnamed, segmentFile.Path, segmentFile.FileId)
+		return report
+	}
+
+	content, err := os.ReadFile(segmentFile.Path)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+	records, validSize, err := decodeRecords(segmentFile.Path, content, keyProvider)
+	report.Entries = len(records)
+	report.ValidSize = int64(validSize)
+	report.Err = err
+	return report
+}
+
+// RepairReport lists the changes made by Repair
+type RepairReport struct {
+	Truncated   []*SegmentReport // segments whose torn tail was truncated
+	Quarantined []*SegmentReport // segments moved to the quarantine subdirectory
+}
+
+// Repair verifies the directory, truncates the segments with a torn tail and moves the otherwise corrupted or misnamed ones to the quarantine subdirectory
+func Repair(directory string, keyProvider config.KeyProvider) (*RepairReport, error) {
+	unlockDirectory, err := dirlock.Exclusive(directory)
+	if err != nil {
+		return nil, fmt.Errorf("locking directory %v: %w", directory, err)
+	}
+	defer unlockDirectory()
+
+	verifyReport, err := verify(directory, keyProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RepairReport{}
+	for _, segment := range verifyReport.Segments {
+		switch {
+		case segment.Healthy():
+			continue
+		case segment.TornTail():
+			if err := os.Truncate(segment.Path, segment.ValidSize); err != nil {
+				return report, err
+			}
+			report.Truncated = append(report.Truncated, segment)
+		case errors.Is(segment.Err, ErrCorrupted), errors.Is(segment.Err, errMisnamed):
+			if err := quarantine(directory, segment.Path); err != nil {
+				return report, err
+			}
+			report.Quarantined = append(report.Quarantined, segment)
+		}
+	}
+	return report, nil
+}
+
+func quarantine(directory string, filePath string) error {
+	quarantinePath := path.Join(directory, quarantineDirectory)
+	if err := os.MkdirAll(quarantinePath, 0755); err != nil {
+		return err
+	}
+	return os.Rename(filePath, path.Join(quarantinePath, path.Base(filePath)))
+}
@@ -0,0 +1,33 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package kv
+
+import (
+	"ashishkujoy/bitcask/internal/dirlock"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySharesAndRepairExcludesTheLockOnTheDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	writeSegment(t, tempDir, 1, "topic")
+
+	unlockShared, err := dirlock.Shared(tempDir)
+	require.NoError(t, err)
+	_, err = Verify(tempDir, nil)
+	require.NoError(t, err, "a reader does not exclude Verify")
+	_, err = Repair(tempDir, nil)
+	require.ErrorIs(t, err, syscall.EWOULDBLOCK, "a reader excludes Repair")
+	unlockShared()
+
+	unlockExclusive, err := dirlock.Exclusive(tempDir)
+	require.NoError(t, err)
+	_, err = Verify(tempDir, nil)
+	require.ErrorIs(t, err, syscall.EWOULDBLOCK, "a writer excludes Verify")
+	unlockExclusive()
+
+	_, err = Repair(tempDir, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,126 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/clock"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeSegment(t *testing.T, directory string, fileId uint64, keys ...string) *Segment[serializableKey] {
+	segment, err := NewSegment[serializableKey](fileId, directory)
+	require.NoError(t, err)
+	for _, key := range keys {
+		_, err := segment.append(NewEntry([]byte(key), []byte("value of "+key), clock.NewSystemClock()))
+		require.NoError(t, err)
+	}
+	require.NoError(t, segment.sync())
+	return segment
+}
+
+func TestVerifyHealthySegments(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testVerifyHealthy")
+	defer os.RemoveAll(tempDir)
+	writeSegment(t, tempDir, 1, "topic", "disk")
+	writeSegment(t, tempDir, 2, "engine")
+
+	report, err := Verify(tempDir, nil)
+	require.NoError(t, err)
+	require.True(t, report.Healthy())
+	require.Equal(t, 2, len(report.Segments))
+	require.Equal(t, 2, report.Segments[0].Entries)
+	require.Equal(t, report.Segments[0].Size, report.Segments[0].ValidSize)
+	require.Equal(t, 1, report.Segments[1].Entries)
+}
+
+func TestVerifyATornTailAndDuplicateFileIds(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testVerifyTorn")
+	defer os.RemoveAll(tempDir)
+	segment := writeSegment(t, tempDir, 1, "topic", "disk")
+	fullSize := segment.sizeInBytes()
+	os.Truncate(segment.filePath, fullSize-2)
+	writeSegment(t, tempDir, 2, "engine")
+	os.WriteFile(path.Join(tempDir, "02_bitcask.data"), []byte{}, 0644)
+
+	report, err := Verify(tempDir, nil)
+	require.NoError(t, err)
+	require.False(t, report.Healthy())
+	require.Equal(t, []uint64{2}, report.DuplicateFileIds)
+
+	torn := report.Segments[0]
+	require.True(t, torn.TornTail())
+	require.ErrorIs(t, torn.Err, ErrCorrupted)
+	require.Equal(t, 1, torn.Entries)
+}
+
+func TestRepairTruncatesTornTailsAndQuarantinesCorruptedSegments(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testRepair")
+	defer os.RemoveAll(tempDir)
+	segment := writeSegment(t, tempDir, 1, "topic", "disk")
+	fullSize := segment.sizeInBytes()
+	os.Truncate(segment.filePath, fullSize-2)
+
+	corrupted := writeSegment(t, tempDir, 2, "engine", "editor")
+	content, _ := os.ReadFile(corrupted.filePath)
+	content[8] = 0
+	os.WriteFile(corrupted.filePath, content, 0644)
+
+	report, err := Repair(tempDir, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(report.Truncated))
+	require.Equal(t, 1, len(report.Quarantined))
+	require.FileExists(t, path.Join(tempDir, quarantineDirectory, "2_bitcask.data"))
+
+	verifyReport, err := Verify(tempDir, nil)
+	require.NoError(t, err)
+	require.True(t, verifyReport.Healthy())
+	require.Equal(t, 1, len(verifyReport.Segments))
+	require.Equal(t, 1, verifyReport.Segments[0].Entries)
+}
+
+func TestRepairQuarantinesASegmentWithACorruptedSizeInTheMiddle(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testRepairCorruptedSize")
+	defer os.RemoveAll(tempDir)
+	segment := writeSegment(t, tempDir, 1, "topic", "disk", "engine")
+	content, _ := os.ReadFile(segment.filePath)
+	content[8] = 0xff
+	os.WriteFile(segment.filePath, content, 0644)
+
+	verifyReport, err := Verify(tempDir, nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, verifyReport.Segments[0].Err, ErrCorrupted)
+	require.False(t, verifyReport.Segments[0].TornTail())
+
+	report, err := Repair(tempDir, nil)
+	require.NoError(t, err)
+	require.Empty(t, report.Truncated)
+	require.Equal(t, 1, len(report.Quarantined))
+
+	quarantined, err := os.ReadFile(path.Join(tempDir, quarantineDirectory, "1_bitcask.data"))
+	require.NoError(t, err)
+	require.Equal(t, content, quarantined, "the entries after the corrupted size are kept")
+}
+
+func TestRepairTruncatesATornTailWhoseLeftoverBytesDecode(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testRepairTornTailDecoding")
+	defer os.RemoveAll(tempDir)
+	segment := writeSegment(t, tempDir, 1, "topic")
+	validSize := segment.sizeInBytes()
+	embedded, err := NewEntry([]byte("disk"), []byte("ssd"), clock.NewSystemClock()).encode(nil)
+	require.NoError(t, err)
+	_, err = segment.append(NewEntry([]byte("engine"), append([]byte("x"), embedded...), clock.NewSystemClock()))
+	require.NoError(t, err)
+	require.NoError(t, segment.sync())
+	os.Truncate(segment.filePath, segment.sizeInBytes()-1)
+
+	verifyReport, err := Verify(tempDir, nil)
+	require.NoError(t, err)
+	require.True(t, verifyReport.Segments[0].TornTail())
+
+	report, err := Repair(tempDir, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(report.Truncated))
+	require.Equal(t, validSize, report.Truncated[0].ValidSize)
+}
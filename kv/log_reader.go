@@ -0,0 +1,131 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Position identifies a record in the log by the fileId of its segment and its offset, the zero Position being the beginning of the log
+type Position struct {
+	FileId uint64
+	Offset int64
+}
+
+// LogRecord is a put, a delete or the drop of a keyspace read from the log
+type LogRecord[Key config.BitcaskKey] struct {
+	Position      Position
+	Keyspace      string // name of the keyspace of the record, "" for the default keyspace
+	Key           Key
+	Value         []byte
+	Deleted       bool
+	DropsKeyspace bool
+}
+
+// LogReader reads the records of the log in append order, skipping the records of the keyspaces dropped by the time they are read
+type LogReader[Key config.BitcaskKey] struct {
+	store    *KVStore[Key]
+	position Position
+}
+
+// LogReader returns a reader which reads the log starting at from. A position in a segment which merge has removed is reported as ErrPositionCompacted.
+func (store *KVStore[Key]) LogReader(from Position) (*LogReader[Key], error) {
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return nil, ErrClosed
+	}
+	if from == (Position{}) {
+		from.FileId, _ = store.segments.NextFileId(0)
+	} else if !store.segments.Contains(from.FileId) {
+		return nil, fmt.Errorf("%w: segment %v is not present", ErrPositionCompacted, from.FileId)
+	}
+	return &LogReader[Key]{store: store, position: from}, nil
+}
+
+// Position returns the position of the next record to be read, which can be used to resume reading later
+func (reader *LogReader[Key]) Position() Position {
+	return reader.position
+}
+
+// Next returns the next record of the log. If the reader has read every record, Next waits for a new record to be appended, or for ctx to be done.
+func (reader *LogReader[Key]) Next(ctx context.Context) (*LogRecord[Key], error) {
+	for {
+		record, appended, err := reader.tryNext()
+		if err != nil || record != nil {
+			return record, err
+		}
+		select {
+		case <-appended:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// TryNext returns the next record of the log, or nil if the reader has read every record, without waiting for a new record
+func (reader *LogReader[Key]) TryNext() (*LogRecord[Key], error) {
+	record, _, err := reader.tryNext()
+	return record, err
+}
+
+// tryNext returns the next record, or a channel which is closed once a new record is appended if the reader has read every record
+func (reader *LogReader[Key]) tryNext() (*LogRecord[Key], <-chan struct{}, error) {
+	store := reader.store
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return nil, nil, ErrClosed
+	}
+	for {
+		if reader.position.FileId == 0 {
+			// read-only segments may have no segment at all when the reader is created
+			next, ok := store.segments.NextFileId(0)
+			if !ok {
+				return nil, store.appended, nil
+			}
+			reader.position = Position{FileId: next}
+		}
+		record, err := store.segments.ReadRecordAt(reader.position.FileId, reader.position.Offset)
+		if errors.Is(err, kvlog.ErrSegmentNotFound) {
+			return nil, nil, fmt.Errorf("%w: segment %v was removed", ErrPositionCompacted, reader.position.FileId)
+		}
+		if errors.Is(err, io.EOF) {
+			next, ok := store.segments.NextFileId(reader.position.FileId)
+			if !ok {
+				return nil, store.appended, nil
+			}
+			reader.position = Position{FileId: next}
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		position := Position{FileId: reader.position.FileId, Offset: int64(record.Offset)}
+		next := Position{FileId: position.FileId, Offset: position.Offset + int64(record.Length)}
+		if record.Keyspace == kvlog.CatalogKeyspace {
+			reader.position = next
+			if record.Deleted {
+				return &LogRecord[Key]{Position: position, Keyspace: string(record.Key), DropsKeyspace: true}, nil, nil
+			}
+			continue
+		}
+		keyspaceName, ok := store.keyspaceName(record.Keyspace)
+		if !ok {
+			reader.position = next
+			continue
+		}
+		key, err := store.segments.KeyCodec().Decode(record.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader.position = next
+		return &LogRecord[Key]{Position: position, Keyspace: keyspaceName, Key: key, Value: record.Value, Deleted: record.Deleted}, nil, nil
+	}
+}
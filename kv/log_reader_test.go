@@ -0,0 +1,137 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	kv "ashishkujoy/bitcask/kv/log"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readRecords(t *testing.T, reader *LogReader[serializableKey], count int) []*LogRecord[serializableKey] {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var records []*LogRecord[serializableKey]
+	for len(records) < count {
+		record, err := reader.Next(ctx)
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogReaderReadsAcrossSegmentsInAppendOrder(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testLogReader")
+	defer os.RemoveAll(tempDir)
+	store, _ := NewKVStore(config.NewConfig(tempDir, 8, config.NewMergeConfig(2, keyMapper)))
+	defer store.Clear()
+
+	store.Put("Topic", []byte("Databases"))
+	store.Put("Disk", []byte("SSD"))
+	store.Delete("Topic")
+
+	reader, err := store.LogReader(Position{})
+	require.NoError(t, err)
+	records := readRecords(t, reader, 3)
+
+	require.Equal(t, serializableKey("Topic"), records[0].Key)
+	require.Equal(t, "Databases", string(records[0].Value))
+	require.Equal(t, serializableKey("Disk"), records[1].Key)
+	require.Equal(t, serializableKey("Topic"), records[2].Key)
+	require.True(t, records[2].Deleted)
+
+	resumed, err := store.LogReader(records[1].Position)
+	require.NoError(t, err)
+	require.Equal(t, records[1:], readRecords(t, resumed, 2))
+}
+
+func TestLogReaderReadsTheRecordsOfTheKeyspaces(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testLogReaderKeyspaces")
+	defer os.RemoveAll(tempDir)
+	store, _ := NewKVStore(config.NewConfig(tempDir, 1024, config.NewMergeConfig(2, keyMapper)))
+	defer store.Clear()
+
+	store.Keyspace("users").Put("Topic", []byte("Alice"))
+	store.Keyspace("orders").Put("Topic", []byte("Book"))
+	store.Put("Topic", []byte("Databases"))
+	store.Keyspace("orders").Drop()
+	store.Keyspace("users").Delete("Topic")
+
+	reader, err := store.LogReader(Position{})
+	require.NoError(t, err)
+	records := readRecords(t, reader, 4)
+
+	require.Equal(t, "users", records[0].Keyspace)
+	require.Equal(t, serializableKey("Topic"), records[0].Key)
+	require.Equal(t, "Alice", string(records[0].Value))
+	require.Equal(t, "", records[1].Keyspace)
+	require.Equal(t, "Databases", string(records[1].Value))
+	require.Equal(t, "orders", records[2].Keyspace)
+	require.True(t, records[2].DropsKeyspace)
+	require.Equal(t, "users", records[3].Keyspace)
+	require.True(t, records[3].Deleted)
+}
+
+func TestLogReaderWaitsForNewRecords(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testLogReaderWaits")
+	defer os.RemoveAll(tempDir)
+	store, _ := NewKVStore(config.NewConfig(tempDir, 1024, config.NewMergeConfig(2, keyMapper)))
+	defer store.Clear()
+
+	store.Put("Topic", []byte("Databases"))
+	reader, _ := store.LogReader(Position{})
+	readRecords(t, reader, 1)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		store.Put("Disk", []byte("SSD"))
+	}()
+	records := readRecords(t, reader, 1)
+	require.Equal(t, serializableKey("Disk"), records[0].Key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := reader.Next(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLogReaderAtACompactedPosition(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testLogReaderCompacted")
+	defer os.RemoveAll(tempDir)
+	store, _ := NewKVStore(config.NewConfig(tempDir, 8, config.NewMergeConfig(2, keyMapper)))
+	defer store.Clear()
+
+	store.Put("Topic", []byte("Databases"))
+	store.Put("Disk", []byte("SSD"))
+	store.Put("Topic", []byte("Microservices"))
+	store.Put("Engine", []byte("Bitcask"))
+
+	reader, _ := store.LogReader(Position{})
+	first := readRecords(t, reader, 1)[0]
+
+	fileIds, entries, _ := store.ReadAllInactiveSegments()
+	changes := make(map[serializableKey]*kv.MappedStoredEntry[serializableKey])
+	for _, segmentEntries := range entries {
+		for _, entry := range segmentEntries {
+			changes[entry.Key] = entry
+		}
+	}
+	require.NoError(t, store.WriteBack(fileIds, changes))
+
+	_, err := reader.Next(context.Background())
+	require.ErrorIs(t, err, ErrPositionCompacted)
+	_, err = store.LogReader(first.Position)
+	require.ErrorIs(t, err, ErrPositionCompacted)
+
+	fromStart, err := store.LogReader(Position{})
+	require.NoError(t, err)
+	keys := map[serializableKey]string{}
+	for _, record := range readRecords(t, fromStart, 3) {
+		keys[record.Key] = string(record.Value)
+	}
+	require.Equal(t, map[serializableKey]string{"Topic": "Microservices", "Disk": "SSD", "Engine": "Bitcask"}, keys)
+}
@@ -0,0 +1,75 @@
+package kv
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the buckets of the latency histograms
+var latencyBuckets = []time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// Histogram is a snapshot of the latencies of an operation, with cumulative bucket counts like a Prometheus histogram
+type Histogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	Count   uint64            `json:"count"`
+	Sum     time.Duration     `json:"sum"`
+}
+
+// HistogramBucket counts the latencies lower than or equal to UpperBound
+type HistogramBucket struct {
+	UpperBound time.Duration `json:"upperBound"`
+	Count      uint64        `json:"count"`
+}
+
+// OperationStats counts the reads and writes served by the store, along with their latencies, waiting for the lock included
+type OperationStats struct {
+	ReadLatency  Histogram `json:"readLatency"`
+	WriteLatency Histogram `json:"writeLatency"`
+}
+
+// latencyHistogram records latencies without locking, so that recording never slows the operations down
+type latencyHistogram struct {
+	counts []atomic.Uint64 // the last count is for the latencies above the last bucket
+	sum    atomic.Int64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]atomic.Uint64, len(latencyBuckets)+1)}
+}
+
+// since records the latency of an operation which started at start
+func (histogram *latencyHistogram) since(start time.Time) {
+	latency := time.Since(start)
+	index := len(latencyBuckets)
+	for bucket, upperBound := range latencyBuckets {
+		if latency <= upperBound {
+			index = bucket
+			break
+		}
+	}
+	histogram.counts[index].Add(1)
+	histogram.sum.Add(int64(latency))
+}
+
+func (histogram *latencyHistogram) snapshot() Histogram {
+	snapshot := Histogram{Buckets: make([]HistogramBucket, len(latencyBuckets)), Sum: time.Duration(histogram.sum.Load())}
+	for index := range histogram.counts {
+		snapshot.Count += histogram.counts[index].Load()
+		if index < len(latencyBuckets) {
+			snapshot.Buckets[index] = HistogramBucket{UpperBound: latencyBuckets[index], Count: snapshot.Count}
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,122 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	"maps"
+	"slices"
+)
+
+// Snapshot is a point-in-time view of the KVStore, pinning its segments until it is released
+type Snapshot[Key config.BitcaskKey] struct {
+	store         *KVStore[Key]
+	keyDirectory  *KeyDirectory[Key]
+	keyspaces     map[string]*KeyDirectory[Key]
+	pinnedFileIds []uint64
+}
+
+// Snapshot takes a snapshot of the KeyDirectory of every keyspace and pins all the segments
+func (store *KVStore[Key]) Snapshot() (*Snapshot[Key], error) {
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return nil, ErrClosed
+	}
+	keyspaces := make(map[string]*KeyDirectory[Key], len(store.keyspaces))
+	for name, keyspace := range store.keyspaces {
+		keyspaces[name] = keyspace.keyDirectory.Snapshot()
+	}
+	return &Snapshot[Key]{
+		store:         store,
+		keyDirectory:  store.keyDirectory.Snapshot(),
+		keyspaces:     keyspaces,
+		pinnedFileIds: store.segments.PinAll(),
+	}, nil
+}
+
+// Len returns the number of live keys of the default keyspace in the snapshot
+func (snapshot *Snapshot[Key]) Len() int {
+	return snapshot.keyDirectory.Len()
+}
+
+// Keyspaces returns the names of the keyspaces in the snapshot, other than the default keyspace, in order
+func (snapshot *Snapshot[Key]) Keyspaces() []string {
+	return slices.Sorted(maps.Keys(snapshot.keyspaces))
+}
+
+// KeyspaceLen returns the number of live keys of the keyspace named keyspaceName in the snapshot
+func (snapshot *Snapshot[Key]) KeyspaceLen(keyspaceName string) int {
+	if keyDirectory := snapshot.keyDirectoryOf(keyspaceName); keyDirectory != nil {
+		return keyDirectory.Len()
+	}
+	return 0
+}
+
+// Get gets the value the key had when the snapshot was taken. Returns value, true and nil if the key was present, and nil, false and nil otherwise.
+func (snapshot *Snapshot[Key]) Get(key Key) ([]byte, bool, error) {
+	entry, ok := snapshot.keyDirectory.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := snapshot.read(entry)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Scan is KVStore.Scan over the keys of the snapshot
+func (snapshot *Snapshot[Key]) Scan(prefix []byte, fn func(key Key, value []byte) bool) error {
+	return snapshot.ScanKeyspace(defaultKeyspaceName, prefix, fn)
+}
+
+// ScanKeyspace is Scan over the keys of the keyspace named keyspaceName
+func (snapshot *Snapshot[Key]) ScanKeyspace(keyspaceName string, prefix []byte, fn func(key Key, value []byte) bool) error {
+	keyDirectory := snapshot.keyDirectoryOf(keyspaceName)
+	if keyDirectory == nil {
+		return nil
+	}
+	var err error
+	keyDirectory.WalkPrefix(prefix, func(encodedKey []byte, entry *Entry) bool {
+		key, decodeErr := snapshot.store.segments.KeyCodec().Decode(encodedKey)
+		if decodeErr != nil {
+			err = decodeErr
+			return false
+		}
+		value, readErr := snapshot.read(entry)
+		if readErr != nil {
+			err = readErr
+			return false
+		}
+		return fn(key, value)
+	})
+	return err
+}
+
+// Release releases the pins held on the segments. The snapshot must not be used after it is released.
+func (snapshot *Snapshot[Key]) Release() {
+	snapshot.store.UnpinSegments(snapshot.pinnedFileIds)
+	snapshot.pinnedFileIds = nil
+}
+
+func (snapshot *Snapshot[Key]) keyDirectoryOf(keyspaceName string) *KeyDirectory[Key] {
+	if keyspaceName == defaultKeyspaceName {
+		return snapshot.keyDirectory
+	}
+	return snapshot.keyspaces[keyspaceName]
+}
+
+func (snapshot *Snapshot[Key]) read(entry *Entry) ([]byte, error) {
+	store := snapshot.store
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return nil, ErrClosed
+	}
+	storedEntry, err := store.segments.Read(entry.FileId, entry.Offset, entry.EntryLength)
+	if err != nil {
+		return nil, err
+	}
+	return storedEntry.Value, nil
+}
@@ -0,0 +1,143 @@
+package kv
+
+import (
+	"ashishkujoy/bitcask/config"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"fmt"
+	"time"
+)
+
+// Txn is an optimistic transaction over the default keyspace, reading from a snapshot and buffering its writes until it commits. It is not safe for concurrent use.
+type Txn[Key config.BitcaskKey] struct {
+	snapshot *Snapshot[Key]
+	reads    map[string]*Entry // the entry of every key read from the snapshot, nil for a missing key, by encoded key
+	writes   map[string]txnWrite[Key]
+	order    []string // encoded keys of the writes, in the order they were first written
+	done     bool
+}
+
+// txnWrite is a buffered write, a delete has a nil value
+type txnWrite[Key config.BitcaskKey] struct {
+	key     Key
+	value   []byte
+	deleted bool
+}
+
+// Begin begins a transaction. It must end with Commit or Discard, which release the segments pinned by its snapshot.
+func (store *KVStore[Key]) Begin() (*Txn[Key], error) {
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &Txn[Key]{
+		snapshot: snapshot,
+		reads:    map[string]*Entry{},
+		writes:   map[string]txnWrite[Key]{},
+	}, nil
+}
+
+// Get gets the value of the key written by the transaction, or else the value the key had when the transaction began
+func (txn *Txn[Key]) Get(key Key) ([]byte, error) {
+	if txn.done {
+		return nil, ErrTxnDone
+	}
+	encodedKey := string(txn.snapshot.store.segments.KeyCodec().Encode(key))
+	if write, ok := txn.writes[encodedKey]; ok {
+		if write.deleted {
+			return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, key)
+		}
+		return write.value, nil
+	}
+
+	entry, _ := txn.snapshot.keyDirectory.GetEncoded([]byte(encodedKey))
+	txn.reads[encodedKey] = entry
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, key)
+	}
+	return txn.snapshot.read(entry)
+}
+
+// Put buffers the key value pair, to be put when the transaction commits
+func (txn *Txn[Key]) Put(key Key, value []byte) error {
+	return txn.write(key, txnWrite[Key]{key: key, value: value})
+}
+
+// Delete buffers the delete of the key, to be deleted when the transaction commits
+func (txn *Txn[Key]) Delete(key Key) error {
+	return txn.write(key, txnWrite[Key]{key: key, deleted: true})
+}
+
+func (txn *Txn[Key]) write(key Key, write txnWrite[Key]) error {
+	if txn.done {
+		return ErrTxnDone
+	}
+	encodedKey := string(txn.snapshot.store.segments.KeyCodec().Encode(key))
+	if _, ok := txn.writes[encodedKey]; !ok {
+		txn.order = append(txn.order, encodedKey)
+	}
+	txn.writes[encodedKey] = write
+	return nil
+}
+
+// Commit appends the writes as one batch, or returns ErrConflict, applying nothing, if a key read was written since the transaction began
+func (txn *Txn[Key]) Commit() error {
+	if txn.done {
+		return ErrTxnDone
+	}
+	defer txn.Discard()
+	if len(txn.writes) == 0 {
+		return nil
+	}
+
+	store := txn.snapshot.store
+	defer store.writeLatency.since(time.Now())
+	store.rwlock.Lock()
+	defer store.rwlock.Unlock()
+
+	if store.closed {
+		return ErrClosed
+	}
+	for encodedKey, readEntry := range txn.reads {
+		currentEntry, _ := store.keyDirectory.GetEncoded([]byte(encodedKey))
+		if store.changed(readEntry, currentEntry) {
+			return ErrConflict
+		}
+	}
+
+	batch := make([]kvlog.BatchEntry[Key], 0, len(txn.order))
+	for _, encodedKey := range txn.order {
+		write := txn.writes[encodedKey]
+		batch = append(batch, kvlog.BatchEntry[Key]{Key: write.key, Value: write.value, Deleted: write.deleted})
+	}
+	appendResponses, err := store.segments.AppendBatch(batch)
+	if err != nil {
+		return err
+	}
+
+	defer store.signalAppended()
+	for index, write := range batch {
+		if write.Deleted {
+			store.applyDelete(write.Key)
+		} else {
+			store.applyPut(write.Key, write.Value, appendResponses[index])
+		}
+	}
+	return nil
+}
+
+// Discard ends the transaction without applying its writes. Discarding a committed or discarded transaction does nothing.
+func (txn *Txn[Key]) Discard() {
+	if txn.done {
+		return
+	}
+	txn.done = true
+	txn.snapshot.Release()
+}
+
+// changed returns true if the key was written between reading readEntry and currentEntry, nil standing for a missing key
+func (store *KVStore[Key]) changed(readEntry *Entry, currentEntry *Entry) bool {
+	if readEntry == nil || currentEntry == nil {
+		return readEntry != currentEntry
+	}
+	return store.versionOf(readEntry) != store.versionOf(currentEntry)
+}
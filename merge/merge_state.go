@@ -3,6 +3,8 @@ package merge
 import (
 	"ashishkujoy/bitcask/config"
 	log "ashishkujoy/bitcask/kv/log"
+	"maps"
+	"slices"
 )
 
 // MergedState encapsulates key and its entry from inactive segment files
@@ -29,14 +31,16 @@ func (mergedState *MergedState[Key]) merge(entries []*log.MappedStoredEntry[Key]
 func (mergedState *MergedState[Key]) takeAll(mappedEntries []*log.MappedStoredEntry[Key]) {
 	for _, entry := range mappedEntries {
 		if entry.Deleted {
+			delete(mergedState.valueByKey, entry.Key)
 			mergedState.deletedKeys[entry.Key] = entry
 		} else {
+			delete(mergedState.deletedKeys, entry.Key)
 			mergedState.valueByKey[entry.Key] = entry
 		}
 	}
 }
 
-// mergeWith performs a merge operation with the new set of entries based on timestamp. The value of key with the latest timestamp is retained
+// mergeWith performs a merge operation with the new set of entries, from a later segment. The value of key from the later segment is retained, as timestamps wrap
 // Tests server as a better documentation for this method
 func (mergedState *MergedState[Key]) mergeWith(mappedEntries []*log.MappedStoredEntry[Key]) {
 	for _, newEntry := range mappedEntries {
@@ -56,11 +60,51 @@ func (mergedState *MergedState[Key]) mergeWith(mappedEntries []*log.MappedStored
 }
 
 func (mergedState *MergedState[Key]) mayBeUpdate(existingEntry, newEntry *log.MappedStoredEntry[Key]) {
-	if newEntry.Timestamp > existingEntry.Timestamp {
-		if newEntry.Deleted {
-			delete(mergedState.valueByKey, existingEntry.Key)
-		} else {
-			mergedState.valueByKey[newEntry.Key] = newEntry
+	if newEntry.Deleted {
+		delete(mergedState.valueByKey, existingEntry.Key)
+	} else {
+		mergedState.valueByKey[newEntry.Key] = newEntry
+	}
+}
+
+// KeyspacesMergedState merges the entries of every keyspace in a MergedState of its own
+type KeyspacesMergedState[Key config.BitcaskKey] struct {
+	stateByKeyspace map[uint32]*MergedState[Key]
+	catalog         []*log.MappedStoredEntry[Key] // entries naming the keyspaces, which are kept as they are, since the store only writes back the ones naming the existing keyspaces
+}
+
+// NewKeyspacesMergedState creates a new instance of KeyspacesMergedState
+func NewKeyspacesMergedState[Key config.BitcaskKey]() *KeyspacesMergedState[Key] {
+	return &KeyspacesMergedState[Key]{stateByKeyspace: make(map[uint32]*MergedState[Key])}
+}
+
+// add merges the entries of a segment, read after the segments added so far, into the state of their keyspace
+func (keyspacesState *KeyspacesMergedState[Key]) add(entries []*log.MappedStoredEntry[Key]) {
+	entriesByKeyspace := make(map[uint32][]*log.MappedStoredEntry[Key])
+	for _, entry := range entries {
+		if entry.Keyspace == log.CatalogKeyspace {
+			keyspacesState.catalog = append(keyspacesState.catalog, entry)
+			continue
+		}
+		entriesByKeyspace[entry.Keyspace] = append(entriesByKeyspace[entry.Keyspace], entry)
+	}
+	for keyspace, keyspaceEntries := range entriesByKeyspace {
+		mergedState, ok := keyspacesState.stateByKeyspace[keyspace]
+		if !ok {
+			mergedState = NewMergedState[Key]()
+			keyspacesState.stateByKeyspace[keyspace] = mergedState
+			mergedState.takeAll(keyspaceEntries)
+			continue
 		}
+		mergedState.mergeWith(keyspaceEntries)
+	}
+}
+
+// changes returns the entries of the catalog, followed by the merged entries of all the keyspaces
+func (keyspacesState *KeyspacesMergedState[Key]) changes() []*log.MappedStoredEntry[Key] {
+	changes := slices.Clone(keyspacesState.catalog)
+	for _, mergedState := range keyspacesState.stateByKeyspace {
+		changes = slices.AppendSeq(changes, maps.Values(mergedState.valueByKey))
 	}
+	return changes
 }
@@ -85,7 +85,7 @@ func TestMergeWithDeletionInTheFirstSet(t *testing.T) {
 	require.False(t, ok)
 }
 
-func TestMergeWithDeletionInTheFirstSetHavingHighTimestamp(t *testing.T) {
+func TestMergeWithDeletionInTheFirstSetHavingHighTimestampKeepsTheLaterEntry(t *testing.T) {
 	mergedState := NewMergedState[serializableKey]()
 	entry := &log.MappedStoredEntry[serializableKey]{
 		Key:       "topic",
@@ -106,7 +106,7 @@ func TestMergeWithDeletionInTheFirstSetHavingHighTimestamp(t *testing.T) {
 	)
 
 	_, ok := mergedState.valueByKey["topic"]
-	require.False(t, ok)
+	require.True(t, ok, "the timestamp wraps, so the entry of the later set wins")
 }
 func TestMergeWithDeletionWithoutSameEntry(t *testing.T) {
 	mergedState := NewMergedState[serializableKey]()
@@ -155,7 +155,7 @@ func TestMergeWithUpdate(t *testing.T) {
 	require.Equal(t, string("bitcask"), string(topicEntry.Value))
 }
 
-func TestMergeWithUpdateInTheFirstSetHavingHighTimestamp(t *testing.T) {
+func TestMergeWithUpdateInTheFirstSetHavingHighTimestampKeepsTheLaterEntry(t *testing.T) {
 	mergedState := NewMergedState[serializableKey]()
 	entry := &log.MappedStoredEntry[serializableKey]{
 		Key:       "topic",
@@ -172,5 +172,43 @@ func TestMergeWithUpdateInTheFirstSetHavingHighTimestamp(t *testing.T) {
 	mergedState.merge([]*log.MappedStoredEntry[serializableKey]{entry}, []*log.MappedStoredEntry[serializableKey]{otherEntry})
 
 	topicEntry := mergedState.valueByKey["topic"]
-	require.Equal(t, string("microservices"), string(topicEntry.Value))
+	require.Equal(t, string("bitcask"), string(topicEntry.Value))
+}
+
+func TestMergeKeepsTheLaterEntryOfAKeyWithinASet(t *testing.T) {
+	mergedState := NewMergedState[serializableKey]()
+	mergedState.merge(
+		[]*log.MappedStoredEntry[serializableKey]{
+			{Key: "topic", Value: []byte("microservices"), Timestamp: 10},
+			{Key: "topic", Deleted: true, Timestamp: 2},
+			{Key: "disk", Deleted: true, Timestamp: 10},
+			{Key: "disk", Value: []byte("ssd"), Timestamp: 2},
+		},
+		nil,
+	)
+
+	_, ok := mergedState.valueByKey["topic"]
+	require.False(t, ok)
+	require.Equal(t, "ssd", string(mergedState.valueByKey["disk"].Value))
+	_, ok = mergedState.deletedKeys["disk"]
+	require.False(t, ok)
+}
+
+func TestMergeTheSameKeyOfDifferentKeyspacesApart(t *testing.T) {
+	mergedState := NewKeyspacesMergedState[serializableKey]()
+	catalogEntry := &log.MappedStoredEntry[serializableKey]{Keyspace: log.CatalogKeyspace, EncodedKey: []byte("users"), Value: []byte{1}}
+	mergedState.add([]*log.MappedStoredEntry[serializableKey]{
+		catalogEntry,
+		{Key: "topic", Value: []byte("Database Systems"), Timestamp: 1},
+		{Keyspace: 1, Key: "topic", Value: []byte("Microservices"), Timestamp: 1},
+	})
+	mergedState.add([]*log.MappedStoredEntry[serializableKey]{
+		{Keyspace: 1, Key: "topic", Value: []byte("Storage engines"), Timestamp: 2},
+	})
+
+	changes := mergedState.changes()
+	require.Len(t, changes, 3)
+	require.Same(t, catalogEntry, changes[0], "the entries of the catalog come first")
+	require.Equal(t, "Database Systems", string(mergedState.stateByKeyspace[log.DefaultKeyspace].valueByKey["topic"].Value))
+	require.Equal(t, "Storage engines", string(mergedState.stateByKeyspace[1].valueByKey["topic"].Value))
 }
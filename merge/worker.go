@@ -4,22 +4,62 @@ import (
 	"ashishkujoy/bitcask/config"
 	"ashishkujoy/bitcask/kv"
 	log "ashishkujoy/bitcask/kv/log"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
 	"time"
 )
 
 // Worker encapsulates KVStore and MergeConfig. Worker is an abstraction inside merge package that performs merge of inactive segment files every fixed duration
 type Worker[Key config.BitcaskKey] struct {
-	kvStore *kv.KVStore[Key]
-	config  *config.MergeConfig[Key]
-	quit    chan struct{}
+	kvStore  *kv.KVStore[Key]
+	config   *config.MergeConfig[Key]
+	quit     chan struct{}
+	running  chan struct{} // holds a token while a merge runs, so that merges run one at a time and waiting for the running one can be cancelled
+	logger   *slog.Logger
+	onError  func(error)
+	listener config.Listener
+
+	runs          atomic.Uint64
+	failures      atomic.Uint64
+	totalDuration atomic.Int64
+	lastDuration  atomic.Int64
+}
+
+// Stats counts the merges run by a Worker which wrote segments back, and the ones which failed
+type Stats struct {
+	Runs          uint64
+	Failures      uint64
+	TotalDuration time.Duration
+	LastDuration  time.Duration
 }
 
 // NewWorker creates an instance of Worker and starts the Worker
-func NewWorker[Key config.BitcaskKey](kvStore *kv.KVStore[Key], config *config.MergeConfig[Key]) *Worker[Key] {
+func NewWorker[Key config.BitcaskKey](kvStore *kv.KVStore[Key], mergeConfig *config.MergeConfig[Key]) *Worker[Key] {
+	return newWorker(kvStore, mergeConfig, config.DiscardLogger, nil, config.NoopListener{})
+}
+
+// NewWorkerFromConfig creates an instance of Worker with the MergeConfig, logger, listener and OnError callback of config
+func NewWorkerFromConfig[Key config.BitcaskKey](kvStore *kv.KVStore[Key], config *config.Config[Key]) *Worker[Key] {
+	return newWorker(kvStore, config.MergeConfig(), config.Logger(), config.OnError(), config.Listener())
+}
+
+func newWorker[Key config.BitcaskKey](
+	kvStore *kv.KVStore[Key],
+	config *config.MergeConfig[Key],
+	logger *slog.Logger,
+	onError func(error),
+	listener config.Listener,
+) *Worker[Key] {
 	worker := &Worker[Key]{
-		kvStore: kvStore,
-		config:  config,
-		quit:    make(chan struct{}),
+		kvStore:  kvStore,
+		config:   config,
+		quit:     make(chan struct{}),
+		running:  make(chan struct{}, 1),
+		logger:   logger,
+		onError:  onError,
+		listener: listener,
 	}
 	worker.start()
 	return worker
@@ -41,30 +81,89 @@ func (worker *Worker[Key]) start() {
 	}()
 }
 
+// beginMerge runs a scheduled merge. Nobody waits for its result, so its error is passed to the onError callback.
 func (worker *Worker[Key]) beginMerge() {
+	if err := worker.Merge(); err != nil && worker.onError != nil {
+		worker.onError(fmt.Errorf("merge: %w", err))
+	}
+}
+
+// Merge reads the inactive segments, merges their entries keeping the latest value of every key, and writes the merged entries back to new segments
+func (worker *Worker[Key]) Merge() error {
+	return worker.MergeContext(context.Background())
+}
+
+// MergeContext is Merge giving up with ctx.Err() if ctx is done before the merged entries start being written back
+func (worker *Worker[Key]) MergeContext(ctx context.Context) error {
+	select {
+	case worker.running <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-worker.running }()
+
+	start := time.Now()
+	worker.logger.Info("merge started")
+	worker.listener.OnMergeStarted(config.MergeStarted{})
+	mergedSegments, err := worker.merge(ctx)
+	duration := time.Since(start)
+	worker.listener.OnMergeFinished(config.MergeFinished{MergedSegments: mergedSegments, Duration: duration, Err: err})
+	if err != nil {
+		worker.failures.Add(1)
+		worker.logger.Error("merge failed", "error", err, "duration", duration)
+		return err
+	}
+	if mergedSegments > 0 {
+		worker.runs.Add(1)
+		worker.totalDuration.Add(int64(duration))
+		worker.lastDuration.Store(int64(duration))
+	}
+	worker.logger.Info("merge finished", "mergedSegments", mergedSegments, "duration", duration)
+	return nil
+}
+
+// Stats returns the number of merges run and failed, and how long they took
+func (worker *Worker[Key]) Stats() Stats {
+	return Stats{
+		Runs:          worker.runs.Load(),
+		Failures:      worker.failures.Load(),
+		TotalDuration: time.Duration(worker.totalDuration.Load()),
+		LastDuration:  time.Duration(worker.lastDuration.Load()),
+	}
+}
+
+// merge runs a merge, and returns the number of inactive segments merged, which is 0 if there were too few of them to merge
+func (worker *Worker[Key]) merge(ctx context.Context) (int, error) {
 	var fileIds []uint64
 	var entries [][]*log.MappedStoredEntry[Key]
 	var err error
 
 	if worker.config.ShouldReadAllSegments() {
-		fileIds, entries, err = worker.kvStore.ReadAllInactiveSegments(worker.config.KeyMapper())
+		fileIds, entries, err = worker.kvStore.ReadAllInactiveSegmentsContext(ctx)
 	} else {
-		fileIds, entries, err = worker.kvStore.ReadInactiveSegments(
-			worker.config.TotalSegmentsToRead(),
-			worker.config.KeyMapper(),
-		)
+		fileIds, entries, err = worker.kvStore.ReadInactiveSegmentsContext(ctx, worker.config.TotalSegmentsToRead())
 	}
 
-	if err == nil && len(entries) > 2 {
-		mergedState := NewMergedState[Key]()
-		mergedState.takeAll(entries[0])
+	if err != nil {
+		return 0, fmt.Errorf("reading the inactive segments: %w", err)
+	}
+	if len(entries) > 2 {
+		mergedState := NewKeyspacesMergedState[Key]()
+		mergedState.add(entries[0])
 
 		for index := 1; index < len(entries); index++ {
-			mergedState.mergeWith(entries[index])
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			mergedState.add(entries[index])
 		}
 
-		worker.kvStore.WriteBack(fileIds, mergedState.valueByKey)
+		if err := worker.kvStore.WriteBackEntriesContext(ctx, fileIds, mergedState.changes()); err != nil {
+			return 0, fmt.Errorf("writing back the merged segments: %w", err)
+		}
+		return len(entries), nil
 	}
+	return 0, nil
 }
 
 // Stop closes the quit channel which is used to signal the merge goroutine to stop
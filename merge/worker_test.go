@@ -3,6 +3,9 @@ package merge
 import (
 	"ashishkujoy/bitcask/config"
 	"ashishkujoy/bitcask/kv"
+	"bytes"
+	"context"
+	"log/slog"
 	"testing"
 	"time"
 
@@ -88,3 +91,107 @@ func TestMergeSegmentsOnSchedule(t *testing.T) {
 	value, _ := store.Get("topic")
 	require.Equal(t, string(value), "bitcask")
 }
+
+func TestMergeContextGivesUpWaitingForARunningMerge(t *testing.T) {
+	config := config.NewConfig(".", 8, config.NewMergeConfig(2, keyMapper))
+	store, _ := kv.NewKVStore(config)
+	defer store.Clear()
+
+	worker := NewWorker(store, config.MergeConfig())
+	defer worker.Stop()
+
+	worker.running <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, worker.MergeContext(ctx), context.DeadlineExceeded)
+	<-worker.running
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = store.Put("topic", []byte("microservices"))
+	_ = store.Put("topic", []byte("bitcask"))
+	_ = store.Put("disk", []byte("ssd"))
+	require.ErrorIs(t, worker.MergeContext(cancelled), context.Canceled)
+	require.NoError(t, worker.MergeContext(context.Background()))
+}
+
+// mergeListener records the merge events
+type mergeListener struct {
+	config.NoopListener
+	started  int
+	finished []config.MergeFinished
+}
+
+func (listener *mergeListener) OnMergeStarted(config.MergeStarted) { listener.started++ }
+
+func (listener *mergeListener) OnMergeFinished(event config.MergeFinished) {
+	listener.finished = append(listener.finished, event)
+}
+
+func TestMergesAreLoggedAndScheduledFailuresReported(t *testing.T) {
+	var logs bytes.Buffer
+	var reported []error
+	listener := &mergeListener{}
+	storeConfig, err := config.New[serializableKey](
+		t.TempDir(),
+		config.WithMaxSegmentSize(8),
+		config.WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}),
+		config.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		config.WithOnError(func(err error) { reported = append(reported, err) }),
+		config.WithListener(listener),
+	)
+	require.NoError(t, err)
+	store, _ := kv.NewKVStore(storeConfig)
+
+	worker := NewWorkerFromConfig(store, storeConfig)
+	defer worker.Stop()
+
+	_ = store.Put("topic", []byte("microservices"))
+	_ = store.Put("topic", []byte("bitcask"))
+	_ = store.Put("disk", []byte("ssd"))
+	_ = store.Put("engine", []byte("bitcask"))
+	worker.beginMerge()
+	require.Empty(t, reported)
+	require.Contains(t, logs.String(), "msg=\"merge started\"")
+	require.Contains(t, logs.String(), "msg=\"merge finished\" mergedSegments=3")
+	require.Equal(t, 1, listener.started)
+	require.Equal(t, 3, listener.finished[0].MergedSegments)
+	require.NoError(t, listener.finished[0].Err)
+
+	store.Shutdown()
+	worker.beginMerge()
+	require.Len(t, reported, 1)
+	require.ErrorIs(t, reported[0], kv.ErrClosed)
+	require.Contains(t, logs.String(), "level=ERROR msg=\"merge failed\"")
+	require.Equal(t, 2, listener.started)
+	require.ErrorIs(t, listener.finished[1].Err, kv.ErrClosed)
+}
+
+// wrappingClock moves forward by a little less than 2^32 nanoseconds on every call, so that the 32 bit timestamp of every entry is lower than the one before it
+type wrappingClock struct {
+	now int64
+}
+
+func (clock *wrappingClock) Now() int64 {
+	clock.now += 1<<32 - 1
+	return clock.now
+}
+
+func TestMergeSegmentsKeepsTheLaterValueWhenTheTimestampWraps(t *testing.T) {
+	config := config.NewConfigWithClock(".", 8, config.NewMergeConfigWithAllSegmentsToRead(keyMapper), &wrappingClock{now: 1 << 40})
+	store, _ := kv.NewKVStore(config)
+	defer store.Clear()
+
+	worker := NewWorker(store, config.MergeConfig())
+
+	_ = store.Put("topic", []byte("microservices"))
+	_ = store.Put("topic", []byte("bitcask"))
+	_ = store.Put("disk", []byte("ssd"))
+	_ = store.Put("engine", []byte("bitcask"))
+
+	require.NoError(t, worker.Merge())
+	require.Equal(t, uint64(1), worker.Stats().Runs)
+	value, err := store.Get("topic")
+	require.NoError(t, err)
+	require.Equal(t, "bitcask", string(value))
+}
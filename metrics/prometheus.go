@@ -0,0 +1,92 @@
+// Package metrics exposes the statistics of a DB in the Prometheus text exposition format, without depending on a Prometheus client library.
+package metrics
+
+import (
+	"ashishkujoy/bitcask"
+	"bufio"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText writes stats as Prometheus metrics, in the text exposition format
+func WriteText(writer io.Writer, stats bitcask.Stats) error {
+	buffered := bufio.NewWriter(writer)
+	text := &textWriter{writer: buffered}
+
+	text.metric("bitcask_keys", "gauge", "Number of live keys.", float64(stats.Keys))
+	text.metric("bitcask_segments", "gauge", "Number of segments, the active one included.", float64(stats.Segments))
+	text.metric("bitcask_segment_bytes", "gauge", "Bytes of all the segments.", float64(stats.TotalBytes))
+	text.metric("bitcask_live_bytes", "gauge", "Bytes of the entries the keys point to.", float64(stats.LiveBytes))
+	text.metric("bitcask_active_segment_bytes", "gauge", "Bytes of the active segment.", float64(stats.ActiveSegmentBytes))
+	text.metric("bitcask_merge_runs_total", "counter", "Merges which wrote segments back.", float64(stats.MergeRuns))
+	text.metric("bitcask_merge_failures_total", "counter", "Merges which returned an error.", float64(stats.MergeFailures))
+	text.metric("bitcask_merge_duration_seconds_total", "counter", "Total duration of the merge runs.", stats.MergeDuration.Seconds())
+	text.metric("bitcask_last_merge_duration_seconds", "gauge", "Duration of the last merge run.", stats.LastMergeDuration.Seconds())
+	text.metric("bitcask_merge_reclaimed_bytes", "gauge", "Bytes of the merged segments minus the bytes written back in their place.", float64(stats.BytesReclaimed))
+	text.histogram("bitcask_read_duration_seconds", "Latency of the reads.", stats.ReadLatency)
+	text.histogram("bitcask_write_duration_seconds", "Latency of the writes.", stats.WriteLatency)
+
+	if text.err != nil {
+		return text.err
+	}
+	return buffered.Flush()
+}
+
+// Handler serves the statistics returned by statsOf, usually DB.Stats, in the text exposition format, for a Prometheus server to scrape
+func Handler(statsOf func() (bitcask.Stats, error)) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		stats, err := statsOf()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", ContentType)
+		_ = WriteText(writer, stats)
+	})
+}
+
+// textWriter writes metrics, remembering the first write error
+type textWriter struct {
+	writer io.Writer
+	err    error
+}
+
+func (text *textWriter) line(parts ...string) {
+	for _, part := range parts {
+		if text.err != nil {
+			return
+		}
+		_, text.err = io.WriteString(text.writer, part)
+	}
+	if text.err == nil {
+		_, text.err = io.WriteString(text.writer, "\n")
+	}
+}
+
+func (text *textWriter) header(name string, kind string, help string) {
+	text.line("# HELP ", name, " ", help)
+	text.line("# TYPE ", name, " ", kind)
+}
+
+func (text *textWriter) metric(name string, kind string, help string, value float64) {
+	text.header(name, kind, help)
+	text.line(name, " ", formatFloat(value))
+}
+
+func (text *textWriter) histogram(name string, help string, histogram bitcask.Histogram) {
+	text.header(name, "histogram", help)
+	for _, bucket := range histogram.Buckets {
+		text.line(name, `_bucket{le="`, formatFloat(bucket.UpperBound.Seconds()), `"} `, strconv.FormatUint(bucket.Count, 10))
+	}
+	text.line(name, `_bucket{le="+Inf"} `, strconv.FormatUint(histogram.Count, 10))
+	text.line(name, "_sum ", formatFloat(histogram.Sum.Seconds()))
+	text.line(name, "_count ", strconv.FormatUint(histogram.Count, 10))
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
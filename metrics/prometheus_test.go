@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"ashishkujoy/bitcask"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStatsAsPrometheusText(t *testing.T) {
+	stats := bitcask.Stats{
+		Keys:          3,
+		Segments:      2,
+		TotalBytes:    1024,
+		MergeRuns:     1,
+		MergeDuration: 1500 * time.Millisecond,
+		ReadLatency: bitcask.Histogram{
+			Buckets: []bitcask.HistogramBucket{{UpperBound: time.Millisecond, Count: 2}, {UpperBound: time.Second, Count: 3}},
+			Count:   4,
+			Sum:     2 * time.Second,
+		},
+	}
+
+	var text strings.Builder
+	require.NoError(t, WriteText(&text, stats))
+
+	lines := strings.Split(text.String(), "\n")
+	require.Contains(t, lines, "# TYPE bitcask_keys gauge")
+	require.Contains(t, lines, "bitcask_keys 3")
+	require.Contains(t, lines, "bitcask_segment_bytes 1024")
+	require.Contains(t, lines, "# TYPE bitcask_merge_runs_total counter")
+	require.Contains(t, lines, "bitcask_merge_duration_seconds_total 1.5")
+	require.Contains(t, lines, "# TYPE bitcask_read_duration_seconds histogram")
+	require.Contains(t, lines, `bitcask_read_duration_seconds_bucket{le="0.001"} 2`)
+	require.Contains(t, lines, `bitcask_read_duration_seconds_bucket{le="1"} 3`)
+	require.Contains(t, lines, `bitcask_read_duration_seconds_bucket{le="+Inf"} 4`)
+	require.Contains(t, lines, "bitcask_read_duration_seconds_sum 2")
+	require.Contains(t, lines, "bitcask_read_duration_seconds_count 4")
+}
+
+func TestHandlerServesTheStats(t *testing.T) {
+	handler := Handler(func() (bitcask.Stats, error) { return bitcask.Stats{Keys: 7}, nil })
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, ContentType, recorder.Header().Get("Content-Type"))
+	require.Contains(t, recorder.Body.String(), "\nbitcask_keys 7\n")
+
+	failing := Handler(func() (bitcask.Stats, error) { return bitcask.Stats{}, bitcask.ErrClosed })
+	recorder = httptest.NewRecorder()
+	failing.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
@@ -0,0 +1,38 @@
+package bitcask
+
+import "ashishkujoy/bitcask/config"
+
+// Option changes a single setting of the database opened by Open
+type Option = config.Option
+
+var (
+	// WithMaxSegmentSize sets the size in bytes beyond which the active segment is rolled over
+	WithMaxSegmentSize = config.WithMaxSegmentSize
+	// WithMergeInterval sets the duration between two runs of merge
+	WithMergeInterval = config.WithMergeInterval
+	// WithMergeSegmentsToRead limits the number of inactive segments read by a single run of merge
+	WithMergeSegmentsToRead = config.WithMergeSegmentsToRead
+	// WithClock sets the clock used to generate file ids and entry timestamps
+	WithClock = config.WithClock
+	// WithSyncPolicy sets when appended entries are flushed to the disk
+	WithSyncPolicy = config.WithSyncPolicy
+	// WithCompression sets the codec used to compress values
+	WithCompression = config.WithCompression
+	// WithKeyProvider enables encryption of values at rest
+	WithKeyProvider = config.WithKeyProvider
+	// WithMaxKeySize rejects keys larger than the given number of bytes
+	WithMaxKeySize = config.WithMaxKeySize
+	// WithMaxValueSize rejects values larger than the given number of bytes
+	WithMaxValueSize = config.WithMaxValueSize
+	// WithLogger sets the logger of the merges and of the errors which happen in the background
+	WithLogger = config.WithLogger
+	// WithOnError sets a callback called with the errors which happen in the background, which must not call the database
+	WithOnError = config.WithOnError
+	// WithListener sets the listener told about the lifecycle events of the database
+	WithListener = config.WithListener
+)
+
+// WithKeyCodec sets the codec used to convert keys to and from bytes. It is required by Open.
+func WithKeyCodec[Key config.BitcaskKey](codec config.KeyCodec[Key]) Option {
+	return config.WithKeyCodec(codec)
+}
@@ -0,0 +1,27 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/internal/dirlock"
+	"ashishkujoy/bitcask/kv"
+	"fmt"
+)
+
+// OpenReadOnly opens an existing directory without changing it: merge never runs, and every write returns ErrReadOnly
+func OpenReadOnly[Key config.BitcaskKey](directory string, opts ...Option) (*DB[Key], error) {
+	return Open[Key](directory, append(opts, config.WithReadOnly())...)
+}
+
+// newReadOnlyDB locks the directory and reloads its segments, without starting any worker
+func newReadOnlyDB[Key config.BitcaskKey](config *config.Config[Key]) (*DB[Key], error) {
+	unlockDirectory, err := dirlock.Shared(config.Directory())
+	if err != nil {
+		return nil, fmt.Errorf("locking directory %v: %w", config.Directory(), err)
+	}
+	store, err := kv.NewKVStore(config)
+	if err != nil {
+		unlockDirectory()
+		return nil, err
+	}
+	return &DB[Key]{kvStore: store, unlockDirectory: unlockDirectory}, nil
+}
@@ -0,0 +1,91 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/kv"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listDirectory(t *testing.T, directory string) map[string]int64 {
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	sizeByName := map[string]int64{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		sizeByName[entry.Name()] = info.Size()
+	}
+	return sizeByName
+}
+
+func TestOpenReadOnlyReadsWithoutChangingTheDirectory(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testReadOnly")
+	defer os.RemoveAll(tempDir)
+	db := openWithSegmentSize(t, tempDir, 16)
+	for _, topic := range []string{"Databases", "Microservices", "Networks"} {
+		require.NoError(t, db.Put("Topic", []byte(topic)))
+	}
+	require.NoError(t, db.Put("Disk", []byte("SSD")))
+	require.NoError(t, db.Delete("Disk"))
+	db.Shutdown()
+	before := listDirectory(t, tempDir)
+
+	readOnly, err := OpenReadOnly[serializableKey](tempDir, WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}), WithMaxSegmentSize(16))
+	require.NoError(t, err)
+	value, err := readOnly.Get("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Networks", string(value))
+	_, err = readOnly.Get("Disk")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.ErrorIs(t, readOnly.Put("Topic", []byte("Compilers")), ErrReadOnly)
+	require.ErrorIs(t, readOnly.Delete("Topic"), ErrReadOnly)
+	_, err = readOnly.PutIfAbsent("Disk", []byte("HDD"))
+	require.ErrorIs(t, err, ErrReadOnly)
+	require.ErrorIs(t, readOnly.Txn(func(tx *kv.Txn[serializableKey]) error {
+		return tx.Put("Disk", []byte("HDD"))
+	}), ErrReadOnly)
+	require.ErrorIs(t, readOnly.Merge(), ErrReadOnly)
+
+	stats, err := readOnly.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.Keys)
+	require.Equal(t, len(before), stats.Segments)
+
+	var keys []serializableKey
+	require.NoError(t, readOnly.ScanKeys(nil, func(key serializableKey) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	require.Equal(t, []serializableKey{"Topic"}, keys)
+
+	readOnly.clearLog()
+	readOnly.Shutdown()
+	require.Equal(t, before, listDirectory(t, tempDir))
+}
+
+func TestOpenReadOnlyAMissingDirectory(t *testing.T) {
+	_, err := OpenReadOnly[serializableKey](
+		t.TempDir()+"/missing",
+		WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}),
+	)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOpenReadOnlyAnEmptyDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	readOnly, err := OpenReadOnly[serializableKey](tempDir, WithKeyCodec[serializableKey](config.StringKeyCodec[serializableKey]{}))
+	require.NoError(t, err)
+	defer readOnly.Shutdown()
+
+	require.Equal(t, 0, readOnly.Len())
+	reader, err := readOnly.LogReader(Position{})
+	require.NoError(t, err)
+	record, err := reader.TryNext()
+	require.NoError(t, err)
+	require.Nil(t, record)
+	require.Empty(t, listDirectory(t, tempDir))
+}
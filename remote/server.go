@@ -0,0 +1,194 @@
+// Package remote serves a DB to the client package over a compact binary protocol, described in the rpc package.
+package remote
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/internal/rpc"
+	"ashishkujoy/bitcask/internal/wire"
+	"ashishkujoy/bitcask/kv"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// scanFlushInterval is the number of scanned entries after which the response is flushed to the client
+const scanFlushInterval = 64
+
+// Server serves the requests of its clients on a DB
+type Server[Key config.BitcaskKey] struct {
+	db *bitcask.DB[Key]
+}
+
+// NewServer creates a Server of the requests on db
+func NewServer[Key config.BitcaskKey](db *bitcask.DB[Key]) *Server[Key] {
+	return &Server[Key]{db: db}
+}
+
+// Serve serves every connection accepted on listener in its own goroutine, until ctx is done or accepting fails
+func (server *Server[Key]) Serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+	defer listener.Close()
+
+	var connections sync.WaitGroup
+	defer connections.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		connections.Add(1)
+		go func() {
+			defer connections.Done()
+			_ = server.ServeConn(ctx, conn)
+		}()
+	}
+}
+
+// ServeConn answers the requests sent on conn, in order, until ctx is done or the client goes away
+func (server *Server[Key]) ServeConn(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		request, err := wire.ReadFrameUpTo(reader, rpc.MaxRequestPayload)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := server.handle(request, writer); err != nil {
+			return err
+		}
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle writes the response of the request. Errors of the DB are sent to the client, only the errors writing the response are returned.
+func (server *Server[Key]) handle(request *wire.Frame, writer *bufio.Writer) error {
+	fields := wire.NewPayloadReader(request.Payload)
+	var response []byte
+	var err error
+	switch request.Kind {
+	case rpc.OpGet:
+		response, err = server.get(fields)
+	case rpc.OpPut:
+		err = server.put(fields)
+	case rpc.OpDelete:
+		err = server.delete(fields)
+	case rpc.OpPutAll:
+		err = server.putAll(fields)
+	case rpc.OpScan:
+		return server.scan(fields, writer)
+	case rpc.OpPing:
+	default:
+		err = &rpc.Error{Code: rpc.CodeBadRequest, Message: fmt.Sprintf("unknown operation %v", request.Kind)}
+	}
+	if err != nil {
+		return wire.WriteFrame(writer, rpc.StatusError, rpc.EncodeError(err))
+	}
+	return wire.WriteFrame(writer, rpc.StatusOK, response)
+}
+
+func (server *Server[Key]) get(fields *wire.PayloadReader) ([]byte, error) {
+	key, err := server.decodeKey(fields.Rest(), fields)
+	if err != nil {
+		return nil, err
+	}
+	return server.db.Get(key)
+}
+
+func (server *Server[Key]) put(fields *wire.PayloadReader) error {
+	key, err := server.decodeKey(fields.Bytes(), fields)
+	if err != nil {
+		return err
+	}
+	return server.db.Put(key, fields.Rest())
+}
+
+func (server *Server[Key]) delete(fields *wire.PayloadReader) error {
+	key, err := server.decodeKey(fields.Rest(), fields)
+	if err != nil {
+		return err
+	}
+	return server.db.Delete(key)
+}
+
+// putAll decodes every pair before putting any, so a malformed request puts nothing
+func (server *Server[Key]) putAll(fields *wire.PayloadReader) error {
+	count := fields.Uint32()
+	if fields.Err() != nil {
+		return badRequest(fields.Err())
+	}
+	pairs := make([]kv.KeyValue[Key], 0, min(count, 1024))
+	for range count {
+		key, err := server.decodeKey(fields.Bytes(), fields)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, kv.KeyValue[Key]{Key: key, Value: fields.Bytes()})
+	}
+	if fields.Err() != nil {
+		return badRequest(fields.Err())
+	}
+	return server.db.PutAll(pairs)
+}
+
+// scan writes an entry frame per key, flushing every scanFlushInterval keys, followed by an end frame, or by an error frame if the scan fails midway
+func (server *Server[Key]) scan(fields *wire.PayloadReader, writer *bufio.Writer) error {
+	keyCodec := server.db.KeyCodec()
+	var writeErr error
+	scanned := 0
+	err := server.db.Scan(fields.Rest(), func(key Key, value []byte) bool {
+		entry := wire.AppendBytes(nil, keyCodec.Encode(key))
+		if writeErr = wire.WriteFrame(writer, rpc.StatusEntry, append(entry, value...)); writeErr != nil {
+			return false
+		}
+		if scanned++; scanned%scanFlushInterval == 0 {
+			writeErr = writer.Flush()
+		}
+		return writeErr == nil
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return wire.WriteFrame(writer, rpc.StatusError, rpc.EncodeError(err))
+	}
+	return wire.WriteFrame(writer, rpc.StatusEnd, nil)
+}
+
+func (server *Server[Key]) decodeKey(encodedKey []byte, fields *wire.PayloadReader) (Key, error) {
+	var key Key
+	if fields.Err() != nil {
+		return key, badRequest(fields.Err())
+	}
+	key, err := server.db.KeyCodec().Decode(encodedKey)
+	if err != nil {
+		return key, badRequest(err)
+	}
+	return key, nil
+}
+
+func badRequest(err error) error {
+	return &rpc.Error{Code: rpc.CodeBadRequest, Message: err.Error()}
+}
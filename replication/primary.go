@@ -0,0 +1,210 @@
+package replication
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/internal/wire"
+	"ashishkujoy/bitcask/kv"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"time"
+)
+
+// DefaultHeartbeatInterval is how often a primary tells a caught up replica that it has nothing more to send
+const DefaultHeartbeatInterval = 100 * time.Millisecond
+
+// Primary streams the records of a DB to its replicas
+type Primary[Key config.BitcaskKey] struct {
+	db                *bitcask.DB[Key]
+	heartbeatInterval time.Duration
+}
+
+// NewPrimary creates a Primary streaming the records of db, which sends heartbeats every heartbeatInterval while a replica is caught up
+func NewPrimary[Key config.BitcaskKey](db *bitcask.DB[Key], heartbeatInterval time.Duration) *Primary[Key] {
+	return &Primary[Key]{db: db, heartbeatInterval: heartbeatInterval}
+}
+
+// Serve streams a snapshot, if asked for, and then the records to the replica over conn, until ctx is done or the replica goes away
+func (primary *Primary[Key]) Serve(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err := primary.serve(ctx, conn)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (primary *Primary[Key]) serve(ctx context.Context, conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	hello, err := wire.ReadFrameUpTo(reader, helloPayloadSize)
+	if err != nil {
+		return err
+	}
+	if hello.Kind != frameHello {
+		return fmt.Errorf("expected a hello frame, got frame type %v", hello.Kind)
+	}
+	payload := wire.NewPayloadReader(hello.Payload)
+	mode := payload.Byte()
+	from := kv.Position{FileId: payload.Uint64(), Offset: int64(payload.Uint64())}
+	if payload.Err() != nil {
+		return payload.Err()
+	}
+
+	if mode == modeBootstrap {
+		if from, err = primary.sendSnapshot(ctx, writer); err != nil {
+			return err
+		}
+	}
+
+	logReader, err := primary.db.LogReader(from)
+	if err != nil {
+		return sendError(writer, err)
+	}
+	return primary.stream(ctx, logReader, writer)
+}
+
+// sendSnapshot backs the DB up to a temporary directory, sends the segments of the backup and returns the position following them
+func (primary *Primary[Key]) sendSnapshot(ctx context.Context, writer *bufio.Writer) (kv.Position, error) {
+	snapshotDir, err := os.MkdirTemp(os.TempDir(), "bitcask-replication-snapshot")
+	if err != nil {
+		return kv.Position{}, err
+	}
+	defer os.RemoveAll(snapshotDir)
+
+	manifest, err := primary.db.Backup(ctx, snapshotDir)
+	if err != nil {
+		return kv.Position{}, err
+	}
+
+	var from kv.Position
+	for _, segment := range manifest.Segments {
+		if err := sendSegment(writer, path.Join(snapshotDir, kvlog.SegmentFileName(segment.FileId)), segment.FileId); err != nil {
+			return kv.Position{}, err
+		}
+		from = kv.Position{FileId: segment.FileId, Offset: segment.Size}
+	}
+
+	payload := wire.LittleEndian.AppendUint64(nil, from.FileId)
+	payload = wire.LittleEndian.AppendUint64(payload, uint64(from.Offset))
+	if err := wire.WriteFrame(writer, frameSnapshotEnd, payload); err != nil {
+		return kv.Position{}, err
+	}
+	return from, writer.Flush()
+}
+
+// sendSegment sends the segment file in chunks. An empty segment is sent as a single empty chunk, so the replica still creates it.
+func sendSegment(writer *bufio.Writer, filePath string, fileId uint64) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	chunk := make([]byte, segmentChunkSize)
+	for sent := 0; ; sent++ {
+		n, err := io.ReadFull(file, chunk)
+		if n > 0 || sent == 0 {
+			payload := append(wire.LittleEndian.AppendUint64(nil, fileId), chunk[:n]...)
+			if err := wire.WriteFrame(writer, frameSegmentChunk, payload); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// stream sends the records read from the log, and a heartbeat every time the replica catches up and every heartbeatInterval while it stays caught up
+func (primary *Primary[Key]) stream(ctx context.Context, logReader *kv.LogReader[Key], writer *bufio.Writer) error {
+	keyCodec := primary.db.KeyCodec()
+	for {
+		record, err := logReader.TryNext()
+		if err != nil {
+			return sendError(writer, err)
+		}
+		if record != nil {
+			if err := writeRecord(writer, encodeRecordKey(keyCodec, record), record, logReader.Position()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := wire.WriteFrame(writer, frameHeartbeat, nil); err != nil {
+			return err
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, primary.heartbeatInterval)
+		record, err = logReader.Next(waitCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			continue
+		}
+		if err != nil {
+			return sendError(writer, err)
+		}
+		if err := writeRecord(writer, encodeRecordKey(keyCodec, record), record, logReader.Position()); err != nil {
+			return err
+		}
+	}
+}
+
+// writeRecord sends the record along with the position following it, which is where the replica resumes from
+func writeRecord[Key config.BitcaskKey](writer io.Writer, encodedKey []byte, record *kv.LogRecord[Key], next kv.Position) error {
+	payload := wire.LittleEndian.AppendUint64(nil, next.FileId)
+	payload = wire.LittleEndian.AppendUint64(payload, uint64(next.Offset))
+	var flags byte
+	if record.Deleted {
+		flags |= recordDeleted
+	}
+	if record.DropsKeyspace {
+		flags |= recordDropsKeyspace
+	}
+	payload = append(payload, flags)
+	payload = wire.AppendBytes(payload, []byte(record.Keyspace))
+	payload = wire.AppendBytes(payload, encodedKey)
+	payload = append(payload, record.Value...)
+	return wire.WriteFrame(writer, frameRecord, payload)
+}
+
+// encodeRecordKey encodes the key of the record, which is empty for the drop of a keyspace
+func encodeRecordKey[Key config.BitcaskKey](keyCodec config.KeyCodec[Key], record *kv.LogRecord[Key]) []byte {
+	if record.DropsKeyspace {
+		return nil
+	}
+	return keyCodec.Encode(record.Key)
+}
+
+// sendError reports err to the replica before returning it
+func sendError(writer *bufio.Writer, err error) error {
+	code := errorCodeOther
+	if errors.Is(err, bitcask.ErrPositionCompacted) {
+		code = errorCodePositionCompacted
+	}
+	payload := append([]byte{code}, err.Error()...)
+	if writeErr := wire.WriteFrame(writer, frameError, payload); writeErr == nil {
+		_ = writer.Flush()
+	}
+	return err
+}
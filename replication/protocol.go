@@ -0,0 +1,35 @@
+package replication
+
+// The replica sends a single hello frame, after which the primary sends the segments of a snapshot, if asked for, followed by records and heartbeats
+const (
+	frameHello byte = iota + 1
+	frameSegmentChunk
+	frameSnapshotEnd
+	frameRecord
+	frameHeartbeat
+	frameError
+)
+
+// Modes of the hello frame
+const (
+	modeBootstrap byte = iota + 1
+	modeResume
+)
+
+// Flags of the record frame, which carries the position following the record, the flags, the keyspace, the key and the value
+const (
+	recordDeleted byte = 1 << iota
+	recordDropsKeyspace
+)
+
+// helloPayloadSize is the size of the payload of the hello frame: mode, fileId and offset
+const helloPayloadSize = 1 + 8 + 8
+
+// Codes of the error frame
+const (
+	errorCodeOther byte = iota + 1
+	errorCodePositionCompacted
+)
+
+// segmentChunkSize is the size of the chunks a snapshot segment is sent in
+const segmentChunkSize = 1024 * 1024
@@ -0,0 +1,237 @@
+package replication
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/internal/wire"
+	"ashishkujoy/bitcask/kv"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Replica applies the records streamed by a primary to a DB, in the order they were appended on the primary
+type Replica[Key config.BitcaskKey] struct {
+	db     *bitcask.DB[Key]
+	conn   net.Conn
+	reader *bufio.Reader
+
+	lock         sync.Mutex
+	position     kv.Position
+	caughtUp     bool
+	lastCaughtUp time.Time
+}
+
+// Bootstrap creates a new replica in directory, which must hold no segment, from a snapshot of the segments of the primary at the other end of conn
+func Bootstrap[Key config.BitcaskKey](ctx context.Context, conn net.Conn, directory string, opts ...bitcask.Option) (*Replica[Key], error) {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	existing, err := kvlog.ListSegmentFiles(directory)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("can not bootstrap a replica in %v, it already contains %v segments", directory, len(existing))
+	}
+
+	if err := sendHello(conn, modeBootstrap, kv.Position{}); err != nil {
+		return nil, contextErrOr(ctx, err)
+	}
+	reader := bufio.NewReader(conn)
+	from, err := receiveSnapshot(reader, directory)
+	if err != nil {
+		return nil, contextErrOr(ctx, err)
+	}
+
+	db, err := bitcask.Open[Key](directory, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Replica[Key]{db: db, conn: conn, reader: reader, position: from, lastCaughtUp: time.Now()}, nil
+}
+
+// Resume attaches db as a replica of the primary at the other end of conn, asking for the records starting at from
+func Resume[Key config.BitcaskKey](conn net.Conn, db *bitcask.DB[Key], from kv.Position) (*Replica[Key], error) {
+	if err := sendHello(conn, modeResume, from); err != nil {
+		return nil, err
+	}
+	return &Replica[Key]{db: db, conn: conn, reader: bufio.NewReader(conn), position: from, lastCaughtUp: time.Now()}, nil
+}
+
+// Run applies the records streamed by the primary until ctx is done or the connection fails
+func (replica *Replica[Key]) Run(ctx context.Context) error {
+	defer replica.conn.Close()
+	stop := context.AfterFunc(ctx, func() { replica.conn.Close() })
+	defer stop()
+
+	keyCodec := replica.db.KeyCodec()
+	for {
+		frame, err := wire.ReadFrame(replica.reader)
+		if err != nil {
+			return contextErrOr(ctx, err)
+		}
+		switch frame.Kind {
+		case frameRecord:
+			if err := replica.apply(frame.Payload, keyCodec); err != nil {
+				return err
+			}
+		case frameHeartbeat:
+			replica.lock.Lock()
+			replica.caughtUp = true
+			replica.lastCaughtUp = time.Now()
+			replica.lock.Unlock()
+		case frameError:
+			return decodeError(frame.Payload)
+		default:
+			return fmt.Errorf("unexpected frame type %v", frame.Kind)
+		}
+	}
+}
+
+// DB returns the replica DB
+func (replica *Replica[Key]) DB() *bitcask.DB[Key] {
+	return replica.db
+}
+
+// Position returns the position, in the log of the primary, following the last applied record. It is the position to Resume from.
+func (replica *Replica[Key]) Position() kv.Position {
+	replica.lock.Lock()
+	defer replica.lock.Unlock()
+
+	return replica.position
+}
+
+// Lag returns how long the replica has been behind the primary, as last reported by the primary
+func (replica *Replica[Key]) Lag() time.Duration {
+	replica.lock.Lock()
+	defer replica.lock.Unlock()
+
+	if replica.caughtUp {
+		return 0
+	}
+	return time.Since(replica.lastCaughtUp)
+}
+
+// CaughtUp returns true if the replica had applied every record when the primary last reported
+func (replica *Replica[Key]) CaughtUp() bool {
+	replica.lock.Lock()
+	defer replica.lock.Unlock()
+
+	return replica.caughtUp
+}
+
+func (replica *Replica[Key]) apply(payload []byte, keyCodec config.KeyCodec[Key]) error {
+	fields := wire.NewPayloadReader(payload)
+	next := kv.Position{FileId: fields.Uint64(), Offset: int64(fields.Uint64())}
+	flags := fields.Byte()
+	keyspace := replica.db.Keyspace(string(fields.Bytes()))
+	encodedKey := fields.Bytes()
+	value := fields.Rest()
+	if fields.Err() != nil {
+		return fields.Err()
+	}
+
+	var err error
+	if flags&recordDropsKeyspace != 0 {
+		err = keyspace.Drop()
+	} else if key, decodeErr := keyCodec.Decode(encodedKey); decodeErr != nil {
+		err = decodeErr
+	} else if flags&recordDeleted != 0 {
+		err = keyspace.Delete(key)
+	} else {
+		err = keyspace.Put(key, value)
+	}
+	if err != nil {
+		return err
+	}
+
+	replica.lock.Lock()
+	replica.position = next
+	replica.caughtUp = false
+	replica.lock.Unlock()
+	return nil
+}
+
+func sendHello(conn net.Conn, mode byte, from kv.Position) error {
+	payload := wire.LittleEndian.AppendUint64([]byte{mode}, from.FileId)
+	payload = wire.LittleEndian.AppendUint64(payload, uint64(from.Offset))
+	return wire.WriteFrame(conn, frameHello, payload)
+}
+
+// receiveSnapshot writes the segments sent by the primary to directory, and returns the position following them
+func receiveSnapshot(reader *bufio.Reader, directory string) (kv.Position, error) {
+	files := make(map[uint64]*os.File)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	for {
+		frame, err := wire.ReadFrame(reader)
+		if err != nil {
+			return kv.Position{}, err
+		}
+		fields := wire.NewPayloadReader(frame.Payload)
+		switch frame.Kind {
+		case frameSegmentChunk:
+			fileId := fields.Uint64()
+			if fields.Err() != nil {
+				return kv.Position{}, fields.Err()
+			}
+			file, ok := files[fileId]
+			if !ok {
+				if file, err = os.Create(path.Join(directory, kvlog.SegmentFileName(fileId))); err != nil {
+					return kv.Position{}, err
+				}
+				files[fileId] = file
+			}
+			if _, err := file.Write(fields.Rest()); err != nil {
+				return kv.Position{}, err
+			}
+		case frameSnapshotEnd:
+			from := kv.Position{FileId: fields.Uint64(), Offset: int64(fields.Uint64())}
+			if fields.Err() != nil {
+				return kv.Position{}, fields.Err()
+			}
+			for _, file := range files {
+				if err := file.Sync(); err != nil {
+					return kv.Position{}, err
+				}
+			}
+			return from, nil
+		case frameError:
+			return kv.Position{}, decodeError(frame.Payload)
+		default:
+			return kv.Position{}, fmt.Errorf("unexpected frame type %v during the snapshot", frame.Kind)
+		}
+	}
+}
+
+func decodeError(payload []byte) error {
+	if len(payload) == 0 {
+		return errors.New("primary reported an error")
+	}
+	if payload[0] == errorCodePositionCompacted {
+		return fmt.Errorf("%w: %v", bitcask.ErrPositionCompacted, string(payload[1:]))
+	}
+	return fmt.Errorf("primary reported an error: %v", string(payload[1:]))
+}
+
+func contextErrOr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package replication
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/kv"
+	"context"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, directory string) *bitcask.DB[string] {
+	db, err := bitcask.Open[string](
+		directory,
+		bitcask.WithKeyCodec[string](config.StringKeyCodec[string]{}),
+		bitcask.WithMaxSegmentSize(64),
+	)
+	require.NoError(t, err)
+	return db
+}
+
+// serve runs the primary over one end of a pipe, and returns the other end along with the channel Serve returns on
+func serve(ctx context.Context, db *bitcask.DB[string]) (net.Conn, chan error) {
+	primaryConn, replicaConn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- NewPrimary(db, 10*time.Millisecond).Serve(ctx, primaryConn)
+	}()
+	return replicaConn, served
+}
+
+func requireValue(t *testing.T, db *bitcask.DB[string], key string, expected string) {
+	require.Eventually(t, func() bool {
+		value, err := db.Get(key)
+		return err == nil && string(value) == expected
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestBootstrapAReplicaAndStreamTheWritesAfterTheSnapshot(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testBootstrap")
+	defer os.RemoveAll(tempDir)
+
+	primaryDb := open(t, path.Join(tempDir, "primary"))
+	defer primaryDb.Shutdown()
+	primaryDb.Put("Topic", []byte("Microservices"))
+	primaryDb.Put("Disk", []byte("SSD"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, served := serve(ctx, primaryDb)
+
+	replica, err := Bootstrap[string](
+		ctx,
+		conn,
+		path.Join(tempDir, "replica"),
+		bitcask.WithKeyCodec[string](config.StringKeyCodec[string]{}),
+	)
+	require.NoError(t, err)
+	defer replica.DB().Shutdown()
+
+	value, err := replica.DB().Get("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Microservices", string(value))
+
+	ran := make(chan error, 1)
+	go func() { ran <- replica.Run(ctx) }()
+
+	primaryDb.Put("Engine", []byte("Bitcask"))
+	primaryDb.Delete("Disk")
+
+	requireValue(t, replica.DB(), "Engine", "Bitcask")
+	require.Eventually(t, func() bool {
+		_, err := replica.DB().Get("Disk")
+		return err != nil
+	}, 2*time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-ran, context.Canceled)
+	require.ErrorIs(t, <-served, context.Canceled)
+}
+
+func TestResumeAReplicaFromItsPosition(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testResume")
+	defer os.RemoveAll(tempDir)
+
+	primaryDb := open(t, path.Join(tempDir, "primary"))
+	defer primaryDb.Shutdown()
+	replicaDb := open(t, path.Join(tempDir, "replica"))
+	defer replicaDb.Shutdown()
+
+	primaryDb.Put("Topic", []byte("Microservices"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, _ := serve(ctx, primaryDb)
+	replica, err := Resume(conn, replicaDb, kv.Position{})
+	require.NoError(t, err)
+	ran := make(chan error, 1)
+	go func() { ran <- replica.Run(ctx) }()
+
+	requireValue(t, replicaDb, "Topic", "Microservices")
+	cancel()
+	<-ran
+	position := replica.Position()
+	require.NotEqual(t, kv.Position{}, position)
+
+	primaryDb.Put("Topic", []byte("Databases"))
+	primaryDb.Put("Disk", []byte("SSD"))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	conn, _ = serve(ctx, primaryDb)
+	replica, err = Resume(conn, replicaDb, position)
+	require.NoError(t, err)
+	go replica.Run(ctx)
+
+	requireValue(t, replicaDb, "Topic", "Databases")
+	requireValue(t, replicaDb, "Disk", "SSD")
+}
+
+func TestStreamTheWritesAndTheDropsOfTheKeyspaces(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testReplicateKeyspaces")
+	defer os.RemoveAll(tempDir)
+
+	primaryDb := open(t, path.Join(tempDir, "primary"))
+	defer primaryDb.Shutdown()
+	replicaDb := open(t, path.Join(tempDir, "replica"))
+	defer replicaDb.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, _ := serve(ctx, primaryDb)
+	replica, err := Resume(conn, replicaDb, kv.Position{})
+	require.NoError(t, err)
+	go replica.Run(ctx)
+
+	primaryDb.Keyspace("users").Put("Topic", []byte("Alice"))
+	primaryDb.Keyspace("orders").Put("Topic", []byte("Book"))
+	primaryDb.Put("Topic", []byte("Microservices"))
+	primaryDb.Keyspace("users").Delete("Topic")
+	primaryDb.Keyspace("orders").Drop()
+	primaryDb.Keyspace("users").Put("Disk", []byte("SSD"))
+
+	require.Eventually(t, func() bool {
+		value, err := replicaDb.Keyspace("users").Get("Disk")
+		return err == nil && string(value) == "SSD"
+	}, 2*time.Second, 5*time.Millisecond)
+	value, err := replicaDb.Get("Topic")
+	require.NoError(t, err)
+	require.Equal(t, "Microservices", string(value))
+	_, err = replicaDb.Keyspace("users").Get("Topic")
+	require.ErrorIs(t, err, bitcask.ErrKeyNotFound)
+	require.Equal(t, []string{"users"}, replicaDb.Keyspaces())
+}
+
+func TestResumeAtACompactedPosition(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testResumeCompacted")
+	defer os.RemoveAll(tempDir)
+
+	primaryDb := open(t, path.Join(tempDir, "primary"))
+	defer primaryDb.Shutdown()
+	replicaDb := open(t, path.Join(tempDir, "replica"))
+	defer replicaDb.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, served := serve(ctx, primaryDb)
+	replica, err := Resume(conn, replicaDb, kv.Position{FileId: 1, Offset: 0})
+	require.NoError(t, err)
+
+	require.ErrorIs(t, replica.Run(ctx), bitcask.ErrPositionCompacted)
+	require.ErrorIs(t, <-served, bitcask.ErrPositionCompacted)
+}
+
+func TestLagOfAReplica(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testLag")
+	defer os.RemoveAll(tempDir)
+
+	primaryDb := open(t, path.Join(tempDir, "primary"))
+	defer primaryDb.Shutdown()
+	replicaDb := open(t, path.Join(tempDir, "replica"))
+	defer replicaDb.Shutdown()
+
+	primaryDb.Put("Topic", []byte("Microservices"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, _ := serve(ctx, primaryDb)
+	replica, err := Resume(conn, replicaDb, kv.Position{})
+	require.NoError(t, err)
+	require.False(t, replica.CaughtUp())
+
+	go replica.Run(ctx)
+
+	require.Eventually(t, replica.CaughtUp, 2*time.Second, 5*time.Millisecond)
+	require.Equal(t, time.Duration(0), replica.Lag())
+	requireValue(t, replicaDb, "Topic", "Microservices")
+}
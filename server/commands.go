@@ -0,0 +1,337 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultScanCount is the number of keys a SCAN walks when no COUNT is given, as in redis
+const defaultScanCount = 10
+
+// execute runs the command and writes its reply. It returns true if the client asked to close the connection.
+func (server *Server[Key]) execute(arguments [][]byte, reply *replyWriter) bool {
+	server.commandsProcessed.Add(1)
+
+	name, arguments := strings.ToUpper(string(arguments[0])), arguments[1:]
+	switch name {
+	case "PING":
+		server.ping(arguments, reply)
+	case "GET":
+		server.get(arguments, reply)
+	case "SET":
+		server.set(arguments, reply)
+	case "DEL":
+		server.del(arguments, reply)
+	case "EXISTS":
+		server.exists(arguments, reply)
+	case "SCAN":
+		server.scan(arguments, reply)
+	case "INFO":
+		server.info(arguments, reply)
+	case "COMMAND":
+		// redis-cli asks for the documentation of the commands when it starts, an empty reply makes it fall back to no hints
+		reply.arrayHeader(0)
+	case "QUIT":
+		reply.simpleString("OK")
+		return true
+	default:
+		reply.error(fmt.Sprintf("ERR unknown command '%v'", strings.ToLower(name)))
+	}
+	return false
+}
+
+func wrongNumberOfArguments(name string, reply *replyWriter) {
+	reply.error(fmt.Sprintf("ERR wrong number of arguments for '%v' command", name))
+}
+
+func (server *Server[Key]) ping(arguments [][]byte, reply *replyWriter) {
+	switch len(arguments) {
+	case 0:
+		reply.simpleString("PONG")
+	case 1:
+		reply.bulkString(arguments[0])
+	default:
+		wrongNumberOfArguments("ping", reply)
+	}
+}
+
+func (server *Server[Key]) get(arguments [][]byte, reply *replyWriter) {
+	if len(arguments) != 1 {
+		wrongNumberOfArguments("get", reply)
+		return
+	}
+	key, ok := server.decodeKey(arguments[0], reply)
+	if !ok {
+		return
+	}
+	value, found, err := server.db.Lookup(key)
+	if err != nil {
+		reply.error("ERR " + err.Error())
+		return
+	}
+	if !found {
+		reply.null()
+		return
+	}
+	reply.bulkString(value)
+}
+
+func (server *Server[Key]) set(arguments [][]byte, reply *replyWriter) {
+	if len(arguments) < 2 {
+		wrongNumberOfArguments("set", reply)
+		return
+	}
+	if len(arguments) > 2 {
+		reply.error("ERR syntax error, SET accepts no option")
+		return
+	}
+	key, ok := server.decodeKey(arguments[0], reply)
+	if !ok {
+		return
+	}
+	if err := server.db.Put(key, arguments[1]); err != nil {
+		reply.error("ERR " + err.Error())
+		return
+	}
+	reply.simpleString("OK")
+}
+
+// del deletes the keys which exist, so deleting a missing key appends no tombstone, and replies with the number of deleted keys
+func (server *Server[Key]) del(arguments [][]byte, reply *replyWriter) {
+	if len(arguments) == 0 {
+		wrongNumberOfArguments("del", reply)
+		return
+	}
+	deleted := 0
+	for _, argument := range arguments {
+		key, ok := server.decodeKey(argument, reply)
+		if !ok {
+			return
+		}
+		_, found, err := server.db.Lookup(key)
+		if err == nil && found {
+			err = server.db.Delete(key)
+			deleted++
+		}
+		if err != nil {
+			reply.error("ERR " + err.Error())
+			return
+		}
+	}
+	reply.integer(deleted)
+}
+
+// exists replies with the number of given keys which exist, counting a key given twice twice, as redis does
+func (server *Server[Key]) exists(arguments [][]byte, reply *replyWriter) {
+	if len(arguments) == 0 {
+		wrongNumberOfArguments("exists", reply)
+		return
+	}
+	existing := 0
+	for _, argument := range arguments {
+		key, ok := server.decodeKey(argument, reply)
+		if !ok {
+			return
+		}
+		_, found, err := server.db.Lookup(key)
+		if err != nil {
+			reply.error("ERR " + err.Error())
+			return
+		}
+		if found {
+			existing++
+		}
+	}
+	reply.integer(existing)
+}
+
+// scan implements SCAN cursor [MATCH pattern] [COUNT count], the cursor being the number of keys walked so far in the order of the encoded keys
+func (server *Server[Key]) scan(arguments [][]byte, reply *replyWriter) {
+	if len(arguments) == 0 {
+		wrongNumberOfArguments("scan", reply)
+		return
+	}
+	cursor, err := strconv.Atoi(string(arguments[0]))
+	if err != nil || cursor < 0 {
+		reply.error("ERR invalid cursor")
+		return
+	}
+
+	var pattern []byte
+	count := defaultScanCount
+	for options := arguments[1:]; len(options) > 0; options = options[2:] {
+		if len(options) < 2 {
+			reply.error("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(string(options[0])) {
+		case "MATCH":
+			pattern = options[1]
+		case "COUNT":
+			if count, err = strconv.Atoi(string(options[1])); err != nil || count < 1 {
+				reply.error("ERR value is not an integer or out of range")
+				return
+			}
+		default:
+			reply.error("ERR syntax error")
+			return
+		}
+	}
+
+	keyCodec := server.db.KeyCodec()
+	var keys [][]byte
+	walked, next := 0, 0
+	err = server.db.ScanKeys(literalPrefix(pattern), func(key Key) bool {
+		if walked == cursor+count {
+			next = walked
+			return false
+		}
+		walked++
+		if walked <= cursor {
+			return true
+		}
+		encodedKey := keyCodec.Encode(key)
+		if pattern == nil || matchGlob(pattern, encodedKey) {
+			keys = append(keys, encodedKey)
+		}
+		return true
+	})
+	if err != nil {
+		reply.error("ERR " + err.Error())
+		return
+	}
+
+	reply.arrayHeader(2)
+	reply.bulkString([]byte(strconv.Itoa(next)))
+	reply.arrayHeader(len(keys))
+	for _, key := range keys {
+		reply.bulkString(key)
+	}
+}
+
+// info replies with the sections of the server information, all of them when no section is given
+func (server *Server[Key]) info(arguments [][]byte, reply *replyWriter) {
+	sections := []struct {
+		name   string
+		fields [][2]string
+	}{
+		{"server", [][2]string{{"bitcask_mode", "standalone"}}},
+		{"clients", [][2]string{{"connected_clients", strconv.FormatInt(server.connectedClients.Load(), 10)}}},
+		{"stats", [][2]string{{"total_commands_processed", strconv.FormatUint(server.commandsProcessed.Load(), 10)}}},
+		{"keyspace", [][2]string{{"db0", fmt.Sprintf("keys=%v", server.db.Len())}}},
+	}
+
+	wanted := make(map[string]bool)
+	for _, argument := range arguments {
+		wanted[strings.ToLower(string(argument))] = true
+	}
+	all := len(wanted) == 0 || wanted["all"] || wanted["default"] || wanted["everything"]
+
+	var info strings.Builder
+	for _, section := range sections {
+		if !all && !wanted[section.name] {
+			continue
+		}
+		if info.Len() > 0 {
+			info.WriteString("\r\n")
+		}
+		fmt.Fprintf(&info, "# %v%v\r\n", strings.ToUpper(section.name[:1]), section.name[1:])
+		for _, field := range section.fields {
+			fmt.Fprintf(&info, "%v:%v\r\n", field[0], field[1])
+		}
+	}
+	reply.bulkString([]byte(info.String()))
+}
+
+func (server *Server[Key]) decodeKey(encodedKey []byte, reply *replyWriter) (Key, bool) {
+	key, err := server.db.KeyCodec().Decode(encodedKey)
+	if err != nil {
+		reply.error("ERR invalid key: " + err.Error())
+		return key, false
+	}
+	return key, true
+}
+
+// literalPrefix returns the part of the glob pattern before its first special character, which every matching key starts with
+func literalPrefix(pattern []byte) []byte {
+	if index := bytes.IndexAny(pattern, `*?[\`); index >= 0 {
+		return pattern[:index]
+	}
+	return pattern
+}
+
+// matchGlob reports whether subject matches the glob pattern, which supports *, ?, [abc], [^abc], [a-z] and \ escapes like the redis MATCH option
+func matchGlob(pattern, subject []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for start := 0; start <= len(subject); start++ {
+				if matchGlob(pattern, subject[start:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(subject) == 0 {
+				return false
+			}
+			pattern, subject = pattern[1:], subject[1:]
+		case '[':
+			if len(subject) == 0 {
+				return false
+			}
+			matched, rest := matchClass(pattern[1:], subject[0])
+			if !matched {
+				return false
+			}
+			pattern, subject = rest, subject[1:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(subject) == 0 || pattern[0] != subject[0] {
+				return false
+			}
+			pattern, subject = pattern[1:], subject[1:]
+		}
+	}
+	return len(subject) == 0
+}
+
+// matchClass matches character against the class pattern starts with, right after its '[', and returns the pattern following the class
+func matchClass(pattern []byte, character byte) (bool, []byte) {
+	negated := len(pattern) > 0 && pattern[0] == '^'
+	if negated {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		if pattern[0] == '\\' && len(pattern) > 1 {
+			pattern = pattern[1:]
+		}
+		low := pattern[0]
+		if len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']' {
+			high := pattern[2]
+			if low > high {
+				low, high = high, low
+			}
+			matched = matched || (character >= low && character <= high)
+			pattern = pattern[3:]
+			continue
+		}
+		matched = matched || character == low
+		pattern = pattern[1:]
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return matched != negated, pattern
+}
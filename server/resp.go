@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// A command is sent by a client either as a RESP array of bulk strings
+//
+//	*<count>\r\n $<length>\r\n<argument>\r\n ...
+//
+// or as an inline command, a single line of arguments separated by spaces.
+const (
+	// maxArguments bounds the number of arguments a command can announce
+	maxArguments = 1024 * 1024
+	// maxBulkLength bounds the length of an argument, like proto-max-bulk-len in redis
+	maxBulkLength = 512 * 1024 * 1024
+	// maxInlineLength bounds the length of an inline command
+	maxInlineLength = 64 * 1024
+)
+
+var errProtocol = errors.New("protocol error")
+
+// readCommand reads the arguments of the next command. An empty inline line is returned as no arguments.
+func readCommand(reader *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+
+	count, err := parseLength(line[1:], maxArguments)
+	if err != nil {
+		return nil, err
+	}
+	var arguments [][]byte
+	for range count {
+		header, err := readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 || header[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got '%s'", errProtocol, header)
+		}
+		length, err := parseLength(header[1:], maxBulkLength)
+		if err != nil {
+			return nil, err
+		}
+		argument, err := readBulk(reader, length)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument)
+	}
+	return arguments, nil
+}
+
+// readBulk reads a bulk string of length bytes followed by CRLF into a buffer growing as its bytes arrive, and returns it without the CRLF
+func readBulk(reader *bufio.Reader, length int) ([]byte, error) {
+	var argument bytes.Buffer
+	n, err := argument.ReadFrom(io.LimitReader(reader, int64(length)+2))
+	if err != nil {
+		return nil, err
+	}
+	if n < int64(length)+2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if !bytes.HasSuffix(argument.Bytes(), []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: bulk string is not terminated by CRLF", errProtocol)
+	}
+	return argument.Bytes()[:length], nil
+}
+
+// readLine reads a line terminated by CRLF, or by LF alone for inline commands, and returns it without the terminator
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		fragment, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, fragment...)
+		if len(line) > maxInlineLength {
+			return nil, fmt.Errorf("%w: line is longer than %v bytes", errProtocol, maxInlineLength)
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+func parseLength(field []byte, max int) (int, error) {
+	length, err := strconv.Atoi(string(field))
+	if err != nil || length < 0 || length > max {
+		return 0, fmt.Errorf("%w: invalid length '%s'", errProtocol, field)
+	}
+	return length, nil
+}
+
+// replyWriter writes RESP replies to a buffered connection, remembering the first write error
+type replyWriter struct {
+	writer *bufio.Writer
+	err    error
+}
+
+func (reply *replyWriter) write(parts ...[]byte) {
+	for _, part := range parts {
+		if reply.err != nil {
+			return
+		}
+		_, reply.err = reply.writer.Write(part)
+	}
+}
+
+func (reply *replyWriter) simpleString(value string) {
+	reply.write([]byte("+"), []byte(value), []byte("\r\n"))
+}
+
+func (reply *replyWriter) error(message string) {
+	reply.write([]byte("-"), []byte(message), []byte("\r\n"))
+}
+
+func (reply *replyWriter) integer(value int) {
+	reply.write([]byte(":"), []byte(strconv.Itoa(value)), []byte("\r\n"))
+}
+
+func (reply *replyWriter) bulkString(value []byte) {
+	reply.write([]byte("$"), []byte(strconv.Itoa(len(value))), []byte("\r\n"), value, []byte("\r\n"))
+}
+
+func (reply *replyWriter) null() {
+	reply.write([]byte("$-1\r\n"))
+}
+
+func (reply *replyWriter) arrayHeader(length int) {
+	reply.write([]byte("*"), []byte(strconv.Itoa(length)), []byte("\r\n"))
+}
+
+func (reply *replyWriter) flush() error {
+	if reply.err != nil {
+		return reply.err
+	}
+	return reply.writer.Flush()
+}
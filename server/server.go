@@ -0,0 +1,94 @@
+// Package server exposes a DB over a subset of RESP, the protocol of redis, so that existing redis clients can use it.
+//
+// The supported commands are GET, SET, DEL, EXISTS, SCAN, PING, INFO, COMMAND and QUIT.
+package server
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Server serves the commands of its clients on a DB
+type Server[Key config.BitcaskKey] struct {
+	db                *bitcask.DB[Key]
+	connectedClients  atomic.Int64
+	commandsProcessed atomic.Uint64
+}
+
+// New creates a Server of the commands on db
+func New[Key config.BitcaskKey](db *bitcask.DB[Key]) *Server[Key] {
+	return &Server[Key]{db: db}
+}
+
+// Serve accepts connections on listener until ctx is done, closing the listener and the connections when it returns
+func (server *Server[Key]) Serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+	defer listener.Close()
+
+	var connections sync.WaitGroup
+	defer connections.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		connections.Add(1)
+		go func() {
+			defer connections.Done()
+			_ = server.ServeConn(ctx, conn)
+		}()
+	}
+}
+
+// ServeConn serves the commands sent on conn until ctx is done, the client sends QUIT or goes away
+func (server *Server[Key]) ServeConn(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	server.connectedClients.Add(1)
+	defer server.connectedClients.Add(-1)
+
+	reader := bufio.NewReader(conn)
+	reply := &replyWriter{writer: bufio.NewWriter(conn)}
+	for {
+		arguments, err := readCommand(reader)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				reply.error("ERR " + err.Error())
+				_ = reply.flush()
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if len(arguments) == 0 {
+			continue
+		}
+
+		quit := server.execute(arguments, reply)
+		if reader.Buffered() == 0 || quit {
+			if err := reply.flush(); err != nil {
+				return err
+			}
+		}
+		if quit {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"ashishkujoy/bitcask"
+	"ashishkujoy/bitcask/config"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// client sends commands as RESP arrays and reads the replies back as lines
+type client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (client *client) send(t *testing.T, arguments ...string) {
+	command := fmt.Sprintf("*%v\r\n", len(arguments))
+	for _, argument := range arguments {
+		command += fmt.Sprintf("$%v\r\n%v\r\n", len(argument), argument)
+	}
+	_, err := client.conn.Write([]byte(command))
+	require.NoError(t, err)
+}
+
+// reply reads the given number of lines of the next reply, and returns them joined by a space
+func (client *client) reply(t *testing.T, lines int) string {
+	var reply []string
+	for range lines {
+		line, err := client.reader.ReadString('\n')
+		require.NoError(t, err)
+		reply = append(reply, strings.TrimSuffix(line, "\r\n"))
+	}
+	return strings.Join(reply, " ")
+}
+
+func startServer(t *testing.T) (*bitcask.DB[config.BytesKey], *client) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testServer")
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+	db, err := bitcask.Open[config.BytesKey](tempDir, bitcask.WithKeyCodec[config.BytesKey](config.BytesKeyCodec{}))
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- New(db).Serve(ctx, listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		cancel()
+		require.ErrorIs(t, <-served, context.Canceled)
+		db.Shutdown()
+	})
+	return db, &client{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func TestGetSetDelAndExists(t *testing.T) {
+	db, client := startServer(t)
+
+	client.send(t, "PING")
+	require.Equal(t, "+PONG", client.reply(t, 1))
+
+	client.send(t, "SET", "Topic", "Microservices")
+	require.Equal(t, "+OK", client.reply(t, 1))
+	client.send(t, "get", "Topic")
+	require.Equal(t, "$13 Microservices", client.reply(t, 2))
+
+	client.send(t, "GET", "Disk")
+	require.Equal(t, "$-1", client.reply(t, 1))
+
+	client.send(t, "EXISTS", "Topic", "Disk", "Topic")
+	require.Equal(t, ":2", client.reply(t, 1))
+
+	client.send(t, "DEL", "Topic", "Disk")
+	require.Equal(t, ":1", client.reply(t, 1))
+	_, err := db.Get("Topic")
+	require.ErrorIs(t, err, bitcask.ErrKeyNotFound)
+
+	client.send(t, "SET", "Topic", "Microservices", "EX", "10")
+	require.Equal(t, "-ERR syntax error, SET accepts no option", client.reply(t, 1))
+	client.send(t, "FLUSHALL")
+	require.Equal(t, "-ERR unknown command 'flushall'", client.reply(t, 1))
+}
+
+func TestPipelinedAndInlineCommands(t *testing.T) {
+	_, client := startServer(t)
+
+	_, err := client.conn.Write([]byte("SET Topic Databases\r\nGET Topic\nPING hello\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, "+OK", client.reply(t, 1))
+	require.Equal(t, "$9 Databases", client.reply(t, 2))
+	require.Equal(t, "$5 hello", client.reply(t, 2))
+}
+
+func TestScanWithCursorMatchAndCount(t *testing.T) {
+	db, client := startServer(t)
+	for _, key := range []string{"user:1", "user:2", "user:3", "order:1"} {
+		require.NoError(t, db.Put(config.BytesKey(key), []byte("value")))
+	}
+
+	client.send(t, "SCAN", "0", "MATCH", "user:*", "COUNT", "2")
+	require.Equal(t, "*2 $1 2 *2 $6 user:1 $6 user:2", client.reply(t, 8))
+	client.send(t, "SCAN", "2", "MATCH", "user:*", "COUNT", "2")
+	require.Equal(t, "*2 $1 0 *1 $6 user:3", client.reply(t, 6))
+
+	client.send(t, "SCAN", "0", "MATCH", "*:1")
+	require.Equal(t, "*2 $1 0 *2 $7 order:1 $6 user:1", client.reply(t, 8))
+}
+
+func TestInfoReportsTheKeyspace(t *testing.T) {
+	db, client := startServer(t)
+	require.NoError(t, db.Put(config.BytesKey("Topic"), []byte("Microservices")))
+
+	client.send(t, "INFO", "keyspace")
+	require.Equal(t, "$24 # Keyspace db0:keys=1", client.reply(t, 3))
+}
+
+func TestMatchGlob(t *testing.T) {
+	matches := []struct {
+		pattern string
+		subject string
+		matched bool
+	}{
+		{"user:*", "user:1", true},
+		{"user:*", "order:1", false},
+		{"h?llo", "hello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"*", "", true},
+	}
+	for _, match := range matches {
+		require.Equal(t, match.matched, matchGlob([]byte(match.pattern), []byte(match.subject)), match.pattern)
+	}
+}
+
+func TestAnnouncedLengthsAreNotAllocatedBeforeTheirBytesArrive(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := readCommand(bufio.NewReader(strings.NewReader("*1048576\r\n$536870912\r\nSET")))
+	runtime.ReadMemStats(&after)
+
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1024*1024))
+}
+
+func TestReadACommandOfBulkStrings(t *testing.T) {
+	arguments, err := readCommand(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$5\r\ntopic\r\n")))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("GET"), []byte("topic")}, arguments)
+
+	_, err = readCommand(bufio.NewReader(strings.NewReader("*1\r\n$3\r\nGETXX")))
+	require.ErrorIs(t, err, errProtocol)
+}
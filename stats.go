@@ -0,0 +1,59 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/kv"
+	"ashishkujoy/bitcask/merge"
+	"time"
+)
+
+// Histogram is a snapshot of the latencies of an operation, with cumulative bucket counts like a Prometheus histogram
+type Histogram = kv.Histogram
+
+// HistogramBucket counts the latencies lower than or equal to its upper bound
+type HistogramBucket = kv.HistogramBucket
+
+// Stats describes the keys and the segments of a database, the merges it ran and the reads and writes it served since it was opened
+type Stats struct {
+	Keys               int           `json:"keys"`
+	Segments           int           `json:"segments"`           // number of segments, the active one included
+	TotalBytes         int64         `json:"totalBytes"`         // bytes of all the segments
+	LiveBytes          int64         `json:"liveBytes"`          // bytes of the entries the keys point to, which merge would keep
+	ActiveSegmentBytes int64         `json:"activeSegmentBytes"` // bytes of the active segment
+	MergeRuns          uint64        `json:"mergeRuns"`          // merges which wrote segments back
+	MergeFailures      uint64        `json:"mergeFailures"`      // merges which returned an error, the cancelled ones included
+	MergeDuration      time.Duration `json:"mergeDuration"`      // total duration of the merge runs
+	LastMergeDuration  time.Duration `json:"lastMergeDuration"`
+	BytesReclaimed     int64         `json:"bytesReclaimed"` // bytes of the merged segments minus the bytes written back in their place
+	Reads              uint64        `json:"reads"`          // gets, along with the values read by scans
+	Writes             uint64        `json:"writes"`         // puts, deletes and batches of puts
+	ReadLatency        Histogram     `json:"readLatency"`
+	WriteLatency       Histogram     `json:"writeLatency"`
+}
+
+// Stats returns the statistics of the database. Latencies include the time spent waiting for the lock.
+func (db *DB[Key]) Stats() (Stats, error) {
+	storeStats, err := db.kvStore.Stats()
+	if err != nil {
+		return Stats{}, err
+	}
+	var mergeStats merge.Stats
+	if db.worker != nil {
+		mergeStats = db.worker.Stats()
+	}
+	return Stats{
+		Keys:               storeStats.Keys,
+		Segments:           storeStats.Segments,
+		TotalBytes:         storeStats.TotalBytes,
+		LiveBytes:          storeStats.LiveBytes,
+		ActiveSegmentBytes: storeStats.ActiveBytes,
+		MergeRuns:          mergeStats.Runs,
+		MergeFailures:      mergeStats.Failures,
+		MergeDuration:      mergeStats.TotalDuration,
+		LastMergeDuration:  mergeStats.LastDuration,
+		BytesReclaimed:     storeStats.ReclaimedBytes,
+		Reads:              storeStats.ReadLatency.Count,
+		Writes:             storeStats.WriteLatency.Count,
+		ReadLatency:        storeStats.ReadLatency,
+		WriteLatency:       storeStats.WriteLatency,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package bitcask
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsOfWritesReadsAndMerges(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testStats")
+	defer os.RemoveAll(tempDir)
+
+	db := openWithSegmentSize(t, tempDir, 16)
+	defer db.Shutdown()
+	for _, value := range []string{"Databases", "Microservices", "Storage engines", "Networks", "Compilers", "Distributed systems"} {
+		require.NoError(t, db.Put("Topic", []byte(value)))
+	}
+	_, err := db.Get("Topic")
+	require.NoError(t, err)
+
+	stats, err := db.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.Keys)
+	require.Equal(t, 6, stats.Segments)
+	require.Equal(t, uint64(6), stats.Writes)
+	require.Equal(t, uint64(1), stats.Reads)
+	require.Equal(t, uint64(6), stats.WriteLatency.Count)
+	require.Less(t, stats.LiveBytes, stats.TotalBytes)
+	require.Greater(t, stats.ActiveSegmentBytes, int64(0))
+	require.Equal(t, uint64(0), stats.MergeRuns)
+
+	require.NoError(t, db.Merge())
+	merged, err := db.Stats()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), merged.MergeRuns)
+	require.Greater(t, merged.MergeDuration, time.Duration(0))
+	require.Greater(t, merged.BytesReclaimed, int64(0))
+	require.Less(t, merged.TotalBytes, stats.TotalBytes)
+	require.Equal(t, stats.LiveBytes, merged.LiveBytes)
+}
@@ -0,0 +1,46 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/config"
+	"ashishkujoy/bitcask/kv"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// syncWorker encapsulates the goroutine that syncs all the segments every fixed duration, as asked by config.SyncEvery
+type syncWorker[Key config.BitcaskKey] struct {
+	kvStore *kv.KVStore[Key]
+	quit    chan struct{}
+}
+
+// newSyncWorker creates an instance of syncWorker and starts it. A failed sync is logged to logger and passed to onError, which may be nil.
+func newSyncWorker[Key config.BitcaskKey](kvStore *kv.KVStore[Key], interval time.Duration, logger *slog.Logger, onError func(error)) *syncWorker[Key] {
+	worker := &syncWorker[Key]{
+		kvStore: kvStore,
+		quit:    make(chan struct{}),
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := worker.kvStore.Sync(); err != nil {
+					logger.Error("sync failed", "error", err)
+					if onError != nil {
+						onError(fmt.Errorf("sync: %w", err))
+					}
+				}
+			case <-worker.quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return worker
+}
+
+// stop closes the quit channel which is used to signal the sync goroutine to stop
+func (worker *syncWorker[Key]) stop() {
+	close(worker.quit)
+}
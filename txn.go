@@ -0,0 +1,16 @@
+package bitcask
+
+import "ashishkujoy/bitcask/kv"
+
+// Txn runs fn in a transaction (see kv.Txn) and commits it if fn returns nil. A commit failing with ErrConflict applies nothing, and can be retried.
+func (db *DB[Key]) Txn(fn func(tx *kv.Txn[Key]) error) error {
+	tx, err := db.kvStore.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Discard()
+		return err
+	}
+	return tx.Commit()
+}
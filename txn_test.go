@@ -0,0 +1,114 @@
+package bitcask
+
+import (
+	"ashishkujoy/bitcask/kv"
+	kvlog "ashishkujoy/bitcask/kv/log"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxnCommitsTheWritesOfATransaction(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testTxnCommit")
+	defer os.RemoveAll(tempDir)
+	db := openWithSegmentSize(t, tempDir, 1024)
+	defer db.Shutdown()
+	require.NoError(t, db.Put("alice", []byte("100")))
+	require.NoError(t, db.Put("bob", []byte("0")))
+
+	require.NoError(t, db.Txn(func(tx *kv.Txn[serializableKey]) error {
+		alice, err := tx.Get("alice")
+		require.NoError(t, err)
+		require.Equal(t, "100", string(alice))
+		require.NoError(t, tx.Put("alice", []byte("60")))
+		require.NoError(t, tx.Put("bob", []byte("40")))
+		require.NoError(t, tx.Delete("carol"))
+
+		written, err := tx.Get("alice")
+		require.NoError(t, err)
+		require.Equal(t, "60", string(written), "a transaction reads its own writes")
+		bob, _ := db.Get("bob")
+		require.Equal(t, "0", string(bob), "the writes are buffered until the commit")
+		return nil
+	}))
+
+	alice, _ := db.Get("alice")
+	bob, _ := db.Get("bob")
+	require.Equal(t, "60", string(alice))
+	require.Equal(t, "40", string(bob))
+
+	failure := errors.New("insufficient funds")
+	require.ErrorIs(t, db.Txn(func(tx *kv.Txn[serializableKey]) error {
+		require.NoError(t, tx.Put("alice", []byte("0")))
+		return failure
+	}), failure)
+	alice, _ = db.Get("alice")
+	require.Equal(t, "60", string(alice))
+}
+
+func TestTxnConflictsWithAWriteOfAKeyItRead(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testTxnConflict")
+	defer os.RemoveAll(tempDir)
+	db := openWithSegmentSize(t, tempDir, 16)
+	defer db.Shutdown()
+	require.NoError(t, db.Put("alice", []byte("100")))
+
+	var leaked *kv.Txn[serializableKey]
+	err := db.Txn(func(tx *kv.Txn[serializableKey]) error {
+		leaked = tx
+		_, err := tx.Get("alice")
+		require.NoError(t, err)
+		_, err = tx.Get("bob")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+		require.NoError(t, tx.Put("carol", []byte("100")))
+
+		require.NoError(t, db.Put("bob", []byte("0")))
+		return nil
+	})
+	require.ErrorIs(t, err, ErrConflict)
+	_, err = db.Get("carol")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = leaked.Get("alice")
+	require.ErrorIs(t, err, ErrTxnDone)
+
+	require.NoError(t, db.Txn(func(tx *kv.Txn[serializableKey]) error {
+		_, err := tx.Get("alice")
+		require.NoError(t, err)
+		for _, value := range []string{"Databases", "Microservices", "Networks"} {
+			require.NoError(t, db.Put("topic", []byte(value)))
+		}
+		require.NoError(t, db.Merge())
+		return tx.Put("carol", []byte("100"))
+	}), "merge moving a key read is not a conflict")
+	carol, _ := db.Get("carol")
+	require.Equal(t, "100", string(carol))
+}
+
+func TestTxnInterruptedInTheMiddleOfItsCommitAppliesNothing(t *testing.T) {
+	tempDir, _ := os.MkdirTemp(os.TempDir(), "testTxnInterrupted")
+	defer os.RemoveAll(tempDir)
+	db := openWithSegmentSize(t, tempDir, 1024)
+	require.NoError(t, db.Put("alice", []byte("100")))
+	require.NoError(t, db.Put("bob", []byte("0")))
+	require.NoError(t, db.Txn(func(tx *kv.Txn[serializableKey]) error {
+		require.NoError(t, tx.Put("alice", []byte("60")))
+		return tx.Put("bob", []byte("40"))
+	}))
+	db.Shutdown()
+
+	segmentFiles, err := kvlog.ListSegmentFiles(tempDir)
+	require.NoError(t, err)
+	records, err := kvlog.ReadRecords(segmentFiles[0].Path, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	require.NoError(t, os.Truncate(segmentFiles[0].Path, int64(records[3].Offset)), "the crash happens before the last write of the commit")
+
+	db = openWithSegmentSize(t, tempDir, 1024)
+	defer db.Shutdown()
+	alice, _ := db.Get("alice")
+	bob, _ := db.Get("bob")
+	require.Equal(t, "100", string(alice))
+	require.Equal(t, "0", string(bob))
+}